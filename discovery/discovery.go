@@ -0,0 +1,244 @@
+// Package discovery fetches a platform's OpenID configuration, as used by 1EdTech LTI Dynamic Registration
+// (see https://www.imsglobal.org/spec/lti-dr/v1p0#platform-configuration), to fill in or validate a peregrine.Platform
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/stevenweathers/peregrine-lti/internal/httpx"
+	"github.com/stevenweathers/peregrine-lti/peregrine"
+)
+
+const (
+	// WellKnownPath is the path of the OpenID configuration relative to the platform issuer
+	WellKnownPath = "/.well-known/openid-configuration"
+	// DefaultTimeout is the default timeout of a configuration request
+	DefaultTimeout = httpx.DefaultTimeout
+	// DefaultMaxResponseSize is the default maximum size in bytes of a configuration response
+	DefaultMaxResponseSize = httpx.DefaultMaxResponseSize
+)
+
+// Configuration is the platforms OpenID configuration
+type Configuration struct {
+	Issuer                                     string                   `json:"issuer"`
+	AuthorizationEndpoint                      string                   `json:"authorization_endpoint"`
+	JWKSURI                                    string                   `json:"jwks_uri"`
+	TokenEndpoint                              string                   `json:"token_endpoint"`
+	RegistrationEndpoint                       string                   `json:"registration_endpoint,omitempty"`
+	ScopesSupported                            []string                 `json:"scopes_supported,omitempty"`
+	ResponseTypesSupported                     []string                 `json:"response_types_supported,omitempty"`
+	SubjectTypesSupported                      []string                 `json:"subject_types_supported,omitempty"`
+	IDTokenSigningAlgValuesSupported           []string                 `json:"id_token_signing_alg_values_supported,omitempty"`
+	ClaimsSupported                            []string                 `json:"claims_supported,omitempty"`
+	TokenEndpointAuthMethodsSupported          []string                 `json:"token_endpoint_auth_methods_supported,omitempty"`
+	TokenEndpointAuthSigningAlgValuesSupported []string                 `json:"token_endpoint_auth_signing_alg_values_supported,omitempty"`
+	LTIPlatformConfiguration                   LTIPlatformConfiguration `json:"https://purl.imsglobal.org/spec/lti-platform-configuration"`
+}
+
+// LTIPlatformConfiguration is the LTI specific platform configuration of the Configuration
+type LTIPlatformConfiguration struct {
+	ProductFamilyCode string             `json:"product_family_code"`
+	Version           string             `json:"version,omitempty"`
+	MessagesSupported []MessageSupported `json:"messages_supported"`
+	Variables         []string           `json:"variables,omitempty"`
+}
+
+// MessageSupported is a message type supported by the platform in the LTIPlatformConfiguration
+type MessageSupported struct {
+	Type       string   `json:"type"`
+	Placements []string `json:"placements,omitempty"`
+}
+
+// SupportsScope returns whether the platform supports the scope
+func (c Configuration) SupportsScope(scope string) bool {
+	for _, s := range c.ScopesSupported {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SupportsMessageType returns whether the platform supports the LTI message type e.g. LtiResourceLinkRequest
+func (c Configuration) SupportsMessageType(messageType string) bool {
+	for _, m := range c.LTIPlatformConfiguration.MessagesSupported {
+		if m.Type == messageType {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Mismatch is a peregrine.Platform field whose stored value differs from the discovered Configuration
+type Mismatch struct {
+	// Field is the peregrine.Platform field name e.g. KeySetURL
+	Field string
+	// Stored is the peregrine.Platform value
+	Stored string
+	// Discovered is the Configuration value
+	Discovered string
+}
+
+func (m Mismatch) String() string {
+	return fmt.Sprintf("%s %s does not match discovered %s", m.Field, m.Stored, m.Discovered)
+}
+
+// platformField is a peregrine.Platform field filled from the Configuration
+type platformField struct {
+	name       string
+	stored     *string
+	discovered string
+}
+
+// platformFields returns the peregrine.Platform fields filled from the Configuration
+func (c Configuration) platformFields(platform *peregrine.Platform) []platformField {
+	return []platformField{
+		{name: "Issuer", stored: &platform.Issuer, discovered: c.Issuer},
+		{name: "AuthLoginURL", stored: &platform.AuthLoginURL, discovered: c.AuthorizationEndpoint},
+		{name: "KeySetURL", stored: &platform.KeySetURL, discovered: c.JWKSURI},
+		{name: "TokenURL", stored: &platform.TokenURL, discovered: c.TokenEndpoint},
+	}
+}
+
+// Apply returns the platform with its empty Issuer, AuthLoginURL, KeySetURL and TokenURL filled in from the
+// Configuration along with the Mismatches of those already set
+func (c Configuration) Apply(platform peregrine.Platform) (peregrine.Platform, []Mismatch) {
+	var mismatches []Mismatch
+	for _, f := range c.platformFields(&platform) {
+		switch {
+		case f.discovered == "":
+		case *f.stored == "":
+			*f.stored = f.discovered
+		case *f.stored != f.discovered:
+			mismatches = append(mismatches, Mismatch{Field: f.name, Stored: *f.stored, Discovered: f.discovered})
+		}
+	}
+
+	return platform, mismatches
+}
+
+// Validate returns the Mismatches between the platform and the Configuration, empty platform fields are not compared
+func (c Configuration) Validate(platform peregrine.Platform) []Mismatch {
+	_, mismatches := c.Apply(platform)
+	return mismatches
+}
+
+// Config holds all the configuration's for Client
+type Config struct {
+	// HTTPClient (OPTIONAL) is the client used to fetch configurations, defaults to a client with DefaultTimeout
+	HTTPClient *http.Client
+	// MaxResponseSize (OPTIONAL) is the maximum size in bytes of a configuration response,
+	// defaults to DefaultMaxResponseSize
+	MaxResponseSize int64
+}
+
+// Client fetches platform OpenID configurations
+type Client struct {
+	config Config
+}
+
+// New returns a new Client for platform configuration discovery
+func New(config Config) *Client {
+	if config.HTTPClient == nil {
+		config.HTTPClient = httpx.NewClient()
+	}
+	if config.MaxResponseSize == 0 {
+		config.MaxResponseSize = DefaultMaxResponseSize
+	}
+
+	return &Client{
+		config: config,
+	}
+}
+
+// ConfigurationURL returns the well known OpenID configuration url of the issuer
+func ConfigurationURL(issuer string) string {
+	return strings.TrimSuffix(issuer, "/") + WellKnownPath
+}
+
+// FetchIssuer fetches the Configuration from the issuers well known OpenID configuration url
+func (c *Client) FetchIssuer(ctx context.Context, issuer string) (Configuration, error) {
+	config, err := c.Fetch(ctx, ConfigurationURL(issuer))
+	if err != nil {
+		return config, err
+	}
+	if config.Issuer != issuer {
+		return config, fmt.Errorf("openid configuration issuer %s does not match %s", config.Issuer, issuer)
+	}
+
+	return config, nil
+}
+
+// Fetch fetches the Configuration from the configuration url (e.g. the openid_configuration url of a
+// Dynamic Registration request), the issuer must have the same host as the configuration url
+func (c *Client) Fetch(ctx context.Context, configurationURL string) (Configuration, error) {
+	var config Configuration
+
+	configURL, err := url.Parse(configurationURL)
+	if err != nil {
+		return config, fmt.Errorf("invalid openid configuration url: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, configurationURL, nil)
+	if err != nil {
+		return config, fmt.Errorf("failed to create openid configuration request: %v", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	res, err := c.config.HTTPClient.Do(req)
+	if err != nil {
+		return config, fmt.Errorf("failed to fetch openid configuration: %v", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return config, fmt.Errorf("failed to fetch openid configuration: unexpected status %d", res.StatusCode)
+	}
+
+	body, err := httpx.ReadLimited(res.Body, c.config.MaxResponseSize)
+	if err != nil {
+		return config, fmt.Errorf("failed to read openid configuration: %w", err)
+	}
+	if err = json.Unmarshal(body, &config); err != nil {
+		return config, fmt.Errorf("failed to parse openid configuration: %v", err)
+	}
+
+	if err = validateConfiguration(config, configURL); err != nil {
+		return config, err
+	}
+
+	return config, nil
+}
+
+// validateConfiguration validates the required Configuration values
+func validateConfiguration(config Configuration, configURL *url.URL) error {
+	if config.Issuer == "" {
+		return fmt.Errorf("openid configuration missing issuer")
+	}
+	if config.AuthorizationEndpoint == "" {
+		return fmt.Errorf("openid configuration missing authorization_endpoint")
+	}
+	if config.JWKSURI == "" {
+		return fmt.Errorf("openid configuration missing jwks_uri")
+	}
+
+	issuerURL, err := url.Parse(config.Issuer)
+	if err != nil {
+		return fmt.Errorf("openid configuration issuer %s is not a url", config.Issuer)
+	}
+	if !strings.EqualFold(issuerURL.Host, configURL.Host) {
+		return fmt.Errorf(
+			"openid configuration issuer host %s does not match configuration url host %s",
+			issuerURL.Host, configURL.Host,
+		)
+	}
+
+	return nil
+}
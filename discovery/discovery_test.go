@@ -0,0 +1,129 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stevenweathers/peregrine-lti/peregrine"
+)
+
+const testConfiguration = `{
+	"issuer": "%[1]s",
+	"authorization_endpoint": "%[1]s/auth",
+	"jwks_uri": "%[1]s/jwks",
+	"token_endpoint": "%[1]s/token",
+	"scopes_supported": ["openid", "https://purl.imsglobal.org/spec/lti-ags/scope/score"],
+	"id_token_signing_alg_values_supported": ["RS256"],
+	"https://purl.imsglobal.org/spec/lti-platform-configuration": {
+		"product_family_code": "moodle",
+		"version": "4.1",
+		"messages_supported": [{"type": "LtiResourceLinkRequest"}, {"type": "LtiDeepLinkingRequest"}]
+	}
+}`
+
+func newTestServer(t *testing.T, body func(srvURL string) string) *httptest.Server {
+	t.Helper()
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != WellKnownPath {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprint(w, body(srv.URL))
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestFetchIssuer(t *testing.T) {
+	t.Parallel()
+	srv := newTestServer(t, func(srvURL string) string {
+		return fmt.Sprintf(testConfiguration, srvURL)
+	})
+
+	config, err := New(Config{}).FetchIssuer(context.Background(), srv.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if config.AuthorizationEndpoint != srv.URL+"/auth" || config.JWKSURI != srv.URL+"/jwks" {
+		t.Fatalf("unexpected configuration %+v", config)
+	}
+	if !config.SupportsScope("https://purl.imsglobal.org/spec/lti-ags/scope/score") {
+		t.Fatal("expected ags score scope to be supported")
+	}
+	if !config.SupportsMessageType("LtiDeepLinkingRequest") || config.SupportsMessageType("LtiSubmissionReviewRequest") {
+		t.Fatalf("unexpected supported message types %+v", config.LTIPlatformConfiguration.MessagesSupported)
+	}
+	if config.LTIPlatformConfiguration.ProductFamilyCode != "moodle" {
+		t.Fatalf("expected product family code moodle got %s", config.LTIPlatformConfiguration.ProductFamilyCode)
+	}
+}
+
+func TestFetchIssuerHostMismatch(t *testing.T) {
+	t.Parallel()
+	srv := newTestServer(t, func(srvURL string) string {
+		return fmt.Sprintf(testConfiguration, "https://evil.example")
+	})
+
+	_, err := New(Config{}).Fetch(context.Background(), ConfigurationURL(srv.URL))
+	if err == nil || !strings.Contains(err.Error(), "does not match configuration url host") {
+		t.Fatalf("expected error: %v", err)
+	}
+}
+
+func TestFetchMissingJWKSURI(t *testing.T) {
+	t.Parallel()
+	srv := newTestServer(t, func(srvURL string) string {
+		return fmt.Sprintf(`{"issuer": "%[1]s", "authorization_endpoint": "%[1]s/auth"}`, srvURL)
+	})
+
+	_, err := New(Config{}).Fetch(context.Background(), ConfigurationURL(srv.URL))
+	if err == nil || !strings.Contains(err.Error(), "missing jwks_uri") {
+		t.Fatalf("expected error: %v", err)
+	}
+}
+
+func TestFetchResponseTooLarge(t *testing.T) {
+	t.Parallel()
+	srv := newTestServer(t, func(srvURL string) string {
+		return fmt.Sprintf(testConfiguration, srvURL)
+	})
+
+	_, err := New(Config{MaxResponseSize: 64}).Fetch(context.Background(), ConfigurationURL(srv.URL))
+	if err == nil || !strings.Contains(err.Error(), "exceeds 64 bytes") {
+		t.Fatalf("expected error: %v", err)
+	}
+}
+
+func TestConfigurationApply(t *testing.T) {
+	t.Parallel()
+	config := Configuration{
+		Issuer:                "https://moodle.school.edu",
+		AuthorizationEndpoint: "https://moodle.school.edu/mod/lti/auth.php",
+		JWKSURI:               "https://moodle.school.edu/mod/lti/certs.php",
+		TokenEndpoint:         "https://moodle.school.edu/mod/lti/token.php",
+	}
+
+	platform, mismatches := config.Apply(peregrine.Platform{
+		Issuer:    "https://moodle.school.edu",
+		KeySetURL: "https://moodle.school.edu/old/certs.php",
+	})
+	if platform.AuthLoginURL != config.AuthorizationEndpoint || platform.TokenURL != config.TokenEndpoint {
+		t.Fatalf("expected empty platform urls to be filled got %+v", platform)
+	}
+	if platform.KeySetURL != "https://moodle.school.edu/old/certs.php" {
+		t.Fatalf("expected stored KeySetURL to be kept got %s", platform.KeySetURL)
+	}
+	if len(mismatches) != 1 || mismatches[0].Field != "KeySetURL" || mismatches[0].Discovered != config.JWKSURI {
+		t.Fatalf("expected KeySetURL mismatch got %v", mismatches)
+	}
+	if len(config.Validate(platform)) != 1 {
+		t.Fatalf("expected Validate to report the KeySetURL mismatch")
+	}
+}
@@ -1,11 +1,12 @@
 module github.com/stevenweathers/peregrine-lti
 
-go 1.20
+go 1.21
 
 require (
 	github.com/google/uuid v1.3.0
 	github.com/lestrrat-go/jwx/v2 v2.0.21
 	github.com/mitchellh/mapstructure v1.5.0
+	golang.org/x/crypto v0.31.0
 )
 
 require (
@@ -17,6 +18,5 @@ require (
 	github.com/lestrrat-go/iter v1.0.2 // indirect
 	github.com/lestrrat-go/option v1.0.1 // indirect
 	github.com/segmentio/asm v1.2.0 // indirect
-	golang.org/x/crypto v0.31.0 // indirect
 	golang.org/x/sys v0.28.0 // indirect
 )
@@ -0,0 +1,305 @@
+// Package groups reads the groups and group sets of a launch's context from the platform using the
+// 1EdTech Course Groups service, see peregrine.GroupsServiceClaim
+package groups
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/stevenweathers/peregrine-lti/internal/httpx"
+	"github.com/stevenweathers/peregrine-lti/peregrine"
+)
+
+// ScopeContextGroupReadOnly is the access token scope of the groups and group sets endpoints
+const ScopeContextGroupReadOnly = "https://purl.imsglobal.org/spec/lti-gs/scope/contextgroup.readonly"
+
+const (
+	groupContainerMediaType    = "application/vnd.ims.lti-gs.v1.contextgroupcontainer+json"
+	groupSetContainerMediaType = "application/vnd.ims.lti-gs.v1.contextgroupsetcontainer+json"
+	// DefaultMaxPages is the default maximum number of pages followed by a single list call
+	DefaultMaxPages = 100
+	// DefaultTimeout is the default timeout of a groups request
+	DefaultTimeout = httpx.DefaultTimeout
+	// DefaultMaxResponseSize is the default maximum size in bytes of a groups page
+	DefaultMaxResponseSize = httpx.DefaultMaxResponseSize
+)
+
+var (
+	// ErrServiceNotAvailable is returned when the launch's groups service claim has no url for the endpoint
+	ErrServiceNotAvailable = errors.New("GROUPS_SERVICE_NOT_AVAILABLE")
+	// ErrContextMismatch is returned when the platform responds with the groups of a context other than the launch's
+	ErrContextMismatch = errors.New("GROUPS_CONTEXT_MISMATCH")
+	// ErrTooManyPages is returned when a list call exceeds the Config MaxPages
+	ErrTooManyPages = errors.New("GROUPS_TOO_MANY_PAGES")
+)
+
+// Group is a group of users within a context
+type Group struct {
+	// ID is the platforms identifier of the group
+	ID string `json:"id"`
+	// Name is the display name of the group
+	Name string `json:"name"`
+	// Tag (OPTIONAL) is a tool or platform defined tag of the group
+	Tag string `json:"tag,omitempty"`
+	// SetIDs (OPTIONAL) are the IDs of the GroupSet's the group belongs to
+	SetIDs []string `json:"set_ids,omitempty"`
+}
+
+// GroupSet is a named set of groups within a context, e.g. the groups of a group assignment
+type GroupSet struct {
+	// ID is the platforms identifier of the group set
+	ID string `json:"id"`
+	// Name is the display name of the group set
+	Name string `json:"name"`
+}
+
+// Groups are the groups of a context
+type Groups struct {
+	// Context is the launch's peregrine.ContextClaim the groups belong to
+	Context peregrine.ContextClaim
+	// Groups are the groups of every page
+	Groups []Group
+}
+
+// GroupSets are the group sets of a context
+type GroupSets struct {
+	// Context is the launch's peregrine.ContextClaim the group sets belong to
+	Context peregrine.ContextClaim
+	// Sets are the group sets of every page
+	Sets []GroupSet
+}
+
+// ListOptions filter and page the groups
+type ListOptions struct {
+	// UserID (OPTIONAL) only lists the groups the user (the launch sub) is a member of
+	UserID string
+	// Limit (OPTIONAL) is the page size requested from the platform, the platform may return fewer
+	Limit int
+}
+
+// Config holds all the configuration's for Client
+type Config struct {
+	// HTTPClient (OPTIONAL) is the client used to call the platform, defaults to a client with DefaultTimeout
+	HTTPClient *http.Client
+	// MaxResponseSize (OPTIONAL) is the maximum size in bytes of a groups page, defaults to DefaultMaxResponseSize
+	MaxResponseSize int64
+	// MaxPages (OPTIONAL) is the maximum number of pages followed by a single list call, defaults to DefaultMaxPages
+	MaxPages int
+}
+
+// Client reads the groups and group sets of a context through the urls of the launch's peregrine.GroupsServiceClaim
+type Client struct {
+	config Config
+	tokens peregrine.AccessTokenSource
+}
+
+// New returns a new Client authorizing its requests with tokens
+func New(config Config, tokens peregrine.AccessTokenSource) *Client {
+	if config.HTTPClient == nil {
+		config.HTTPClient = httpx.NewClient()
+	}
+	if config.MaxResponseSize == 0 {
+		config.MaxResponseSize = DefaultMaxResponseSize
+	}
+	if config.MaxPages == 0 {
+		config.MaxPages = DefaultMaxPages
+	}
+
+	return &Client{
+		config: config,
+		tokens: tokens,
+	}
+}
+
+// groupContainer is a page of the groups endpoint
+type groupContainer struct {
+	Context peregrine.ContextClaim `json:"context"`
+	Groups  []Group                `json:"groups"`
+}
+
+// groupSetContainer is a page of the group sets endpoint
+type groupSetContainer struct {
+	Context peregrine.ContextClaim `json:"context"`
+	Sets    []GroupSet             `json:"sets"`
+}
+
+// ListGroups returns the groups of the launch's context following every page
+func (c *Client) ListGroups(
+	ctx context.Context, registration peregrine.Registration, claims peregrine.LTI1p3Claims, opts ListOptions,
+) (Groups, error) {
+	groups := Groups{Context: claims.Context}
+	if claims.GroupsService.ContextGroupsURL == "" {
+		return groups, fmt.Errorf("context groups url: %w", ErrServiceNotAvailable)
+	}
+
+	pageURL, err := listURL(claims.GroupsService.ContextGroupsURL, opts)
+	if err != nil {
+		return groups, err
+	}
+
+	err = c.list(ctx, registration, claims.Context, pageURL, groupContainerMediaType,
+		func(dec *json.Decoder) (peregrine.ContextClaim, error) {
+			var page groupContainer
+			err := dec.Decode(&page)
+			groups.Groups = append(groups.Groups, page.Groups...)
+			return page.Context, err
+		})
+
+	return groups, err
+}
+
+// ListGroupSets returns the group sets of the launch's context following every page
+func (c *Client) ListGroupSets(
+	ctx context.Context, registration peregrine.Registration, claims peregrine.LTI1p3Claims, opts ListOptions,
+) (GroupSets, error) {
+	sets := GroupSets{Context: claims.Context}
+	if claims.GroupsService.ContextGroupSetsURL == "" {
+		return sets, fmt.Errorf("context group sets url: %w", ErrServiceNotAvailable)
+	}
+
+	// the user filter only applies to groups
+	pageURL, err := listURL(claims.GroupsService.ContextGroupSetsURL, ListOptions{Limit: opts.Limit})
+	if err != nil {
+		return sets, err
+	}
+
+	err = c.list(ctx, registration, claims.Context, pageURL, groupSetContainerMediaType,
+		func(dec *json.Decoder) (peregrine.ContextClaim, error) {
+			var page groupSetContainer
+			err := dec.Decode(&page)
+			sets.Sets = append(sets.Sets, page.Sets...)
+			return page.Context, err
+		})
+
+	return sets, err
+}
+
+// list gets every page starting from pageURL following the Link rel="next" header,
+// decodePage decodes a page returning the context it belongs to
+func (c *Client) list(
+	ctx context.Context, registration peregrine.Registration, launchContext peregrine.ContextClaim,
+	pageURL string, mediaType string, decodePage func(dec *json.Decoder) (peregrine.ContextClaim, error),
+) error {
+	token, err := c.tokens.AccessToken(ctx, registration, []string{ScopeContextGroupReadOnly})
+	if err != nil {
+		return fmt.Errorf("failed to get groups access token: %v", err)
+	}
+
+	for pages := 0; pageURL != ""; pages++ {
+		if pages == c.config.MaxPages {
+			return fmt.Errorf("more than %d pages: %w", c.config.MaxPages, ErrTooManyPages)
+		}
+
+		pageURL, err = c.getPage(ctx, token, launchContext, pageURL, mediaType, decodePage)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getPage gets and decodes a single page returning the url of the next page, empty when it is the last page
+func (c *Client) getPage(
+	ctx context.Context, token string, launchContext peregrine.ContextClaim,
+	pageURL string, mediaType string, decodePage func(dec *json.Decoder) (peregrine.ContextClaim, error),
+) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create groups request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", mediaType)
+
+	res, err := c.config.HTTPClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to call groups endpoint: %v", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("groups endpoint responded with status %d", res.StatusCode)
+	}
+
+	body, err := httpx.ReadLimited(res.Body, c.config.MaxResponseSize)
+	if err != nil {
+		return "", fmt.Errorf("failed to read groups response: %w", err)
+	}
+	pageContext, err := decodePage(json.NewDecoder(bytes.NewReader(body)))
+	if err != nil {
+		return "", fmt.Errorf("failed to decode groups response: %v", err)
+	}
+	if pageContext.ID != "" && pageContext.ID != launchContext.ID {
+		return "", fmt.Errorf("context %s: %w", pageContext.ID, ErrContextMismatch)
+	}
+
+	return nextLink(req.URL, res.Header.Values("Link"))
+}
+
+// listURL adds the ListOptions query parameters to the endpoint url
+func listURL(endpoint string, opts ListOptions) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid groups url %s: %v", endpoint, err)
+	}
+
+	q := u.Query()
+	if opts.UserID != "" {
+		q.Set("user_id", opts.UserID)
+	}
+	if opts.Limit > 0 {
+		q.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	u.RawQuery = q.Encode()
+
+	return u.String(), nil
+}
+
+// nextLink returns the rel="next" url of the Link headers resolved against the request url
+func nextLink(requestURL *url.URL, links []string) (string, error) {
+	for _, header := range links {
+		for _, link := range strings.Split(header, ",") {
+			target, params, ok := strings.Cut(strings.TrimSpace(link), ";")
+			target = strings.TrimSpace(target)
+			if !ok || !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+
+			for _, param := range strings.Split(params, ";") {
+				name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+				if !strings.EqualFold(name, "rel") || !containsFold(strings.Fields(strings.Trim(value, `"`)), "next") {
+					continue
+				}
+
+				next, err := requestURL.Parse(strings.Trim(target, "<>"))
+				if err != nil {
+					return "", fmt.Errorf("invalid groups next page url: %v", err)
+				}
+				// the access token must not be sent to another origin
+				if next.Scheme != requestURL.Scheme || !strings.EqualFold(next.Host, requestURL.Host) {
+					return "", fmt.Errorf("groups next page url %s is not on the groups endpoint origin", next)
+				}
+				return next.String(), nil
+			}
+		}
+	}
+
+	return "", nil
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,166 @@
+package groups
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stevenweathers/peregrine-lti/peregrine"
+)
+
+const testContextID = "context-1"
+
+type mockTokenSource struct{}
+
+func (m mockTokenSource) AccessToken(ctx context.Context, registration peregrine.Registration, scopes []string) (
+	string, error,
+) {
+	return "test_token:" + scopes[0], nil
+}
+
+func newTestServer(t *testing.T, contextID string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test_token:"+ScopeContextGroupReadOnly {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/groups":
+			if r.Header.Get("Accept") != groupContainerMediaType {
+				w.WriteHeader(http.StatusNotAcceptable)
+				return
+			}
+			if r.URL.Query().Get("user_id") == "" && r.URL.Query().Get("page") == "" {
+				w.Header().Add("Link", `</groups?page=2>; rel="next", </groups>; rel="first"`)
+				_, _ = fmt.Fprintf(w, `{"context": {"id": %q}, "groups": [
+					{"id": "g1", "name": "Group 1", "set_ids": ["s1"]}]}`, contextID)
+				return
+			}
+			_, _ = fmt.Fprintf(w, `{"context": {"id": %q}, "groups": [
+				{"id": "g2", "name": "Group 2", "tag": "lab", "set_ids": ["s1"]}]}`, contextID)
+		case "/groups/sets":
+			if r.URL.Query().Get("limit") != "1" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			_, _ = fmt.Fprintf(w, `{"context": {"id": %q}, "sets": [{"id": "s1", "name": "Project Teams"}]}`, contextID)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func testClaims(srvURL string) peregrine.LTI1p3Claims {
+	return peregrine.LTI1p3Claims{
+		Context: peregrine.ContextClaim{ID: testContextID, Title: "Asgardian History"},
+		GroupsService: peregrine.GroupsServiceClaim{
+			Scope:               []string{ScopeContextGroupReadOnly},
+			ContextGroupsURL:    srvURL + "/groups",
+			ContextGroupSetsURL: srvURL + "/groups/sets",
+			ServiceVersions:     []string{"1.0"},
+		},
+	}
+}
+
+func TestListGroups(t *testing.T) {
+	t.Parallel()
+	srv := newTestServer(t, testContextID)
+	client := New(Config{}, mockTokenSource{})
+
+	groups, err := client.ListGroups(context.Background(), peregrine.Registration{}, testClaims(srv.URL), ListOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(groups.Groups) != 2 || groups.Groups[0].ID != "g1" || groups.Groups[1].Tag != "lab" {
+		t.Fatalf("expected groups of both pages got %+v", groups.Groups)
+	}
+	if groups.Context.Title != "Asgardian History" {
+		t.Fatalf("expected the launch context got %+v", groups.Context)
+	}
+
+	groups, err = client.ListGroups(context.Background(), peregrine.Registration{}, testClaims(srv.URL), ListOptions{
+		UserID: "thor",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(groups.Groups) != 1 || groups.Groups[0].ID != "g2" {
+		t.Fatalf("expected the users groups got %+v", groups.Groups)
+	}
+}
+
+func TestListGroupSets(t *testing.T) {
+	t.Parallel()
+	srv := newTestServer(t, testContextID)
+	client := New(Config{}, mockTokenSource{})
+
+	sets, err := client.ListGroupSets(context.Background(), peregrine.Registration{}, testClaims(srv.URL), ListOptions{
+		UserID: "thor",
+		Limit:  1,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(sets.Sets) != 1 || sets.Sets[0].Name != "Project Teams" || sets.Context.ID != testContextID {
+		t.Fatalf("unexpected group sets %+v", sets)
+	}
+}
+
+func TestListGroupsFailures(t *testing.T) {
+	t.Parallel()
+	srv := newTestServer(t, "someothercontext")
+	ctx := context.Background()
+
+	_, err := New(Config{}, mockTokenSource{}).ListGroups(ctx, peregrine.Registration{}, testClaims(srv.URL), ListOptions{})
+	if !errors.Is(err, ErrContextMismatch) {
+		t.Fatalf("expected error: %v", err)
+	}
+
+	_, err = New(Config{}, mockTokenSource{}).ListGroupSets(ctx, peregrine.Registration{}, peregrine.LTI1p3Claims{}, ListOptions{})
+	if !errors.Is(err, ErrServiceNotAvailable) {
+		t.Fatalf("expected error: %v", err)
+	}
+
+	srv = newTestServer(t, testContextID)
+	_, err = New(Config{MaxResponseSize: 16}, mockTokenSource{}).ListGroups(ctx, peregrine.Registration{}, testClaims(srv.URL), ListOptions{})
+	if err == nil || !strings.Contains(err.Error(), "exceeds 16 bytes") {
+		t.Fatalf("expected error: %v", err)
+	}
+
+	_, err = New(Config{MaxPages: 1}, mockTokenSource{}).ListGroups(ctx, peregrine.Registration{}, testClaims(srv.URL), ListOptions{})
+	if !errors.Is(err, ErrTooManyPages) {
+		t.Fatalf("expected error: %v", err)
+	}
+}
+
+func TestNextLink(t *testing.T) {
+	t.Parallel()
+	requestURL, _ := url.Parse("https://lms.example/groups?page=1")
+	tests := []struct {
+		links []string
+		next  string
+		err   bool
+	}{
+		{links: nil, next: ""},
+		{links: []string{`<https://lms.example/groups?page=2>; rel="next"`}, next: "https://lms.example/groups?page=2"},
+		{links: []string{`</groups?page=1>; rel="prev"`, `</groups?page=3>; rel="last next"`}, next: "https://lms.example/groups?page=3"},
+		{links: []string{`</groups?page=9>; rel="last"`}, next: ""},
+		{links: []string{`<https://evil.example/groups?page=2>; rel="next"`}, err: true},
+	}
+
+	for _, tt := range tests {
+		next, err := nextLink(requestURL, tt.links)
+		if (err != nil) != tt.err || next != tt.next {
+			t.Fatalf("expected %v next %q got %q: %v", tt.links, tt.next, next, err)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+// Package instrument defines the small tracing and metrics interfaces the launch flow is instrumented with,
+// see the otel sub package for an OpenTelemetry adapter
+package instrument
+
+import (
+	"context"
+)
+
+// Metric names recorded by the launch.Service
+const (
+	// MetricLaunches counts completed logins and callbacks by AttrFlow, AttrOutcome, AttrReason and AttrIssuer
+	MetricLaunches = "peregrine.launches"
+	// MetricLaunchDuration records the seconds taken by logins and callbacks by AttrFlow and AttrIssuer
+	MetricLaunchDuration = "peregrine.launch.duration"
+	// MetricJWKSCache counts platform key set lookups by AttrCacheResult and AttrIssuer
+	MetricJWKSCache = "peregrine.jwks.cache"
+	// MetricJWKSFetchDuration records the seconds taken to fetch a platform key set by AttrIssuer
+	MetricJWKSFetchDuration = "peregrine.jwks.fetch.duration"
+	// MetricLaunchesPurged counts expired launches deleted by the retention.Janitor by AttrPurgeReason
+	MetricLaunchesPurged = "peregrine.launches.purged"
+	// MetricPurgeDuration records the seconds taken by a retention.Janitor purge
+	MetricPurgeDuration = "peregrine.launches.purge.duration"
+)
+
+// Attribute keys set on the spans and metrics recorded by the launch.Service
+const (
+	// AttrFlow is either FlowLogin or FlowCallback
+	AttrFlow = "peregrine.flow"
+	// AttrOutcome is either OutcomeSuccess or OutcomeFailure
+	AttrOutcome = "peregrine.outcome"
+	// AttrReason is the launch.Stage the flow failed at
+	AttrReason = "peregrine.reason"
+	// AttrIssuer is the platform issuer
+	AttrIssuer = "peregrine.platform.issuer"
+	// AttrClientID is the registration client_id
+	AttrClientID = "peregrine.client_id"
+	// AttrCacheResult is either CacheHit or CacheMiss
+	AttrCacheResult = "peregrine.cache.result"
+	// AttrPurgeReason is either PurgeUnused or PurgeUsed
+	AttrPurgeReason = "peregrine.purge.reason"
+)
+
+// Attribute values set on the spans and metrics recorded by the launch.Service
+const (
+	FlowLogin      = "login"
+	FlowCallback   = "callback"
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+	CacheHit       = "hit"
+	CacheMiss      = "miss"
+	// IssuerUnknown is the AttrIssuer of a login or callback failing before its registration is found
+	IssuerUnknown = "unknown"
+	PurgeUnused   = "unused"
+	PurgeUsed     = "used"
+)
+
+// Attribute is a key value pair describing a Span or measurement
+type Attribute struct {
+	Key   string
+	Value string
+}
+
+// String returns an Attribute
+func String(key, value string) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Tracer starts spans
+type Tracer interface {
+	// Start starts a Span as a child of any span in ctx and returns a copy of ctx carrying the new Span
+	Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span)
+}
+
+// Span is a single traced operation
+type Span interface {
+	// SetAttributes sets attributes on the Span
+	SetAttributes(attrs ...Attribute)
+	// RecordError records err on the Span and marks it as failed
+	RecordError(err error)
+	// End completes the Span
+	End()
+}
+
+// Meter records measurements
+type Meter interface {
+	// Count adds value to the named counter
+	Count(ctx context.Context, name string, value int64, attrs ...Attribute)
+	// Record records value in the named histogram
+	Record(ctx context.Context, name string, value float64, attrs ...Attribute)
+}
+
+// Noop is a Tracer and Meter that records nothing, it is the default when no instrumentation is configured
+type Noop struct{}
+
+// Start returns ctx and a Span that records nothing
+func (Noop) Start(ctx context.Context, _ string, _ ...Attribute) (context.Context, Span) {
+	return ctx, noopSpan{}
+}
+
+// Count does nothing
+func (Noop) Count(context.Context, string, int64, ...Attribute) {}
+
+// Record does nothing
+func (Noop) Record(context.Context, string, float64, ...Attribute) {}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttributes(...Attribute) {}
+func (noopSpan) RecordError(error)          {}
+func (noopSpan) End()                       {}
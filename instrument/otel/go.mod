@@ -0,0 +1,22 @@
+module github.com/stevenweathers/peregrine-lti/instrument/otel
+
+go 1.21
+
+require (
+	github.com/stevenweathers/peregrine-lti v0.13.0
+	go.opentelemetry.io/otel v1.24.0
+	go.opentelemetry.io/otel/metric v1.24.0
+	go.opentelemetry.io/otel/sdk v1.24.0
+	go.opentelemetry.io/otel/sdk/metric v1.24.0
+	go.opentelemetry.io/otel/trace v1.24.0
+)
+
+require (
+	github.com/go-logr/logr v1.4.1 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	golang.org/x/sys v0.28.0 // indirect
+)
+
+// the adapter is developed against the peregrine-lti module in this repository, replace directives are
+// ignored by consumers which resolve the required peregrine-lti release tagged along with the adapter
+replace github.com/stevenweathers/peregrine-lti => ../..
@@ -0,0 +1,115 @@
+// Package otel adapts OpenTelemetry tracer and meter providers to the instrument interfaces,
+// it is a separate module so that OpenTelemetry is not a dependency of the peregrine-lti module
+package otel
+
+import (
+	"context"
+	"sync"
+
+	"github.com/stevenweathers/peregrine-lti/instrument"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ScopeName is the instrumentation scope name of the tracer and meter
+const ScopeName = "github.com/stevenweathers/peregrine-lti"
+
+// Tracer is an instrument.Tracer backed by an OpenTelemetry trace.Tracer
+type Tracer struct {
+	tracer trace.Tracer
+}
+
+// NewTracer returns a Tracer from the provider, e.g. otel.GetTracerProvider()
+func NewTracer(provider trace.TracerProvider) *Tracer {
+	return &Tracer{tracer: provider.Tracer(ScopeName)}
+}
+
+// Start starts an OpenTelemetry span
+func (t *Tracer) Start(ctx context.Context, name string, attrs ...instrument.Attribute) (
+	context.Context, instrument.Span,
+) {
+	ctx, s := t.tracer.Start(ctx, name, trace.WithAttributes(attributes(attrs)...))
+	return ctx, span{span: s}
+}
+
+type span struct {
+	span trace.Span
+}
+
+func (s span) SetAttributes(attrs ...instrument.Attribute) {
+	s.span.SetAttributes(attributes(attrs)...)
+}
+
+func (s span) RecordError(err error) {
+	s.span.RecordError(err)
+	s.span.SetStatus(codes.Error, err.Error())
+}
+
+func (s span) End() {
+	s.span.End()
+}
+
+// Meter is an instrument.Meter backed by an OpenTelemetry metric.Meter,
+// counters and histograms are created on first use
+type Meter struct {
+	meter metric.Meter
+
+	mu         sync.Mutex
+	counters   map[string]metric.Int64Counter
+	histograms map[string]metric.Float64Histogram
+}
+
+// NewMeter returns a Meter from the provider, e.g. otel.GetMeterProvider()
+func NewMeter(provider metric.MeterProvider) *Meter {
+	return &Meter{
+		meter:      provider.Meter(ScopeName),
+		counters:   make(map[string]metric.Int64Counter),
+		histograms: make(map[string]metric.Float64Histogram),
+	}
+}
+
+// Count adds value to the named Int64Counter
+func (m *Meter) Count(ctx context.Context, name string, value int64, attrs ...instrument.Attribute) {
+	m.mu.Lock()
+	counter, ok := m.counters[name]
+	if !ok {
+		var err error
+		counter, err = m.meter.Int64Counter(name)
+		if err != nil {
+			m.mu.Unlock()
+			return
+		}
+		m.counters[name] = counter
+	}
+	m.mu.Unlock()
+
+	counter.Add(ctx, value, metric.WithAttributes(attributes(attrs)...))
+}
+
+// Record records value in the named Float64Histogram
+func (m *Meter) Record(ctx context.Context, name string, value float64, attrs ...instrument.Attribute) {
+	m.mu.Lock()
+	histogram, ok := m.histograms[name]
+	if !ok {
+		var err error
+		histogram, err = m.meter.Float64Histogram(name, metric.WithUnit("s"))
+		if err != nil {
+			m.mu.Unlock()
+			return
+		}
+		m.histograms[name] = histogram
+	}
+	m.mu.Unlock()
+
+	histogram.Record(ctx, value, metric.WithAttributes(attributes(attrs)...))
+}
+
+func attributes(attrs []instrument.Attribute) []attribute.KeyValue {
+	kvs := make([]attribute.KeyValue, 0, len(attrs))
+	for _, a := range attrs {
+		kvs = append(kvs, attribute.String(a.Key, a.Value))
+	}
+	return kvs
+}
@@ -0,0 +1,85 @@
+package otel
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stevenweathers/peregrine-lti/instrument"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+var (
+	_ instrument.Tracer = (*Tracer)(nil)
+	_ instrument.Meter  = (*Meter)(nil)
+)
+
+func TestTracer(t *testing.T) {
+	t.Parallel()
+	recorder := tracetest.NewSpanRecorder()
+	tracer := NewTracer(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	_, span := tracer.Start(context.Background(), "peregrine.HandleOidcLogin",
+		instrument.String(instrument.AttrFlow, instrument.FlowLogin),
+	)
+	span.SetAttributes(instrument.String(instrument.AttrIssuer, "https://canvas.instructure.com"))
+	span.RecordError(errors.New("login failed"))
+	span.End()
+
+	ended := recorder.Ended()
+	if len(ended) != 1 || ended[0].Name() != "peregrine.HandleOidcLogin" {
+		t.Fatalf("expected one ended span got %v", ended)
+	}
+	attrs := attribute.NewSet(ended[0].Attributes()...)
+	if v, _ := attrs.Value(instrument.AttrFlow); v.AsString() != instrument.FlowLogin {
+		t.Fatalf("expected span %s attribute %s got %s", instrument.AttrFlow, instrument.FlowLogin, v.AsString())
+	}
+	if v, _ := attrs.Value(instrument.AttrIssuer); v.AsString() != "https://canvas.instructure.com" {
+		t.Fatalf("expected span %s attribute got %s", instrument.AttrIssuer, v.AsString())
+	}
+	if ended[0].Status().Code != codes.Error || len(ended[0].Events()) != 1 {
+		t.Fatalf("expected span error status and event got %+v", ended[0].Status())
+	}
+}
+
+func TestMeter(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	reader := sdkmetric.NewManualReader()
+	meter := NewMeter(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+
+	attr := instrument.String(instrument.AttrFlow, instrument.FlowCallback)
+	meter.Count(ctx, instrument.MetricLaunches, 1, attr)
+	meter.Count(ctx, instrument.MetricLaunches, 2, attr)
+	meter.Record(ctx, instrument.MetricLaunchDuration, 0.25, attr)
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(ctx, &rm); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(rm.ScopeMetrics) != 1 || rm.ScopeMetrics[0].Scope.Name != ScopeName {
+		t.Fatalf("expected metrics of scope %s got %+v", ScopeName, rm.ScopeMetrics)
+	}
+
+	metrics := map[string]metricdata.Aggregation{}
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		metrics[m.Name] = m.Data
+	}
+	sum, ok := metrics[instrument.MetricLaunches].(metricdata.Sum[int64])
+	if !ok || len(sum.DataPoints) != 1 || sum.DataPoints[0].Value != 3 {
+		t.Fatalf("expected %s counter of 3 got %+v", instrument.MetricLaunches, metrics[instrument.MetricLaunches])
+	}
+	if v, _ := sum.DataPoints[0].Attributes.Value(instrument.AttrFlow); v.AsString() != instrument.FlowCallback {
+		t.Fatalf("expected counter %s attribute got %s", instrument.AttrFlow, v.AsString())
+	}
+	histogram, ok := metrics[instrument.MetricLaunchDuration].(metricdata.Histogram[float64])
+	if !ok || len(histogram.DataPoints) != 1 || histogram.DataPoints[0].Count != 1 {
+		t.Fatalf("expected %s histogram of 1 got %+v",
+			instrument.MetricLaunchDuration, metrics[instrument.MetricLaunchDuration])
+	}
+}
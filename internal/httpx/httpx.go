@@ -0,0 +1,38 @@
+// Package httpx holds the http client defaults and limited body reads shared by the packages calling platform services
+package httpx
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const (
+	// DefaultTimeout is the default timeout of a platform request
+	DefaultTimeout = time.Second * 10
+	// DefaultMaxResponseSize is the default maximum size in bytes of a platform response
+	DefaultMaxResponseSize = 1 << 20
+)
+
+// ErrTooLarge is returned by ReadLimited when the body exceeds the maximum size
+var ErrTooLarge = errors.New("BODY_TOO_LARGE")
+
+// NewClient returns a new http client with DefaultTimeout
+func NewClient() *http.Client {
+	return &http.Client{Timeout: DefaultTimeout}
+}
+
+// ReadLimited reads the body up to maxSize bytes, a larger body is rejected with ErrTooLarge
+func ReadLimited(body io.Reader, maxSize int64) ([]byte, error) {
+	b, err := io.ReadAll(io.LimitReader(body, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(b)) > maxSize {
+		return nil, fmt.Errorf("exceeds %d bytes: %w", maxSize, ErrTooLarge)
+	}
+
+	return b, nil
+}
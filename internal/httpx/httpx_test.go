@@ -0,0 +1,48 @@
+package httpx
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestReadLimited(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		body    string
+		maxSize int64
+		errors  bool
+	}{
+		{name: "under limit", body: "abc", maxSize: 4},
+		{name: "at limit", body: "abcd", maxSize: 4},
+		{name: "over limit", body: "abcde", maxSize: 4, errors: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			b, err := ReadLimited(strings.NewReader(tt.body), tt.maxSize)
+			if tt.errors {
+				if !errors.Is(err, ErrTooLarge) {
+					t.Fatalf("expected error: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(b) != tt.body {
+				t.Fatalf("expected body %s got %s", tt.body, b)
+			}
+		})
+	}
+}
+
+func TestNewClient(t *testing.T) {
+	t.Parallel()
+	if c := NewClient(); c.Timeout != DefaultTimeout {
+		t.Fatalf("expected client timeout %s got %s", DefaultTimeout, c.Timeout)
+	}
+}
@@ -0,0 +1,111 @@
+package launch
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// ClaimDecoder decodes the raw value of an extension claim (e.g. a vendor specific claim like
+// https://www.instructure.com/placement) into a typed value
+type ClaimDecoder func(claim string, value interface{}) (interface{}, error)
+
+// RegisterClaimDecoder registers a ClaimDecoder for every id_token claim whose name starts with namespace,
+// when multiple namespaces match a claim the longest (most specific) namespace is used.
+// Decoded values are made available in HandleOidcCallbackResponse Extensions keyed by claim name.
+func (s *Service) RegisterClaimDecoder(namespace string, decoder ClaimDecoder) {
+	s.decodersMu.Lock()
+	defer s.decodersMu.Unlock()
+
+	s.claimDecoders[namespace] = decoder
+}
+
+// decodeExtensionClaims runs the registered ClaimDecoder's against the verified id_token claims
+func (s *Service) decodeExtensionClaims(claims map[string]interface{}) (map[string]interface{}, error) {
+	extensions := make(map[string]interface{})
+
+	s.decodersMu.RLock()
+	defer s.decodersMu.RUnlock()
+
+	if len(s.claimDecoders) == 0 {
+		return extensions, nil
+	}
+
+	// sort namespaces longest first so the most specific namespace wins
+	namespaces := make([]string, 0, len(s.claimDecoders))
+	for ns := range s.claimDecoders {
+		namespaces = append(namespaces, ns)
+	}
+	sort.Slice(namespaces, func(i, j int) bool {
+		return len(namespaces[i]) > len(namespaces[j])
+	})
+
+	for claim, value := range claims {
+		for _, ns := range namespaces {
+			if !strings.HasPrefix(claim, ns) {
+				continue
+			}
+			decoded, err := s.claimDecoders[ns](claim, value)
+			if err != nil {
+				return extensions, fmt.Errorf("failed to decode extension claim %s: %v", claim, err)
+			}
+			extensions[claim] = decoded
+			break
+		}
+	}
+
+	return extensions, nil
+}
+
+// Claim returns the named claim from the verified id_token decoded into T,
+// T may be the claims native type (e.g. string) or a struct using json tags for its fields
+func Claim[T any](resp HandleOidcCallbackResponse, name string) (T, error) {
+	var result T
+
+	value, ok := resp.RawClaims[name]
+	if !ok {
+		return result, fmt.Errorf("claim %s not found in id_token", name)
+	}
+
+	return decodeClaimValue[T](name, value)
+}
+
+// Extension returns the value decoded by a registered ClaimDecoder for the named claim
+func Extension[T any](resp HandleOidcCallbackResponse, name string) (T, bool) {
+	var result T
+
+	value, ok := resp.Extensions[name]
+	if !ok {
+		return result, false
+	}
+	result, ok = value.(T)
+
+	return result, ok
+}
+
+// DecodeClaim decodes a raw claim value into T, intended for use within a ClaimDecoder
+func DecodeClaim[T any](claim string, value interface{}) (T, error) {
+	return decodeClaimValue[T](claim, value)
+}
+
+func decodeClaimValue[T any](name string, value interface{}) (T, error) {
+	var result T
+
+	if v, ok := value.(T); ok {
+		return v, nil
+	}
+
+	cfg := &mapstructure.DecoderConfig{
+		Metadata: nil,
+		Result:   &result,
+		TagName:  "json",
+	}
+	decoder, _ := mapstructure.NewDecoder(cfg)
+	if err := decoder.Decode(value); err != nil {
+		return result, fmt.Errorf("failed to decode claim %s: %v", name, err)
+	}
+
+	return result, nil
+}
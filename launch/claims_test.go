@@ -0,0 +1,127 @@
+package launch
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stevenweathers/peregrine-lti/peregrine"
+)
+
+func TestClaim(t *testing.T) {
+	t.Parallel()
+	resp := HandleOidcCallbackResponse{
+		RawClaims: map[string]interface{}{
+			testPlacementClaim: "course_navigation",
+			toolPlatformClaim: map[string]interface{}{
+				"guid": testPlatformInstanceGUID,
+				"name": "Canvas",
+			},
+		},
+	}
+
+	placement, err := Claim[string](resp, testPlacementClaim)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if placement != "course_navigation" {
+		t.Fatalf("expected %s to equal course_navigation got %s", testPlacementClaim, placement)
+	}
+
+	toolPlatform, err := Claim[peregrine.PlatformInstanceClaim](resp, toolPlatformClaim)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if toolPlatform.GUID != testPlatformInstanceGUID {
+		t.Fatalf("expected tool_platform guid %s to equal %s", toolPlatform.GUID, testPlatformInstanceGUID)
+	}
+
+	_, err = Claim[string](resp, "https://www.instructure.com/missing")
+	if err == nil || !strings.Contains(err.Error(), "not found in id_token") {
+		t.Fatalf("expected error: %v", err)
+	}
+
+	_, err = Claim[int](resp, testPlacementClaim)
+	if err == nil || !strings.Contains(err.Error(), "failed to decode claim") {
+		t.Fatalf("expected error: %v", err)
+	}
+}
+
+func TestDecodeExtensionClaims(t *testing.T) {
+	t.Parallel()
+	launchSvc := New(Config{}, &mockStoreSvc{})
+	launchSvc.RegisterClaimDecoder("https://www.instructure.com/", func(claim string, value interface{}) (interface{}, error) {
+		return "generic", nil
+	})
+	launchSvc.RegisterClaimDecoder(testPlacementClaim, func(claim string, value interface{}) (interface{}, error) {
+		return DecodeClaim[string](claim, value)
+	})
+
+	extensions, err := launchSvc.decodeExtensionClaims(map[string]interface{}{
+		testPlacementClaim:                         "course_navigation",
+		"https://www.instructure.com/other":        "value",
+		"https://purl.imsglobal.org/spec/lti/none": "ignored",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if extensions[testPlacementClaim] != "course_navigation" {
+		t.Fatalf("expected most specific decoder to be used for %s", testPlacementClaim)
+	}
+	if extensions["https://www.instructure.com/other"] != "generic" {
+		t.Fatalf("expected namespace decoder to be used for https://www.instructure.com/other")
+	}
+	if _, ok := extensions["https://purl.imsglobal.org/spec/lti/none"]; ok {
+		t.Fatalf("expected claim without registered decoder to not be decoded")
+	}
+}
+
+func TestDecodeExtensionClaimsFailure(t *testing.T) {
+	t.Parallel()
+	launchSvc := New(Config{}, &mockStoreSvc{})
+	launchSvc.RegisterClaimDecoder(testExtensionNamespace, func(claim string, value interface{}) (interface{}, error) {
+		return nil, fmt.Errorf("forced decoder failure")
+	})
+
+	_, err := launchSvc.decodeExtensionClaims(map[string]interface{}{
+		testPlacementClaim: "course_navigation",
+	})
+	if err == nil || !strings.Contains(err.Error(), "failed to decode extension claim") {
+		t.Fatalf("expected error: %v", err)
+	}
+}
+
+func TestHandleOidcCallbackGroupsServiceClaim(t *testing.T) {
+	t.Parallel()
+	launchSvc := New(Config{
+		JWTKeySecret: testJWTSecret,
+		Issuer:       testIssuer,
+		CallbackURL:  testCallbackURL,
+	}, &mockStoreSvc{})
+
+	state, err := createLaunchState(testIssuer, testJWTSecret, testClientID, testLaunchID, testTargetLinkURI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := launchSvc.HandleOidcCallback(context.Background(), peregrine.OIDCAuthenticationResponse{
+		State: state,
+		IDToken: signTestIDToken(t, testIDTokenBuilder().Claim(
+			"https://purl.imsglobal.org/spec/lti-gs/claim/groupsservice",
+			map[string]interface{}{
+				"scope":                  []string{"https://purl.imsglobal.org/spec/lti-gs/scope/contextgroup.readonly"},
+				"context_groups_url":     "https://canvas.instructure.com/api/lti/courses/1/groups",
+				"context_group_sets_url": "https://canvas.instructure.com/api/lti/courses/1/group_sets",
+				"service_versions":       []string{"1.0"},
+			},
+		)),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Claims.GroupsService.ContextGroupsURL != "https://canvas.instructure.com/api/lti/courses/1/groups" ||
+		resp.Claims.GroupsService.ContextGroupSetsURL != "https://canvas.instructure.com/api/lti/courses/1/group_sets" {
+		t.Fatalf("unexpected groups service claim %+v", resp.Claims.GroupsService)
+	}
+}
@@ -0,0 +1,187 @@
+package launch
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwe"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/stevenweathers/peregrine-lti/peregrine"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	encryptedRegistrationIDClaim = "lti_registration_id"
+	encryptedDeploymentIDClaim   = "lti_deployment_id"
+	encryptedNonceClaim          = "lti_nonce"
+)
+
+// isEncryptedLaunchState reports whether the state is a JWE created by createEncryptedLaunchState,
+// compact JWE serialization has five segments compared to the three of the JWS state.
+// Encrypted states are only accepted when DeferLaunchCreation or Stateless is configured
+func (s *Service) isEncryptedLaunchState(state string) bool {
+	if !s.config.DeferLaunchCreation && !s.config.Stateless {
+		return false
+	}
+
+	return strings.Count(state, ".") == 4
+}
+
+// encryptedStateKeyInfo is the HKDF info label of the encrypted state key, distinguishing it from
+// the JWTKeySecret used directly as the HS256 state signing key
+const encryptedStateKeyInfo = "peregrine-lti encrypted launch state A256GCM"
+
+// encryptedStateKey derives the A256GCM content encryption key from the tools JWTKeySecret with HKDF
+func encryptedStateKey(jwtKeySecret string) ([]byte, error) {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, []byte(jwtKeySecret), nil, []byte(encryptedStateKeyInfo)), key); err != nil {
+		return nil, fmt.Errorf("failed to derive encrypted launch state key: %v", err)
+	}
+
+	return key, nil
+}
+
+// createEncryptedLaunchState builds an encrypted jwt carrying the launch id, registration, platform deployment id,
+// target_link_uri and nonce of a login whose peregrine.Launch is not created at login (see Config DeferLaunchCreation and Stateless),
+// the client_id is set as the (authenticated but
+// unencrypted) key id header so the tool identity can be resolved before decrypting
+func createEncryptedLaunchState(
+	toolCfg ToolConfig, registration peregrine.Registration, platformDeploymentID string, targetLinkURI string,
+	launchID uuid.UUID, nonce uuid.UUID,
+) (string, error) {
+	tok, err := jwt.NewBuilder().
+		Issuer(toolCfg.Issuer).
+		IssuedAt(time.Now()).
+		Expiration(time.Now().Add(DefaultStateTTL)).
+		Claim(launchIDClaim, launchID.String()).
+		Claim(encryptedRegistrationIDClaim, registration.ID.String()).
+		Claim(encryptedDeploymentIDClaim, platformDeploymentID).
+		Claim(encryptedNonceClaim, nonce.String()).
+		Claim(targetLinkURIClaim, targetLinkURI).
+		Build()
+	if err != nil {
+		return "", fmt.Errorf("failed to create encrypted launch state jwt: %v", err)
+	}
+
+	payload, err := json.Marshal(tok)
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize encrypted launch state jwt: %v", err)
+	}
+
+	headers := jwe.NewHeaders()
+	if err = headers.Set(jwe.KeyIDKey, registration.ClientID); err != nil {
+		return "", fmt.Errorf("failed to set encrypted launch state key id: %v", err)
+	}
+
+	key, err := encryptedStateKey(toolCfg.JWTKeySecret)
+	if err != nil {
+		return "", err
+	}
+
+	state, err := jwe.Encrypt(payload,
+		jwe.WithKey(jwa.DIRECT, key),
+		jwe.WithContentEncryption(jwa.A256GCM),
+		jwe.WithProtectedHeaders(headers),
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt launch state: %v", err)
+	}
+
+	return string(state), nil
+}
+
+// parseEncryptedLaunchState decrypts the state created by createEncryptedLaunchState returning the
+// (not yet persisted) peregrine.Launch and ToolConfig of the login
+func (s *Service) parseEncryptedLaunchState(ctx context.Context, state string) (peregrine.Launch, ToolConfig, error) {
+	var launch peregrine.Launch
+	var toolCfg ToolConfig
+
+	msg, err := jwe.Parse([]byte(state))
+	if err != nil {
+		return launch, toolCfg, newError(StageValidateState, fmt.Errorf("failed to validate state: %v", err))
+	}
+	clientID := msg.ProtectedHeaders().KeyID()
+	if clientID == "" {
+		return launch, toolCfg, newError(StageValidateState, fmt.Errorf("failed to validate state: missing kid"))
+	}
+
+	registration, toolCfg, err := s.stateToolConfig(ctx, clientID)
+	if err != nil {
+		return launch, toolCfg, err
+	}
+	launch.Registration = &registration
+
+	key, err := encryptedStateKey(toolCfg.JWTKeySecret)
+	if err != nil {
+		return launch, toolCfg, newError(StageValidateState, fmt.Errorf("failed to validate state: %v", err))
+	}
+	payload, err := jwe.Decrypt([]byte(state), jwe.WithKey(jwa.DIRECT, key))
+	if err != nil {
+		return launch, toolCfg, newError(StageValidateState, fmt.Errorf("failed to validate state: %v", err))
+	}
+	tok, err := jwt.Parse(payload, jwt.WithVerify(false), jwt.WithIssuer(toolCfg.Issuer),
+		jwt.WithClaimValue(encryptedRegistrationIDClaim, registration.ID.String()),
+		jwt.WithRequiredClaim(launchIDClaim),
+		jwt.WithRequiredClaim(encryptedNonceClaim),
+	)
+	if err != nil {
+		return launch, toolCfg, newError(StageValidateState, fmt.Errorf("failed to validate state: %v", err))
+	}
+
+	claims := tok.PrivateClaims()
+	launchID, _ := claims[launchIDClaim].(string)
+	launch.ID, err = uuid.Parse(launchID)
+	if err != nil {
+		return launch, toolCfg, newError(StageValidateState, fmt.Errorf(
+			"failed to validate state: %s claim not a uuid", launchIDClaim,
+		))
+	}
+	nonce, _ := claims[encryptedNonceClaim].(string)
+	launch.Nonce, err = uuid.Parse(nonce)
+	if err != nil {
+		return launch, toolCfg, newError(StageValidateState, fmt.Errorf(
+			"failed to validate state: %s claim not a uuid", encryptedNonceClaim,
+		))
+	}
+	if platformDeploymentID, _ := claims[encryptedDeploymentIDClaim].(string); platformDeploymentID != "" {
+		launch.Deployment = &peregrine.Deployment{
+			Registration: &peregrine.Registration{
+				ID: registration.ID,
+			},
+			PlatformDeploymentID: platformDeploymentID,
+		}
+	}
+
+	launch.TargetLinkURI, _ = claims[targetLinkURIClaim].(string)
+
+	return launch, toolCfg, nil
+}
+
+// stateToolConfig returns the peregrine.Registration and ToolConfig of the client_id set as the key id header
+// of a state, the ToolConfig JWTKeySecret is then used to verify (or decrypt) the state
+func (s *Service) stateToolConfig(ctx context.Context, clientID string) (peregrine.Registration, ToolConfig, error) {
+	repoCtx, end := s.startRepoSpan(ctx, "GetRegistrationByClientID")
+	registration, err := s.dataSvc.GetRegistrationByClientID(repoCtx, clientID)
+	end(err)
+	if err != nil {
+		return registration, ToolConfig{}, newError(StageRegistrationLookup, fmt.Errorf(
+			"failed to get registration by client id %s: %v", clientID, err,
+		))
+	}
+
+	toolCfg, err := s.resolveToolConfig(ctx, registration)
+	if err != nil {
+		return registration, toolCfg, newError(StageToolConfig, fmt.Errorf(
+			"failed to resolve tool config for client id %s: %v", clientID, err,
+		))
+	}
+
+	return registration, toolCfg, nil
+}
@@ -0,0 +1,89 @@
+package launch
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/stevenweathers/peregrine-lti/peregrine"
+)
+
+// EntitlementDecision is the outcome of an EntitlementChecker
+type EntitlementDecision string
+
+const (
+	// EntitlementAllow allows the launch
+	EntitlementAllow EntitlementDecision = "allow"
+	// EntitlementDeny refuses the launch with ErrLaunchNotEntitled
+	EntitlementDeny EntitlementDecision = "deny"
+	// EntitlementAllowWithFlags allows the launch in a degraded mode described by the Entitlement Flags
+	// (e.g. read only or over seat limit)
+	EntitlementAllowWithFlags EntitlementDecision = "allow_with_flags"
+)
+
+// Entitlement is the licensing decision for a launch
+type Entitlement struct {
+	// Decision (REQUIRED) is whether the launch is allowed, denied or allowed with flags
+	Decision EntitlementDecision
+	// Flags (OPTIONAL) are tool defined flags the tool uses to degrade the launch, e.g. "seat_limit_exceeded"
+	Flags []string
+	// Reason (OPTIONAL) is a human-readable reason for the decision, e.g. to show on an upgrade page
+	Reason string
+}
+
+// HasFlag reports whether the Entitlement carries the flag
+func (e Entitlement) HasFlag(flag string) bool {
+	return containsString(e.Flags, flag)
+}
+
+// EntitlementRequest is the launch an EntitlementChecker decides on
+type EntitlementRequest struct {
+	// Registration is the launch's peregrine.Registration
+	Registration peregrine.Registration
+	// Deployment is the launch's peregrine.Deployment
+	Deployment *peregrine.Deployment
+	// PlatformInstance is the launch's peregrine.PlatformInstance, nil when the id_token has no tool_platform guid
+	PlatformInstance *peregrine.PlatformInstance
+	// Claims are the verified id_token claims
+	Claims peregrine.LTI1p3Claims
+}
+
+// EntitlementChecker decides whether a verified launch is licensed
+type EntitlementChecker interface {
+	// CheckEntitlement returns the Entitlement of the launch, an error fails the launch
+	CheckEntitlement(ctx context.Context, req EntitlementRequest) (Entitlement, error)
+}
+
+// EntitlementCheckerFunc is an adapter to allow the use of ordinary functions as an EntitlementChecker
+type EntitlementCheckerFunc func(ctx context.Context, req EntitlementRequest) (Entitlement, error)
+
+// CheckEntitlement calls f(ctx, req)
+func (f EntitlementCheckerFunc) CheckEntitlement(ctx context.Context, req EntitlementRequest) (Entitlement, error) {
+	return f(ctx, req)
+}
+
+// checkEntitlement consults the configured EntitlementChecker setting the callback response Entitlement
+func (s *Service) checkEntitlement(ctx context.Context, resp *HandleOidcCallbackResponse) error {
+	if s.config.EntitlementChecker == nil {
+		return nil
+	}
+
+	entitlement, err := s.config.EntitlementChecker.CheckEntitlement(ctx, EntitlementRequest{
+		Registration:     *resp.Launch.Registration,
+		Deployment:       resp.Launch.Deployment,
+		PlatformInstance: resp.Launch.PlatformInstance,
+		Claims:           resp.Claims,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to check entitlement: %v", err)
+	}
+	resp.Entitlement = &entitlement
+
+	switch entitlement.Decision {
+	case EntitlementAllow, EntitlementAllowWithFlags:
+		return nil
+	case EntitlementDeny:
+		return fmt.Errorf("launch denied: %s: %w", entitlement.Reason, ErrLaunchNotEntitled)
+	default:
+		return fmt.Errorf("unknown entitlement decision %q", entitlement.Decision)
+	}
+}
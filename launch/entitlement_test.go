@@ -0,0 +1,111 @@
+package launch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stevenweathers/peregrine-lti/peregrine"
+)
+
+func TestHandleOidcCallbackEntitlement(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		entitlement Entitlement
+		checkErr    error
+		err         error
+		failed      bool
+	}{
+		{
+			name:        "allow",
+			entitlement: Entitlement{Decision: EntitlementAllow},
+		},
+		{
+			name:        "allow with flags",
+			entitlement: Entitlement{Decision: EntitlementAllowWithFlags, Flags: []string{"seat_limit_exceeded"}},
+		},
+		{
+			name:        "deny",
+			entitlement: Entitlement{Decision: EntitlementDeny, Reason: "license expired"},
+			err:         ErrLaunchNotEntitled,
+			failed:      true,
+		},
+		{
+			name:        "unknown decision",
+			entitlement: Entitlement{Decision: "maybe"},
+			failed:      true,
+		},
+		{
+			name:     "checker failure",
+			checkErr: fmt.Errorf("license service unavailable"),
+			failed:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var req EntitlementRequest
+			launchSvc := New(Config{
+				JWTKeySecret: testJWTSecret,
+				Issuer:       testIssuer,
+				CallbackURL:  testCallbackURL,
+				EntitlementChecker: EntitlementCheckerFunc(func(ctx context.Context, r EntitlementRequest) (Entitlement, error) {
+					req = r
+					return tt.entitlement, tt.checkErr
+				}),
+			}, &mockStoreSvc{})
+
+			state, err := createLaunchState(testIssuer, testJWTSecret, testClientID, testLaunchID, testTargetLinkURI)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := launchSvc.HandleOidcCallback(context.Background(), peregrine.OIDCAuthenticationResponse{
+				State: state,
+				IDToken: signTestIDToken(t, testIDTokenBuilder().Claim(
+					"https://purl.imsglobal.org/spec/lti/claim/tool_platform",
+					map[string]interface{}{"guid": testPlatformInstanceGUID},
+				)),
+			})
+
+			if tt.failed {
+				if ErrorStage(err) != StageEntitlement || (tt.err != nil && !errors.Is(err, tt.err)) {
+					t.Fatalf("expected error: %v", err)
+				}
+				if resp.Launch.Used != nil {
+					t.Fatal("expected failed launch to not be used")
+				}
+				if tt.checkErr != nil {
+					return
+				}
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if resp.Entitlement == nil || resp.Entitlement.Decision != tt.entitlement.Decision {
+				t.Fatalf("expected entitlement decision %s got %v", tt.entitlement.Decision, resp.Entitlement)
+			}
+			if req.Registration.ID != testRegistrationID || req.Deployment == nil || req.Deployment.ID != testDeploymentID {
+				t.Fatalf("expected entitlement request registration and deployment got %+v", req)
+			}
+			if req.PlatformInstance == nil || req.PlatformInstance.ID != testPlatformInstanceID {
+				t.Fatalf("expected entitlement request platform instance got %+v", req.PlatformInstance)
+			}
+			if req.Claims.DeploymentID != testPlatformDeploymentID {
+				t.Fatalf("expected entitlement request claims deployment_id %s got %s",
+					testPlatformDeploymentID, req.Claims.DeploymentID)
+			}
+		})
+	}
+}
+
+func TestEntitlementHasFlag(t *testing.T) {
+	t.Parallel()
+	e := Entitlement{Decision: EntitlementAllowWithFlags, Flags: []string{"read_only"}}
+	if !e.HasFlag("read_only") || e.HasFlag("seat_limit_exceeded") {
+		t.Fatalf("unexpected flags %v", e.Flags)
+	}
+}
@@ -0,0 +1,102 @@
+package launch
+
+import (
+	"errors"
+
+	"github.com/stevenweathers/peregrine-lti/peregrine"
+)
+
+var (
+	// ErrRegistrationNotFound is returned when no peregrine.Registration matches the login request,
+	// it is peregrine.ErrRegistrationNotFound so that data store errors wrapping it match either
+	ErrRegistrationNotFound = peregrine.ErrRegistrationNotFound
+	// ErrAmbiguousRegistration is returned when the login request omits client_id and the issuer
+	// (and lti_deployment_id if provided) matches more than one peregrine.Registration
+	ErrAmbiguousRegistration = errors.New("AMBIGUOUS_REGISTRATION")
+	// ErrRateLimited is returned when the login request is rejected by the configured RateLimiter
+	ErrRateLimited = errors.New("RATE_LIMITED")
+	// ErrNonceReplayed is returned when the id_token nonce has already been used, see NonceStore
+	ErrNonceReplayed = errors.New("NONCE_REPLAYED")
+	// ErrTargetLinkURINotAllowed is returned when the target_link_uri is not in the tools AllowedTargetLinkURIs
+	ErrTargetLinkURINotAllowed = errors.New("TARGET_LINK_URI_NOT_ALLOWED")
+	// ErrConflictingLoginParam is returned by ParseLoginRequest when a parameter is sent with differing values
+	ErrConflictingLoginParam = errors.New("CONFLICTING_LOGIN_PARAM")
+	// ErrLoginRequestTooLarge is returned by ParseLoginRequest when the request body exceeds the maximum size
+	ErrLoginRequestTooLarge = errors.New("LOGIN_REQUEST_TOO_LARGE")
+	// ErrRegistrationInactive is returned when the launch's peregrine.Registration is suspended or revoked
+	ErrRegistrationInactive = errors.New("REGISTRATION_INACTIVE")
+	// ErrDeploymentInactive is returned when the launch's peregrine.Deployment is suspended or revoked
+	ErrDeploymentInactive = errors.New("DEPLOYMENT_INACTIVE")
+	// ErrDeploymentNotAllowed is returned when the launch's deployment_id is not in the
+	// peregrine.Registration DeploymentAllowlist
+	ErrDeploymentNotAllowed = errors.New("DEPLOYMENT_NOT_ALLOWED")
+	// ErrLaunchNotEntitled is returned when the EntitlementChecker denies the launch
+	ErrLaunchNotEntitled = errors.New("LAUNCH_NOT_ENTITLED")
+)
+
+// Stage identifies the step of the launch flow
+type Stage string
+
+// Launch flow stages of HandleOidcLogin in the order they first occur, see Error
+const (
+	StageBeforeLogin        Stage = "before_login"
+	StageLoginParams        Stage = "login_params"
+	StageRateLimit          Stage = "rate_limit"
+	StageRegistrationLookup Stage = "registration_lookup"
+	StageRegistrationStatus Stage = "registration_status"
+	StageIssuerMismatch     Stage = "issuer_mismatch"
+	StageRegistrationHook   Stage = "registration_hook"
+	StageDeploymentStatus   Stage = "deployment_status"
+	StageDeploymentUpsert   Stage = "deployment_upsert"
+	StageToolConfig         Stage = "tool_config"
+	StageTargetLinkURI      Stage = "target_link_uri"
+	StageCreateLaunch       Stage = "create_launch"
+	StageCreateState        Stage = "create_state"
+)
+
+// Launch flow stages of HandleOidcCallback in the order they first occur, the callback also fails with
+// the login stages StageRegistrationLookup, StageToolConfig, StageRegistrationStatus, StageTargetLinkURI,
+// StageDeploymentStatus, StageDeploymentUpsert and StageCreateLaunch, see Error
+const (
+	StageValidateState          Stage = "validate_state"
+	StageGetLaunch              Stage = "get_launch"
+	StageKeySet                 Stage = "key_set"
+	StageIDToken                Stage = "id_token"
+	StageNonce                  Stage = "nonce"
+	StageExtensionClaims        Stage = "extension_claims"
+	StageIDTokenHook            Stage = "id_token_hook"
+	StagePlatformInstanceUpsert Stage = "platform_instance_upsert"
+	StageEntitlement            Stage = "entitlement"
+	StageLaunchData             Stage = "launch_data"
+	StageUpdateLaunch           Stage = "update_launch"
+)
+
+// Error is returned by the Service handlers identifying the Stage of the launch flow that failed
+type Error struct {
+	// Stage is the step of the launch flow that failed
+	Stage Stage
+	// Err is the underlying error
+	Err error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// ErrorStage returns the Stage of the launch flow the error occurred in, empty if err is not an Error
+func ErrorStage(err error) Stage {
+	var launchErr *Error
+	if errors.As(err, &launchErr) {
+		return launchErr.Stage
+	}
+
+	return ""
+}
+
+func newError(stage Stage, err error) *Error {
+	return &Error{Stage: stage, Err: err}
+}
@@ -0,0 +1,87 @@
+package launch
+
+import (
+	"context"
+
+	"github.com/stevenweathers/peregrine-lti/peregrine"
+)
+
+// BeforeLoginHook is run before the login request is validated, returning an error rejects the login
+type BeforeLoginHook func(ctx context.Context, params peregrine.OIDCLoginRequestParams) error
+
+// RegistrationHook is run once the peregrine.Registration of the login request is found,
+// returning an error rejects the login (e.g. feature gating or tenant provisioning)
+type RegistrationHook func(ctx context.Context, registration peregrine.Registration) error
+
+// IDTokenHook is run once the id_token is verified and before any launch data is persisted,
+// returning an error vetoes the launch, with Config DeferLaunchCreation the launch has not yet been created (no ID)
+type IDTokenHook func(ctx context.Context, launch peregrine.Launch, claims peregrine.LTI1p3Claims) error
+
+// LaunchHook is run after the launch is completed and marked used
+type LaunchHook func(ctx context.Context, resp HandleOidcCallbackResponse)
+
+// FailureHook is run when either handler fails with the Error identifying the failed Stage
+type FailureHook func(ctx context.Context, err *Error)
+
+// Hooks are the callbacks run at points in the launch flow, hooks of each kind are run in order
+// and the first error returned by a vetoing hook stops the launch
+type Hooks struct {
+	// BeforeLogin (OPTIONAL) hooks are run before the login request is validated
+	BeforeLogin []BeforeLoginHook
+	// AfterRegistrationLookup (OPTIONAL) hooks are run after the login requests registration is found
+	AfterRegistrationLookup []RegistrationHook
+	// AfterIDTokenVerified (OPTIONAL) hooks are run after the callbacks id_token is verified
+	AfterIDTokenVerified []IDTokenHook
+	// AfterLaunch (OPTIONAL) hooks are run after the launch is completed
+	AfterLaunch []LaunchHook
+	// OnFailure (OPTIONAL) hooks are run when the login or callback fails
+	OnFailure []FailureHook
+}
+
+func (h Hooks) runBeforeLogin(ctx context.Context, params peregrine.OIDCLoginRequestParams) error {
+	for _, hook := range h.BeforeLogin {
+		if err := hook(ctx, params); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h Hooks) runAfterRegistrationLookup(ctx context.Context, registration peregrine.Registration) error {
+	for _, hook := range h.AfterRegistrationLookup {
+		if err := hook(ctx, registration); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h Hooks) runAfterIDTokenVerified(
+	ctx context.Context, launch peregrine.Launch, claims peregrine.LTI1p3Claims,
+) error {
+	for _, hook := range h.AfterIDTokenVerified {
+		if err := hook(ctx, launch, claims); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h Hooks) runAfterLaunch(ctx context.Context, resp HandleOidcCallbackResponse) {
+	for _, hook := range h.AfterLaunch {
+		hook(ctx, resp)
+	}
+}
+
+// failed runs the OnFailure hooks when err is an Error and returns err unchanged
+func (h Hooks) failed(ctx context.Context, err error) error {
+	if err == nil || len(h.OnFailure) == 0 {
+		return err
+	}
+	if launchErr, ok := err.(*Error); ok {
+		for _, hook := range h.OnFailure {
+			hook(ctx, launchErr)
+		}
+	}
+	return err
+}
@@ -0,0 +1,179 @@
+package launch
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stevenweathers/peregrine-lti/peregrine"
+)
+
+var errTenantSuspended = errors.New("TENANT_SUSPENDED")
+
+func TestHandleOidcLoginHooks(t *testing.T) {
+	t.Parallel()
+	var calls []string
+	launchSvc := New(Config{
+		JWTKeySecret: testJWTSecret,
+		Issuer:       testIssuer,
+		CallbackURL:  testCallbackURL,
+		Hooks: Hooks{
+			BeforeLogin: []BeforeLoginHook{func(ctx context.Context, params peregrine.OIDCLoginRequestParams) error {
+				calls = append(calls, "before_login:"+params.ClientID)
+				return nil
+			}},
+			AfterRegistrationLookup: []RegistrationHook{func(ctx context.Context, registration peregrine.Registration) error {
+				calls = append(calls, "registration:"+registration.Platform.Issuer)
+				return nil
+			}},
+		},
+	}, &mockStoreSvc{})
+
+	_, err := launchSvc.HandleOidcLogin(context.Background(), peregrine.OIDCLoginRequestParams{
+		Issuer:        canvasTestIssuer,
+		LoginHint:     "32",
+		TargetLinkURI: testTargetLinkURI,
+		ClientID:      testClientID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(calls) != 2 || calls[0] != "before_login:"+testClientID || calls[1] != "registration:"+canvasTestIssuer {
+		t.Fatalf("expected login hooks to be called in order got %v", calls)
+	}
+}
+
+func TestHandleOidcLoginRegistrationHookVeto(t *testing.T) {
+	t.Parallel()
+	var failure *Error
+	launchSvc := New(Config{
+		JWTKeySecret: testJWTSecret,
+		Issuer:       testIssuer,
+		CallbackURL:  testCallbackURL,
+		Hooks: Hooks{
+			AfterRegistrationLookup: []RegistrationHook{func(ctx context.Context, registration peregrine.Registration) error {
+				return errTenantSuspended
+			}},
+			OnFailure: []FailureHook{func(ctx context.Context, err *Error) {
+				failure = err
+			}},
+		},
+	}, &mockStoreSvc{})
+
+	_, err := launchSvc.HandleOidcLogin(context.Background(), peregrine.OIDCLoginRequestParams{
+		Issuer:        canvasTestIssuer,
+		LoginHint:     "32",
+		TargetLinkURI: testTargetLinkURI,
+		ClientID:      testClientID,
+	})
+	if !errors.Is(err, errTenantSuspended) || ErrorStage(err) != StageRegistrationHook {
+		t.Fatalf("expected error: %v", err)
+	}
+	if failure == nil || failure.Stage != StageRegistrationHook {
+		t.Fatalf("expected OnFailure hook to be called with stage %s got %v", StageRegistrationHook, failure)
+	}
+}
+
+func TestHandleOidcCallbackHooks(t *testing.T) {
+	t.Parallel()
+	var verifiedSub string
+	var completed *HandleOidcCallbackResponse
+	launchSvc := New(Config{
+		JWTKeySecret: testJWTSecret,
+		Issuer:       testIssuer,
+		CallbackURL:  testCallbackURL,
+		Hooks: Hooks{
+			AfterIDTokenVerified: []IDTokenHook{
+				func(ctx context.Context, launch peregrine.Launch, claims peregrine.LTI1p3Claims) error {
+					verifiedSub = claims.SUB
+					return nil
+				},
+			},
+			AfterLaunch: []LaunchHook{func(ctx context.Context, resp HandleOidcCallbackResponse) {
+				completed = &resp
+			}},
+		},
+	}, &mockStoreSvc{})
+
+	state, err := createLaunchState(testIssuer, testJWTSecret, testClientID, testLaunchID, testTargetLinkURI)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = launchSvc.HandleOidcCallback(context.Background(), peregrine.OIDCAuthenticationResponse{
+		State:   state,
+		IDToken: signTestIDToken(t, testIDTokenBuilder()),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if verifiedSub != testSubClaim {
+		t.Fatalf("expected AfterIDTokenVerified hook to receive sub %s got %s", testSubClaim, verifiedSub)
+	}
+	if completed == nil || completed.Launch.ID != testLaunchID || completed.Launch.Used == nil {
+		t.Fatalf("expected AfterLaunch hook to receive the used launch got %v", completed)
+	}
+}
+
+func TestHandleOidcCallbackIDTokenHookVeto(t *testing.T) {
+	t.Parallel()
+	var failure *Error
+	launchCompleted := false
+	launchSvc := New(Config{
+		JWTKeySecret: testJWTSecret,
+		Issuer:       testIssuer,
+		CallbackURL:  testCallbackURL,
+		Hooks: Hooks{
+			AfterIDTokenVerified: []IDTokenHook{
+				func(ctx context.Context, launch peregrine.Launch, claims peregrine.LTI1p3Claims) error {
+					return errTenantSuspended
+				},
+			},
+			AfterLaunch: []LaunchHook{func(ctx context.Context, resp HandleOidcCallbackResponse) {
+				launchCompleted = true
+			}},
+			OnFailure: []FailureHook{func(ctx context.Context, err *Error) {
+				failure = err
+			}},
+		},
+	}, &mockStoreSvcWithFailedLaunchUpdate{})
+
+	state, err := createLaunchState(testIssuer, testJWTSecret, testClientID, testLaunchID, testTargetLinkURI)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the failing launch update ensures the veto stops the launch before it is marked used
+	_, err = launchSvc.HandleOidcCallback(context.Background(), peregrine.OIDCAuthenticationResponse{
+		State:   state,
+		IDToken: signTestIDToken(t, testIDTokenBuilder()),
+	})
+	if !errors.Is(err, errTenantSuspended) || ErrorStage(err) != StageIDTokenHook {
+		t.Fatalf("expected error: %v", err)
+	}
+	if failure == nil || failure.Stage != StageIDTokenHook {
+		t.Fatalf("expected OnFailure hook to be called with stage %s got %v", StageIDTokenHook, failure)
+	}
+	if launchCompleted {
+		t.Fatalf("expected AfterLaunch hook not to be called for vetoed launch")
+	}
+}
+
+func TestHandleOidcCallbackFailureStage(t *testing.T) {
+	t.Parallel()
+	launchSvc := New(Config{
+		JWTKeySecret: testJWTSecret,
+		Issuer:       testIssuer,
+		CallbackURL:  testCallbackURL,
+	}, &mockStoreSvc{})
+
+	_, err := launchSvc.HandleOidcCallback(context.Background(), peregrine.OIDCAuthenticationResponse{
+		State:   "notavalidstate",
+		IDToken: signTestIDToken(t, testIDTokenBuilder()),
+	})
+	if ErrorStage(err) != StageValidateState {
+		t.Fatalf("expected error: %v", err)
+	}
+}
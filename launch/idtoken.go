@@ -0,0 +1,137 @@
+package launch
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// DefaultMaxIDTokenAge is the default maximum time since the id_token iat, the Platform issues the id_token
+	// immediately before posting it to the tool so an older id_token is likely replayed
+	DefaultMaxIDTokenAge = time.Minute * 5
+	// DefaultIDTokenClockSkew is the default leeway allowed between the Platform and tool clocks
+	// when validating the id_token exp, nbf and iat
+	DefaultIDTokenClockSkew = time.Minute
+)
+
+// DefaultIDTokenSigningAlgorithms are the id_token alg header values accepted by default,
+// the LTI Security Framework requires platforms to sign with RS256
+var DefaultIDTokenSigningAlgorithms = []string{"RS256"}
+
+// IDTokenRule identifies a rule of the id_token validation as per
+// http://www.imsglobal.org/spec/security/v1p0/#authentication-response-validation
+// and https://www.imsglobal.org/spec/lti/v1p3#required-message-claims
+type IDTokenRule string
+
+// id_token validation rules in the order they are validated, see IDTokenError
+const (
+	RuleAlgorithm       IDTokenRule = "alg"
+	RuleSignature       IDTokenRule = "signature"
+	RuleIssuer          IDTokenRule = "iss"
+	RuleAudience        IDTokenRule = "aud"
+	RuleAuthorizedParty IDTokenRule = "azp"
+	RuleExpiration      IDTokenRule = "exp"
+	RuleNotBefore       IDTokenRule = "nbf"
+	RuleIssuedAt        IDTokenRule = "iat"
+	RuleNonce           IDTokenRule = "nonce"
+	RuleDeploymentID    IDTokenRule = "deployment_id"
+	RuleMessageType     IDTokenRule = "message_type"
+	RuleVersion         IDTokenRule = "version"
+	RuleTargetLinkURI   IDTokenRule = "target_link_uri"
+	RuleClaims          IDTokenRule = "claims"
+	RuleSubject         IDTokenRule = "sub"
+	// RuleSubjectRequired requires the sub claim when the id_token carries identity claims (name, given_name,
+	// family_name or email) as only anonymous launches may omit it
+	RuleSubjectRequired IDTokenRule = "sub_required"
+	// RuleRoles requires the roles claim, which may be an empty array
+	RuleRoles IDTokenRule = "roles"
+	// RuleResourceLinkID requires the resource_link id claim of a LtiResourceLinkRequest
+	RuleResourceLinkID IDTokenRule = "resource_link_id"
+	// RuleTargetLinkURIMatch requires the target_link_uri claim to match the target_link_uri of the login
+	RuleTargetLinkURIMatch IDTokenRule = "target_link_uri_match"
+)
+
+// IDTokenError is returned when the id_token fails a validation Rule
+type IDTokenError struct {
+	// Rule is the failed validation rule
+	Rule IDTokenRule
+	// Err describes the failure
+	Err error
+}
+
+func (e *IDTokenError) Error() string {
+	return fmt.Sprintf("invalid id_token: %v", e.Err)
+}
+
+func (e *IDTokenError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorIDTokenRule returns the IDTokenRule the id_token failed, empty if err is not an IDTokenError
+func ErrorIDTokenRule(err error) IDTokenRule {
+	var idTokenErr *IDTokenError
+	if errors.As(err, &idTokenErr) {
+		return idTokenErr.Rule
+	}
+
+	return ""
+}
+
+func newIDTokenError(rule IDTokenRule, format string, a ...interface{}) *IDTokenError {
+	return &IDTokenError{Rule: rule, Err: fmt.Errorf(format, a...)}
+}
+
+// idTokenValidation holds the options of the id_token validation
+type idTokenValidation struct {
+	allowedMessageTypes []string
+	signingAlgorithms   []string
+	trustedAudiences    []string
+	maxAge              time.Duration
+	clockSkew           time.Duration
+	now                 time.Time
+	// targetLinkURI is the target_link_uri of the login, empty when the state does not carry it
+	targetLinkURI string
+	severities    map[IDTokenRule]RuleSeverity
+	// warnings are the failed rules with a SeverityWarning
+	warnings []*IDTokenError
+}
+
+// idTokenValidation returns the id_token validation options for the tool identity and login target_link_uri
+func (s *Service) idTokenValidation(toolCfg ToolConfig, targetLinkURI string) *idTokenValidation {
+	v := &idTokenValidation{
+		allowedMessageTypes: toolCfg.AllowedMessageTypes,
+		signingAlgorithms:   s.config.IDTokenSigningAlgorithms,
+		trustedAudiences:    s.config.TrustedAudiences,
+		maxAge:              s.config.MaxIDTokenAge,
+		clockSkew:           s.config.IDTokenClockSkew,
+		now:                 time.Now(),
+		targetLinkURI:       targetLinkURI,
+		severities:          s.config.ValidationProfile.severities(s.config.RuleOverrides),
+	}
+	if v.maxAge == 0 {
+		v.maxAge = DefaultMaxIDTokenAge
+	}
+	if v.clockSkew == 0 {
+		v.clockSkew = DefaultIDTokenClockSkew
+	}
+	if len(v.signingAlgorithms) == 0 {
+		v.signingAlgorithms = DefaultIDTokenSigningAlgorithms
+	}
+
+	return v
+}
+
+// check returns the failed rule err when its severity is SeverityError,
+// otherwise it is recorded as a warning or ignored
+func (v *idTokenValidation) check(err *IDTokenError) error {
+	switch v.severities[err.Rule] {
+	case SeverityIgnore:
+		return nil
+	case SeverityWarning:
+		v.warnings = append(v.warnings, err)
+		return nil
+	default:
+		return err
+	}
+}
@@ -0,0 +1,304 @@
+package launch
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/stevenweathers/peregrine-lti/peregrine"
+)
+
+func TestHandleOidcCallbackIDTokenRules(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		config  Config
+		builder *jwt.Builder
+		rule    IDTokenRule
+	}{
+		{
+			name:    "signing algorithm not allowed",
+			config:  Config{IDTokenSigningAlgorithms: []string{"ES256"}},
+			builder: testIDTokenBuilder(),
+			rule:    RuleAlgorithm,
+		},
+		{
+			name:    "issuer mismatch",
+			builder: testIDTokenBuilder().Issuer("https://canvas.instructure.com"),
+			rule:    RuleIssuer,
+		},
+		{
+			name:    "audience missing client_id",
+			builder: testIDTokenBuilder().Audience([]string{"someothertool"}),
+			rule:    RuleAudience,
+		},
+		{
+			name:    "untrusted additional audience",
+			builder: testIDTokenBuilder().Audience([]string{testClientID, "someothertool"}).Claim(azpClaim, testClientID),
+			rule:    RuleAudience,
+		},
+		{
+			name:    "multiple audiences without azp",
+			config:  Config{TrustedAudiences: []string{"someothertool"}},
+			builder: testIDTokenBuilder().Audience([]string{testClientID, "someothertool"}),
+			rule:    RuleAuthorizedParty,
+		},
+		{
+			name:    "azp not client_id",
+			builder: testIDTokenBuilder().Claim(azpClaim, "someothertool"),
+			rule:    RuleAuthorizedParty,
+		},
+		{
+			name:    "expired",
+			builder: testIDTokenBuilder().Expiration(time.Now().Add(-time.Minute * 2)),
+			rule:    RuleExpiration,
+		},
+		{
+			name:    "not yet valid",
+			builder: testIDTokenBuilder().NotBefore(time.Now().Add(time.Minute * 5)),
+			rule:    RuleNotBefore,
+		},
+		{
+			name:    "issued too long ago",
+			builder: testIDTokenBuilder().IssuedAt(time.Now().Add(-time.Minute * 30)),
+			rule:    RuleIssuedAt,
+		},
+		{
+			name:    "issued in the future",
+			builder: testIDTokenBuilder().IssuedAt(time.Now().Add(time.Minute * 5)),
+			rule:    RuleIssuedAt,
+		},
+		{
+			name:    "nonce mismatch",
+			builder: testIDTokenBuilder().Claim(nonceClaim, "notthenonce"),
+			rule:    RuleNonce,
+		},
+		{
+			name:    "unsupported version",
+			builder: testIDTokenBuilder().Claim(ltiVersionClaim, "1.1"),
+			rule:    RuleVersion,
+		},
+		{
+			name:    "strict missing roles",
+			config:  Config{ValidationProfile: StrictProfile},
+			builder: testIDTokenBuilder(),
+			rule:    RuleRoles,
+		},
+		{
+			name:    "strict missing resource_link id",
+			config:  Config{ValidationProfile: StrictProfile},
+			builder: testIDTokenBuilder().Claim(ltiRolesClaim, []string{}),
+			rule:    RuleResourceLinkID,
+		},
+		{
+			name:    "strict target_link_uri mismatch",
+			config:  Config{ValidationProfile: StrictProfile},
+			builder: testStrictIDTokenBuilder().Claim(ltiTargetLinkUriClaim, "https://stevenweathers.dev/other"),
+			rule:    RuleTargetLinkURIMatch,
+		},
+		{
+			name:    "lenient target_link_uri mismatch",
+			builder: testIDTokenBuilder().Claim(ltiTargetLinkUriClaim, "https://stevenweathers.dev/other"),
+			rule:    RuleTargetLinkURIMatch,
+		},
+		{
+			name:    "strict identity claims without sub",
+			config:  Config{ValidationProfile: StrictProfile},
+			builder: testStrictIDTokenBuilder().Subject("").Claim("name", "Thor Odinson"),
+			rule:    RuleSubjectRequired,
+		},
+		{
+			name:    "lenient override missing roles",
+			config:  Config{RuleOverrides: map[IDTokenRule]RuleSeverity{RuleRoles: SeverityError}},
+			builder: testIDTokenBuilder(),
+			rule:    RuleRoles,
+		},
+		{
+			name:    "security rule can not be overridden",
+			config:  Config{RuleOverrides: map[IDTokenRule]RuleSeverity{RuleNonce: SeverityIgnore}},
+			builder: testIDTokenBuilder().Claim(nonceClaim, "notthenonce"),
+			rule:    RuleNonce,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			tt.config.JWTKeySecret = testJWTSecret
+			tt.config.Issuer = testIssuer
+			tt.config.CallbackURL = testCallbackURL
+			launchSvc := New(tt.config, &mockStoreSvc{})
+
+			state, err := createLaunchState(testIssuer, testJWTSecret, testClientID, testLaunchID, testTargetLinkURI)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = launchSvc.HandleOidcCallback(context.Background(), peregrine.OIDCAuthenticationResponse{
+				State:   state,
+				IDToken: signTestIDToken(t, tt.builder),
+			})
+			if ErrorIDTokenRule(err) != tt.rule || ErrorStage(err) != StageIDToken {
+				t.Fatalf("expected %s rule error: %v", tt.rule, err)
+			}
+		})
+	}
+}
+
+// testStrictIDTokenBuilder returns a testIDTokenBuilder that passes the StrictProfile rules
+func testStrictIDTokenBuilder() *jwt.Builder {
+	return testIDTokenBuilder().
+		Claim(ltiRolesClaim, []string{"http://purl.imsglobal.org/vocab/lis/v2/membership#Learner"}).
+		Claim("https://purl.imsglobal.org/spec/lti/claim/resource_link", map[string]interface{}{"id": "rl-1"})
+}
+
+func TestHandleOidcCallbackValidationProfiles(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		config   Config
+		builder  *jwt.Builder
+		warnings []IDTokenRule
+	}{
+		{
+			name:    "strict conforming id_token",
+			config:  Config{ValidationProfile: StrictProfile},
+			builder: testStrictIDTokenBuilder(),
+		},
+		{
+			name:     "lenient warns on deviations",
+			builder:  testIDTokenBuilder(),
+			warnings: []IDTokenRule{RuleRoles, RuleResourceLinkID},
+		},
+		{
+			name:     "lenient ignore override",
+			config:   Config{RuleOverrides: map[IDTokenRule]RuleSeverity{RuleRoles: SeverityIgnore}},
+			builder:  testIDTokenBuilder(),
+			warnings: []IDTokenRule{RuleResourceLinkID},
+		},
+		{
+			name:     "strict warning override",
+			config:   Config{ValidationProfile: StrictProfile, RuleOverrides: map[IDTokenRule]RuleSeverity{RuleVersion: SeverityWarning}},
+			builder:  testStrictIDTokenBuilder().Claim(ltiVersionClaim, "1.1"),
+			warnings: []IDTokenRule{RuleVersion},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			tt.config.JWTKeySecret = testJWTSecret
+			tt.config.Issuer = testIssuer
+			tt.config.CallbackURL = testCallbackURL
+			launchSvc := New(tt.config, &mockStoreSvc{})
+
+			state, err := createLaunchState(testIssuer, testJWTSecret, testClientID, testLaunchID, testTargetLinkURI)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := launchSvc.HandleOidcCallback(context.Background(), peregrine.OIDCAuthenticationResponse{
+				State:   state,
+				IDToken: signTestIDToken(t, tt.builder),
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(resp.Warnings) != len(tt.warnings) {
+				t.Fatalf("expected %d warnings got %v", len(tt.warnings), resp.Warnings)
+			}
+			for i, rule := range tt.warnings {
+				if resp.Warnings[i].Rule != rule {
+					t.Fatalf("expected warning %d to be %s got %s", i, rule, resp.Warnings[i].Rule)
+				}
+			}
+		})
+	}
+}
+
+func TestHandleOidcCallbackIDTokenDefaultSigningAlgorithm(t *testing.T) {
+	t.Parallel()
+	launchSvc := New(Config{
+		JWTKeySecret: testJWTSecret,
+		Issuer:       testIssuer,
+		CallbackURL:  testCallbackURL,
+	}, &mockStoreSvc{})
+
+	tok, err := testIDTokenBuilder().Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := jwk.FromRaw([]byte(testJWTSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = key.Set(jwk.KeyIDKey, testJwkKey.KeyID()); err != nil {
+		t.Fatal(err)
+	}
+	signed, err := jwt.Sign(tok, jwt.WithKey(jwa.HS256, key))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state, err := createLaunchState(testIssuer, testJWTSecret, testClientID, testLaunchID, testTargetLinkURI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = launchSvc.HandleOidcCallback(context.Background(), peregrine.OIDCAuthenticationResponse{
+		State:   state,
+		IDToken: string(signed),
+	})
+	if ErrorIDTokenRule(err) != RuleAlgorithm {
+		t.Fatalf("expected HS256 id_token to be rejected by default: %v", err)
+	}
+}
+
+func TestHandleOidcCallbackIDTokenNotBeforeClockSkew(t *testing.T) {
+	t.Parallel()
+	launchSvc := New(Config{
+		JWTKeySecret: testJWTSecret,
+		Issuer:       testIssuer,
+		CallbackURL:  testCallbackURL,
+	}, &mockStoreSvc{})
+
+	state, err := createLaunchState(testIssuer, testJWTSecret, testClientID, testLaunchID, testTargetLinkURI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = launchSvc.HandleOidcCallback(context.Background(), peregrine.OIDCAuthenticationResponse{
+		State:   state,
+		IDToken: signTestIDToken(t, testIDTokenBuilder().NotBefore(time.Now().Add(time.Second*30))),
+	})
+	if err != nil {
+		t.Fatalf("expected nbf within the clock skew to be valid: %v", err)
+	}
+}
+
+func TestHandleOidcCallbackIDTokenMultipleAudiences(t *testing.T) {
+	t.Parallel()
+	launchSvc := New(Config{
+		JWTKeySecret:     testJWTSecret,
+		Issuer:           testIssuer,
+		CallbackURL:      testCallbackURL,
+		TrustedAudiences: []string{"someothertool"},
+		MaxIDTokenAge:    time.Hour,
+	}, &mockStoreSvc{})
+
+	state, err := createLaunchState(testIssuer, testJWTSecret, testClientID, testLaunchID, testTargetLinkURI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = launchSvc.HandleOidcCallback(context.Background(), peregrine.OIDCAuthenticationResponse{
+		State: state,
+		IDToken: signTestIDToken(t, testIDTokenBuilder().
+			Audience([]string{testClientID, "someothertool"}).
+			Claim(azpClaim, testClientID).
+			IssuedAt(time.Now().Add(-time.Minute*30))),
+	})
+	if err != nil {
+		t.Fatalf("expected trusted multi audience id_token with azp to be valid: %v", err)
+	}
+}
@@ -0,0 +1,71 @@
+package launch
+
+import (
+	"context"
+	"time"
+
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/stevenweathers/peregrine-lti/instrument"
+)
+
+// startRepoSpan starts a span for the peregrine.ToolDataRepo operation returning the context to call the
+// data store with and a func ending the span with the operations error
+func (s *Service) startRepoSpan(ctx context.Context, operation string) (context.Context, func(error)) {
+	ctx, span := s.config.Tracer.Start(ctx, "peregrine.repo."+operation)
+
+	return ctx, func(err error) {
+		if err != nil {
+			span.RecordError(err)
+		}
+		span.End()
+	}
+}
+
+// recordOutcome records the outcome and latency of the login or callback flow,
+// failures are counted by the Stage of the Error as the reason
+func (s *Service) recordOutcome(
+	ctx context.Context, span instrument.Span, flow string, issuer string, start time.Time, err error,
+) {
+	attrs := []instrument.Attribute{
+		instrument.String(instrument.AttrFlow, flow),
+		instrument.String(instrument.AttrIssuer, issuer),
+	}
+	s.config.Meter.Record(ctx, instrument.MetricLaunchDuration, time.Since(start).Seconds(), attrs...)
+
+	if err != nil {
+		span.RecordError(err)
+		reason := instrument.String(instrument.AttrReason, string(ErrorStage(err)))
+		span.SetAttributes(reason)
+		attrs = append(attrs, instrument.String(instrument.AttrOutcome, instrument.OutcomeFailure), reason)
+	} else {
+		attrs = append(attrs, instrument.String(instrument.AttrOutcome, instrument.OutcomeSuccess))
+	}
+	s.config.Meter.Count(ctx, instrument.MetricLaunches, 1, attrs...)
+}
+
+// platformJWKs retrieves the platforms jwk key set recording whether it was already cached
+// and how long fetching it took
+func (s *Service) platformJWKs(ctx context.Context, issuer string, jwkURL string) (jwk.Set, error) {
+	issuerAttr := instrument.String(instrument.AttrIssuer, issuer)
+	cacheResult := instrument.CacheHit
+	if !s.jwkCache.IsRegistered(jwkURL) {
+		cacheResult = instrument.CacheMiss
+	}
+	s.config.Meter.Count(ctx, instrument.MetricJWKSCache, 1,
+		issuerAttr, instrument.String(instrument.AttrCacheResult, cacheResult),
+	)
+
+	ctx, span := s.config.Tracer.Start(ctx, "peregrine.getPlatformJWKs",
+		issuerAttr, instrument.String(instrument.AttrCacheResult, cacheResult),
+	)
+	defer span.End()
+	start := time.Now()
+
+	keySet, err := getPlatformJWKs(ctx, s.jwkCache, jwkURL)
+	s.config.Meter.Record(ctx, instrument.MetricJWKSFetchDuration, time.Since(start).Seconds(), issuerAttr)
+	if err != nil {
+		span.RecordError(err)
+	}
+
+	return keySet, err
+}
@@ -0,0 +1,168 @@
+package launch
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stevenweathers/peregrine-lti/instrument"
+	"github.com/stevenweathers/peregrine-lti/peregrine"
+)
+
+// recordingInstrument records the spans and counters of a Service for assertions
+type recordingInstrument struct {
+	mu       sync.Mutex
+	spans    []string
+	failed   []string
+	counters map[string][]map[string]string
+}
+
+type recordingSpan struct {
+	name string
+	rec  *recordingInstrument
+}
+
+func (r *recordingInstrument) Start(ctx context.Context, name string, _ ...instrument.Attribute) (
+	context.Context, instrument.Span,
+) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans = append(r.spans, name)
+	return ctx, recordingSpan{name: name, rec: r}
+}
+
+func (s recordingSpan) SetAttributes(...instrument.Attribute) {}
+
+func (s recordingSpan) RecordError(error) {
+	s.rec.mu.Lock()
+	defer s.rec.mu.Unlock()
+	s.rec.failed = append(s.rec.failed, s.name)
+}
+
+func (s recordingSpan) End() {}
+
+func (r *recordingInstrument) Count(_ context.Context, name string, _ int64, attrs ...instrument.Attribute) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.counters == nil {
+		r.counters = make(map[string][]map[string]string)
+	}
+	m := make(map[string]string)
+	for _, a := range attrs {
+		m[a.Key] = a.Value
+	}
+	r.counters[name] = append(r.counters[name], m)
+}
+
+func (r *recordingInstrument) Record(context.Context, string, float64, ...instrument.Attribute) {}
+
+func TestHandleOidcCallbackInstrumentation(t *testing.T) {
+	t.Parallel()
+	rec := &recordingInstrument{}
+	launchSvc := New(Config{
+		JWTKeySecret: testJWTSecret,
+		Issuer:       testIssuer,
+		CallbackURL:  testCallbackURL,
+		Tracer:       rec,
+		Meter:        rec,
+		// the mock data store returns the same launch (and nonce) for both callbacks
+		NonceStore: NonceStoreFunc(func(ctx context.Context, nonce string, expiresAt time.Time) (bool, error) {
+			return true, nil
+		}),
+	}, &mockStoreSvc{})
+
+	for i := 0; i < 2; i++ {
+		state, err := createLaunchState(testIssuer, testJWTSecret, testClientID, testLaunchID, testTargetLinkURI)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = launchSvc.HandleOidcCallback(context.Background(), peregrine.OIDCAuthenticationResponse{
+			State:   state,
+			IDToken: signTestIDToken(t, testIDTokenBuilder()),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, name := range []string{
+		"peregrine.HandleOidcCallback", "peregrine.repo.GetLaunch", "peregrine.getPlatformJWKs",
+		"peregrine.repo.UpdateLaunch",
+	} {
+		if !containsString(rec.spans, name) {
+			t.Fatalf("expected span %s to be recorded got %v", name, rec.spans)
+		}
+	}
+
+	cache := rec.counters[instrument.MetricJWKSCache]
+	if len(cache) != 2 || cache[0][instrument.AttrCacheResult] != instrument.CacheMiss ||
+		cache[1][instrument.AttrCacheResult] != instrument.CacheHit {
+		t.Fatalf("expected jwks cache miss then hit got %v", cache)
+	}
+
+	launches := rec.counters[instrument.MetricLaunches]
+	if len(launches) != 2 || launches[0][instrument.AttrOutcome] != instrument.OutcomeSuccess ||
+		launches[0][instrument.AttrIssuer] != canvasTestIssuer {
+		t.Fatalf("expected successful launches to be counted got %v", launches)
+	}
+}
+
+func TestHandleOidcLoginInstrumentationFailure(t *testing.T) {
+	t.Parallel()
+	rec := &recordingInstrument{}
+	launchSvc := New(Config{
+		JWTKeySecret: testJWTSecret,
+		Issuer:       testIssuer,
+		CallbackURL:  testCallbackURL,
+		Tracer:       rec,
+		Meter:        rec,
+	}, &mockStoreSvcWithFailedLaunchCreate{})
+
+	_, err := launchSvc.HandleOidcLogin(context.Background(), peregrine.OIDCLoginRequestParams{
+		Issuer:        canvasTestIssuer,
+		LoginHint:     "32",
+		TargetLinkURI: testTargetLinkURI,
+		ClientID:      testClientID,
+	})
+	if err == nil {
+		t.Fatalf("expected error: %v", err)
+	}
+
+	if !containsString(rec.failed, "peregrine.repo.CreateLaunch") || !containsString(rec.failed, "peregrine.HandleOidcLogin") {
+		t.Fatalf("expected failed spans to record the error got %v", rec.failed)
+	}
+	launches := rec.counters[instrument.MetricLaunches]
+	if len(launches) != 1 || launches[0][instrument.AttrOutcome] != instrument.OutcomeFailure ||
+		launches[0][instrument.AttrReason] != string(StageCreateLaunch) ||
+		launches[0][instrument.AttrIssuer] != happyPathPlatform.Issuer {
+		t.Fatalf("expected failed login to be counted by reason got %v", launches)
+	}
+}
+
+func TestHandleOidcLoginInstrumentationUnknownIssuer(t *testing.T) {
+	t.Parallel()
+	rec := &recordingInstrument{}
+	launchSvc := New(Config{
+		JWTKeySecret: testJWTSecret,
+		Issuer:       testIssuer,
+		CallbackURL:  testCallbackURL,
+		Tracer:       rec,
+		Meter:        rec,
+	}, &mockStoreSvc{})
+
+	_, err := launchSvc.HandleOidcLogin(context.Background(), peregrine.OIDCLoginRequestParams{
+		Issuer:        "https://attacker.example/random-1",
+		LoginHint:     "32",
+		TargetLinkURI: testTargetLinkURI,
+		ClientID:      "random-1",
+	})
+	if ErrorStage(err) != StageRegistrationLookup {
+		t.Fatalf("expected error: %v", err)
+	}
+
+	launches := rec.counters[instrument.MetricLaunches]
+	if len(launches) != 1 || launches[0][instrument.AttrIssuer] != instrument.IssuerUnknown {
+		t.Fatalf("expected login without a registration to be counted with an unknown issuer got %v", launches)
+	}
+}
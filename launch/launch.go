@@ -3,29 +3,86 @@ package launch
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/stevenweathers/peregrine-lti/instrument"
 	"github.com/stevenweathers/peregrine-lti/peregrine"
 )
 
 // New returns a new Service for handling LTI launch
 func New(config Config, dataSvc peregrine.ToolDataRepo) *Service {
 	c := jwk.NewCache(context.Background())
+	if config.Tracer == nil {
+		config.Tracer = instrument.Noop{}
+	}
+	if config.Meter == nil {
+		config.Meter = instrument.Noop{}
+	}
+	if config.Logger == nil {
+		config.Logger = DiscardLogger()
+	}
+
+	nonceStore := config.NonceStore
+	if nonceStore == nil {
+		if repo, ok := dataSvc.(peregrine.NonceRepo); ok {
+			nonceStore = repo
+		} else {
+			nonceStore = NewMemoryNonceStore()
+		}
+	}
 
 	return &Service{
-		config:   config,
-		dataSvc:  dataSvc,
-		jwkCache: c,
+		config:        config,
+		dataSvc:       dataSvc,
+		jwkCache:      c,
+		nonceStore:    nonceStore,
+		claimDecoders: make(map[string]ClaimDecoder),
 	}
 }
 
 // HandleOidcLogin receives the peregrine.OIDCLoginRequestParams
 // then validates the request and builds the peregrine.OIDCLoginResponseParams
-// to send the Platform in the redirect to peregrine.Platform AuthLoginURL
+// to send the Platform in the redirect to peregrine.Platform AuthLoginURL,
+// errors are returned as *Error identifying the failed Stage
 func (s *Service) HandleOidcLogin(ctx context.Context, params peregrine.OIDCLoginRequestParams) (
 	HandleOidcLoginResponse, error,
 ) {
+	ctx, span := s.config.Tracer.Start(ctx, "peregrine.HandleOidcLogin")
+	defer span.End()
+	start := time.Now()
+
+	// spans and metrics are only labelled with the stored registration, not the requesters login params
+	var registration peregrine.Registration
+	resp, err := s.handleOidcLogin(ctx, params, &registration)
+	issuer := instrument.IssuerUnknown
+	if registration.Platform != nil {
+		issuer = registration.Platform.Issuer
+		span.SetAttributes(
+			instrument.String(instrument.AttrIssuer, issuer),
+			instrument.String(instrument.AttrClientID, registration.ClientID),
+		)
+	}
+	s.recordOutcome(ctx, span, instrument.FlowLogin, issuer, start, err)
+	if err != nil {
+		s.config.Logger.WarnContext(ctx, "oidc login failed",
+			slog.String(logKeyIssuer, params.Issuer),
+			slog.String(logKeyClientID, params.ClientID),
+			slog.String(logKeyDeploymentID, params.LTIDeploymentID),
+			slog.String(logKeyStage, string(ErrorStage(err))),
+			slog.String(logKeyError, err.Error()),
+		)
+	}
+
+	return resp, s.config.Hooks.failed(ctx, err)
+}
+
+// handleOidcLogin sets resolved to the registration of the login once found
+func (s *Service) handleOidcLogin(
+	ctx context.Context, params peregrine.OIDCLoginRequestParams, resolved *peregrine.Registration,
+) (HandleOidcLoginResponse, error) {
 	var deployment *peregrine.Deployment
 	resp := HandleOidcLoginResponse{
 		OIDCLoginResponseParams: peregrine.OIDCLoginResponseParams{
@@ -39,51 +96,152 @@ func (s *Service) HandleOidcLogin(ctx context.Context, params peregrine.OIDCLogi
 		},
 	}
 
-	err := validateLoginRequestParams(params)
-	if err != nil {
-		return resp, fmt.Errorf("failed to validate login request params: %v", err)
+	if err := s.config.Hooks.runBeforeLogin(ctx, params); err != nil {
+		return resp, newError(StageBeforeLogin, fmt.Errorf("login rejected: %w", err))
 	}
 
-	registration, err := s.dataSvc.GetRegistrationByClientID(ctx, params.ClientID)
+	issuerRepo, canLookupByIssuer := s.dataSvc.(peregrine.RegistrationIssuerRepo)
+	err := validateLoginRequestParams(params, canLookupByIssuer)
 	if err != nil {
-		return resp, fmt.Errorf("failed to get registration by client id %s: %v", params.ClientID, err)
+		return resp, newError(StageLoginParams, fmt.Errorf("failed to validate login request params: %v", err))
+	}
+
+	if !s.allowLogin(ctx, params.ClientID, params.Issuer) {
+		return resp, newError(StageRateLimit, fmt.Errorf("%w: too many login requests", ErrRateLimited))
+	}
+
+	var registration peregrine.Registration
+	if params.ClientID != "" {
+		repoCtx, end := s.startRepoSpan(ctx, "GetRegistrationByClientID")
+		registration, err = s.dataSvc.GetRegistrationByClientID(repoCtx, params.ClientID)
+		end(err)
+		if err != nil {
+			return resp, newError(StageRegistrationLookup, fmt.Errorf(
+				"failed to get registration by client id %s: %w", params.ClientID, err,
+			))
+		}
+	} else {
+		repoCtx, end := s.startRepoSpan(ctx, "GetRegistrationsByIssuer")
+		registration, err = getRegistrationByIssuer(repoCtx, issuerRepo, params.Issuer, params.LTIDeploymentID)
+		end(err)
+		if err != nil {
+			return resp, newError(StageRegistrationLookup, fmt.Errorf(
+				"failed to get registration by issuer %s: %w", params.Issuer, err,
+			))
+		}
+		resp.OIDCLoginResponseParams.ClientID = registration.ClientID
 	}
+	*resolved = registration
 	resp.RedirectURL = registration.Platform.AuthLoginURL
+	resp.AuthRequestMethod = registration.Platform.AuthRequestMethod
+	s.config.Logger.DebugContext(ctx, "registration found",
+		slog.String(logKeyIssuer, params.Issuer),
+		slog.String(logKeyClientID, registration.ClientID),
+		slog.String(logKeyDeploymentID, params.LTIDeploymentID),
+		slog.String(logKeyRegistrationID, registration.ID.String()),
+	)
+
+	if err = checkRegistrationStatus(registration); err != nil {
+		return resp, newError(StageRegistrationStatus, err)
+	}
 
 	if params.Issuer != registration.Platform.Issuer {
-		return resp, fmt.Errorf(
+		s.config.Logger.WarnContext(ctx, "request issuer does not match registration issuer",
+			slog.String(logKeyIssuer, params.Issuer),
+			slog.String("registration_issuer", registration.Platform.Issuer),
+			slog.String(logKeyClientID, registration.ClientID),
+		)
+		return resp, newError(StageIssuerMismatch, fmt.Errorf(
 			"request issuer %s does not match registration issuer %s",
 			params.Issuer, registration.Platform.Issuer,
-		)
+		))
 	}
 
+	if err = s.config.Hooks.runAfterRegistrationLookup(ctx, registration); err != nil {
+		return resp, newError(StageRegistrationHook, fmt.Errorf("login rejected: %w", err))
+	}
+
+	// a deferred or stateless launch's deployment is upserted at the callback once the id_token is verified
+	encryptState := s.config.DeferLaunchCreation || s.config.Stateless
 	if params.LTIDeploymentID != "" {
-		dep, err := s.dataSvc.UpsertDeploymentByPlatformDeploymentID(ctx, peregrine.Deployment{
+		if err = checkDeploymentAllowed(registration, params.LTIDeploymentID); err != nil {
+			return resp, newError(StageDeploymentStatus, err)
+		}
+	}
+	if params.LTIDeploymentID != "" && !encryptState {
+		repoCtx, end := s.startRepoSpan(ctx, "UpsertDeploymentByPlatformDeploymentID")
+		dep, err := s.dataSvc.UpsertDeploymentByPlatformDeploymentID(repoCtx, peregrine.Deployment{
 			Registration: &peregrine.Registration{
 				ID: registration.ID,
 			},
 			PlatformDeploymentID: params.LTIDeploymentID,
 		})
+		end(err)
 		if err != nil {
-			return resp, fmt.Errorf(
+			return resp, newError(StageDeploymentUpsert, fmt.Errorf(
 				"failed to upsert deployment %s: %v", params.LTIDeploymentID, err,
-			)
+			))
 		}
 		deployment = &dep
+		s.config.Logger.DebugContext(ctx, "deployment upserted",
+			slog.String(logKeyIssuer, params.Issuer),
+			slog.String(logKeyClientID, registration.ClientID),
+			slog.String(logKeyDeploymentID, params.LTIDeploymentID),
+		)
+		if err = checkDeploymentStatus(deployment); err != nil {
+			return resp, newError(StageDeploymentStatus, err)
+		}
+	}
+
+	toolCfg, err := s.resolveToolConfig(ctx, registration)
+	if err != nil {
+		return resp, newError(StageToolConfig, fmt.Errorf(
+			"failed to resolve tool config for client id %s: %v", registration.ClientID, err,
+		))
+	}
+	resp.OIDCLoginResponseParams.RedirectURI = toolCfg.CallbackURL
+
+	if err = checkTargetLinkURI(toolCfg, params.TargetLinkURI); err != nil {
+		return resp, newError(StageTargetLinkURI, err)
 	}
 
-	launch, err := s.dataSvc.CreateLaunch(ctx, peregrine.Launch{
-		Registration: &registration,
-		Deployment:   deployment,
+	if encryptState {
+		nonce := uuid.New()
+		state, err := createEncryptedLaunchState(
+			toolCfg, registration, params.LTIDeploymentID, params.TargetLinkURI, uuid.New(), nonce,
+		)
+		if err != nil {
+			return resp, newError(StageCreateState, fmt.Errorf("failed to create launch state: %v", err))
+		}
+		resp.OIDCLoginResponseParams.Nonce = nonce.String()
+		resp.OIDCLoginResponseParams.State = state
+
+		return resp, nil
+	}
+
+	repoCtx, end := s.startRepoSpan(ctx, "CreateLaunch")
+	launch, err := s.dataSvc.CreateLaunch(repoCtx, peregrine.Launch{
+		Registration:  &registration,
+		Deployment:    deployment,
+		TargetLinkURI: params.TargetLinkURI,
 	})
+	end(err)
 	if err != nil {
-		return resp, fmt.Errorf("failed to create launch: %v", err)
+		return resp, newError(StageCreateLaunch, fmt.Errorf("failed to create launch: %v", err))
 	}
 	resp.OIDCLoginResponseParams.Nonce = launch.Nonce.String()
+	s.config.Logger.DebugContext(ctx, "launch created",
+		slog.String(logKeyIssuer, params.Issuer),
+		slog.String(logKeyClientID, registration.ClientID),
+		slog.String(logKeyDeploymentID, params.LTIDeploymentID),
+		slog.String(logKeyLaunchID, launch.ID.String()),
+	)
 
-	state, err := createLaunchState(s.config.Issuer, s.config.JWTKeySecret, launch.ID)
+	state, err := createLaunchState(
+		toolCfg.Issuer, toolCfg.JWTKeySecret, registration.ClientID, launch.ID, params.TargetLinkURI,
+	)
 	if err != nil {
-		return resp, fmt.Errorf("failed to create launch state: %v", err)
+		return resp, newError(StageCreateState, fmt.Errorf("failed to create launch state: %v", err))
 	}
 	resp.OIDCLoginResponseParams.State = state
 
@@ -93,49 +251,149 @@ func (s *Service) HandleOidcLogin(ctx context.Context, params peregrine.OIDCLogi
 // HandleOidcCallback receives the peregrine.OIDCAuthenticationResponse
 // then validates the state and id_token (with claims) as per
 // http://www.imsglobal.org/spec/security/v1p0/#authentication-response-validation
-// and https://www.imsglobal.org/spec/lti/v1p3#required-message-claims
+// and https://www.imsglobal.org/spec/lti/v1p3#required-message-claims,
+// errors are returned as *Error identifying the failed Stage
 func (s *Service) HandleOidcCallback(ctx context.Context, params peregrine.OIDCAuthenticationResponse) (
 	HandleOidcCallbackResponse, error,
+) {
+	ctx, span := s.config.Tracer.Start(ctx, "peregrine.HandleOidcCallback")
+	defer span.End()
+	start := time.Now()
+
+	resp, err := s.handleOidcCallback(ctx, params)
+	issuer := instrument.IssuerUnknown
+	if resp.Launch.Registration != nil && resp.Launch.Registration.Platform != nil {
+		issuer = resp.Launch.Registration.Platform.Issuer
+		span.SetAttributes(
+			instrument.String(instrument.AttrIssuer, issuer),
+			instrument.String(instrument.AttrClientID, resp.Launch.Registration.ClientID),
+		)
+	}
+	s.recordOutcome(ctx, span, instrument.FlowCallback, issuer, start, err)
+	if err != nil {
+		s.config.Logger.WarnContext(ctx, "oidc callback failed", append(launchLogAttrs(resp.Launch),
+			slog.String(logKeyStage, string(ErrorStage(err))),
+			slog.String(logKeyError, err.Error()),
+		)...)
+		return resp, s.config.Hooks.failed(ctx, err)
+	}
+	s.config.Logger.InfoContext(ctx, "launch completed", append(launchLogAttrs(resp.Launch),
+		slog.String("sub", resp.Claims.SUB),
+		s.sensitive("name", resp.Claims.Name),
+		s.sensitive("email", resp.Claims.Email),
+	)...)
+	s.config.Hooks.runAfterLaunch(ctx, resp)
+
+	return resp, nil
+}
+
+func (s *Service) handleOidcCallback(ctx context.Context, params peregrine.OIDCAuthenticationResponse) (
+	HandleOidcCallbackResponse, error,
 ) {
 	resp := HandleOidcCallbackResponse{
 		Claims: peregrine.LTI1p3Claims{},
 		Launch: peregrine.Launch{},
 	}
 
-	launchID, err := validateState(s.config.JWTKeySecret, params.State)
+	var toolCfg ToolConfig
+	var err error
+	encryptedState := s.isEncryptedLaunchState(params.State)
+	if encryptedState {
+		resp.Launch, toolCfg, err = s.parseEncryptedLaunchState(ctx, params.State)
+	} else {
+		resp.Launch, toolCfg, err = s.getStateLaunch(ctx, params.State)
+	}
+	if err != nil {
+		return resp, err
+	}
+	if err = checkRegistrationStatus(*resp.Launch.Registration); err != nil {
+		return resp, newError(StageRegistrationStatus, err)
+	}
+
+	keySetURL := resp.Launch.Registration.Platform.KeySetURL
+	keySet, err := s.platformJWKs(ctx, resp.Launch.Registration.Platform.Issuer, keySetURL)
+	if err != nil {
+		return resp, newError(StageKeySet, fmt.Errorf(
+			"failed to parse id_token: unable to retrieve %s keyset: %v", keySetURL, err,
+		))
+	}
+
+	validation := s.idTokenValidation(toolCfg, resp.Launch.TargetLinkURI)
+	claims, idToken, err := parseIDToken(keySet, resp.Launch, params.IDToken, validation)
+	resp.Warnings = validation.warnings
+	for _, warning := range resp.Warnings {
+		s.config.Logger.WarnContext(ctx, "id_token validation warning", append(launchLogAttrs(resp.Launch),
+			slog.String(logKeyRule, string(warning.Rule)),
+			slog.String(logKeyError, warning.Err.Error()),
+		)...)
+	}
 	if err != nil {
-		return resp, fmt.Errorf("failed to validate state: %v", err)
+		s.config.Logger.WarnContext(ctx, "id_token validation failed", append(launchLogAttrs(resp.Launch),
+			slog.String(logKeyRule, string(ErrorIDTokenRule(err))),
+			slog.String(logKeyError, err.Error()),
+			s.sensitive("id_token", params.IDToken),
+		)...)
+		return resp, newError(StageIDToken, fmt.Errorf("failed to parse id_token: %w", err))
+	}
+	resp.Claims = claims
+	resp.RawIDToken = params.IDToken
+
+	if err = checkTargetLinkURI(toolCfg, claims.TargetLinkURI); err != nil {
+		return resp, newError(StageTargetLinkURI, err)
+	}
+	resp.TargetLinkURI = claims.TargetLinkURI
+
+	// the nonce is recorded as used for every launch so that a replayed id_token is rejected
+	// regardless of how (or whether) the Launch record is stored
+	nonceExpiry := nonceExpiresAt(time.Now(), idToken.Expiration(), validation.clockSkew)
+	if err = s.useNonce(ctx, resp.Launch.Nonce.String(), nonceExpiry); err != nil {
+		return resp, err
 	}
 
-	resp.Launch, err = s.dataSvc.GetLaunch(ctx, launchID)
+	resp.RawClaims, err = idToken.AsMap(ctx)
 	if err != nil {
-		return resp, fmt.Errorf("failed to get launch %s: %v", launchID, err)
+		return resp, newError(StageIDToken, fmt.Errorf("failed to read id_token claims: %v", err))
 	}
 
-	resp.Claims, err = parseIDToken(ctx, s.jwkCache, resp.Launch, params.IDToken)
+	resp.Extensions, err = s.decodeExtensionClaims(resp.RawClaims)
 	if err != nil {
-		return resp, fmt.Errorf("failed to parse id_token: %v", err)
+		return resp, newError(StageExtensionClaims, err)
 	}
 
-	if resp.Claims.DeploymentID != "" && resp.Launch.Deployment == nil {
-		deployment, err := s.dataSvc.UpsertDeploymentByPlatformDeploymentID(ctx, peregrine.Deployment{
+	if err = s.config.Hooks.runAfterIDTokenVerified(ctx, resp.Launch, resp.Claims); err != nil {
+		return resp, newError(StageIDTokenHook, fmt.Errorf("launch rejected: %w", err))
+	}
+
+	if err = checkDeploymentAllowed(*resp.Launch.Registration, resp.Claims.DeploymentID); err != nil {
+		return resp, newError(StageDeploymentStatus, err)
+	}
+	// a deferred or stateless launch's deployment is only known by its platform deployment id until upserted
+	if resp.Claims.DeploymentID != "" && (resp.Launch.Deployment == nil || resp.Launch.Deployment.ID == uuid.Nil) {
+		repoCtx, end := s.startRepoSpan(ctx, "UpsertDeploymentByPlatformDeploymentID")
+		deployment, err := s.dataSvc.UpsertDeploymentByPlatformDeploymentID(repoCtx, peregrine.Deployment{
 			Registration: &peregrine.Registration{
 				ID: resp.Launch.Registration.ID,
 			},
 			PlatformDeploymentID: resp.Claims.DeploymentID,
 		})
+		end(err)
 		if err != nil {
-			return resp, fmt.Errorf(
+			return resp, newError(StageDeploymentUpsert, fmt.Errorf(
 				"failed to upsert lms deployment_id %s",
 				resp.Claims.DeploymentID,
-			)
+			))
 		}
 		resp.Launch.Deployment = &deployment
+		s.config.Logger.DebugContext(ctx, "deployment upserted", launchLogAttrs(resp.Launch)...)
+	}
+	if err = checkDeploymentStatus(resp.Launch.Deployment); err != nil {
+		return resp, newError(StageDeploymentStatus, err)
 	}
 
 	// The peregrine.PlatformInstance is purely for audit purposes and not actually meant to be pre-configured by tool
 	if resp.Claims.ToolPlatform.GUID != "" {
-		platformInstance, err := s.dataSvc.UpsertPlatformInstanceByGUID(ctx, peregrine.PlatformInstance{
+		repoCtx, end := s.startRepoSpan(ctx, "UpsertPlatformInstanceByGUID")
+		platformInstance, err := s.dataSvc.UpsertPlatformInstanceByGUID(repoCtx, peregrine.PlatformInstance{
 			GUID: resp.Claims.ToolPlatform.GUID,
 			Platform: &peregrine.Platform{
 				ID: resp.Launch.Registration.Platform.ID,
@@ -147,19 +405,130 @@ func (s *Service) HandleOidcCallback(ctx context.Context, params peregrine.OIDCA
 			ProductFamilyCode: resp.Claims.ToolPlatform.ProductFamilyCode,
 			Version:           resp.Claims.ToolPlatform.Version,
 		})
+		end(err)
 		if err != nil {
-			return resp, fmt.Errorf(
-				"failed to upsert PlatformInstance by guid %s: %v", resp.Claims.ToolPlatform.GUID, err)
+			return resp, newError(StagePlatformInstanceUpsert, fmt.Errorf(
+				"failed to upsert PlatformInstance by guid %s: %v", resp.Claims.ToolPlatform.GUID, err,
+			))
 		}
 		resp.Launch.PlatformInstance = &platformInstance
+		s.config.Logger.DebugContext(ctx, "platform instance recorded", append(launchLogAttrs(resp.Launch),
+			slog.String("platform_instance_guid", platformInstance.GUID),
+		)...)
+	}
+
+	if err = s.checkEntitlement(ctx, &resp); err != nil {
+		return resp, newError(StageEntitlement, err)
+	}
+	if resp.Entitlement != nil {
+		s.config.Logger.DebugContext(ctx, "entitlement checked", append(launchLogAttrs(resp.Launch),
+			slog.String("entitlement_decision", string(resp.Entitlement.Decision)),
+		)...)
+	}
+
+	if launchDataRepo, ok := s.dataSvc.(peregrine.LaunchDataRepo); ok {
+		if err = s.upsertLaunchData(ctx, launchDataRepo, &resp); err != nil {
+			return resp, newError(StageLaunchData, err)
+		}
 	}
 
 	used := time.Now()
 	resp.Launch.Used = &used
-	_, err = s.dataSvc.UpdateLaunch(ctx, resp.Launch)
+	switch {
+	case encryptedState && s.config.Stateless:
+		// there is no Launch record to mark used, the NonceStore has already recorded the nonce as used
+		return resp, nil
+	case encryptedState:
+		return resp, s.createDeferredLaunch(ctx, &resp)
+	}
+
+	repoCtx, end := s.startRepoSpan(ctx, "UpdateLaunch")
+	_, err = s.dataSvc.UpdateLaunch(repoCtx, resp.Launch)
+	end(err)
 	if err != nil {
-		return resp, fmt.Errorf("failed to update launch %s: %v", resp.Launch.ID, err)
+		return resp, newError(StageUpdateLaunch, fmt.Errorf(
+			"failed to update launch %s: %v", resp.Launch.ID, err,
+		))
 	}
 
 	return resp, nil
 }
+
+// getStateLaunch returns the peregrine.Launch of the state along with the ToolConfig the state is verified with,
+// the tool identity (and its key) is resolved from the state's key id header and the state verified
+// before the launch is read from the data store
+func (s *Service) getStateLaunch(ctx context.Context, state string) (peregrine.Launch, ToolConfig, error) {
+	var launch peregrine.Launch
+
+	clientID, err := stateKeyID(state)
+	if err != nil {
+		return launch, ToolConfig{}, newError(StageValidateState, fmt.Errorf("failed to validate state: %v", err))
+	}
+
+	registration, toolCfg, err := s.stateToolConfig(ctx, clientID)
+	if err != nil {
+		return launch, toolCfg, err
+	}
+
+	verifiedState, err := validateState(toolCfg.JWTKeySecret, state)
+	if err != nil {
+		return launch, toolCfg, newError(StageValidateState, fmt.Errorf("failed to validate state: %v", err))
+	}
+
+	repoCtx, end := s.startRepoSpan(ctx, "GetLaunch")
+	launch, err = s.dataSvc.GetLaunch(repoCtx, verifiedState.launchID)
+	end(err)
+	if err != nil {
+		return launch, toolCfg, newError(StageGetLaunch, fmt.Errorf(
+			"failed to get launch %s: %v", verifiedState.launchID, err,
+		))
+	}
+	if launch.Registration == nil || launch.Registration.ID != registration.ID {
+		return launch, toolCfg, newError(StageValidateState, fmt.Errorf(
+			"failed to validate state: launch registration mismatch",
+		))
+	}
+
+	// the data store may not persist the TargetLinkURI of a launch created before it was added
+	switch launch.TargetLinkURI {
+	case verifiedState.targetLinkURI:
+	case "":
+		launch.TargetLinkURI = verifiedState.targetLinkURI
+	default:
+		return launch, toolCfg, newError(StageValidateState, fmt.Errorf(
+			"failed to validate state: target_link_uri mismatch",
+		))
+	}
+
+	return launch, toolCfg, nil
+}
+
+// createDeferredLaunch creates the used peregrine.Launch of a deferred login with the nonce carried in the state,
+// the data store must persist the given nonce so that it can not be reused
+func (s *Service) createDeferredLaunch(ctx context.Context, resp *HandleOidcCallbackResponse) error {
+	repoCtx, end := s.startRepoSpan(ctx, "CreateLaunch")
+	launch, err := s.dataSvc.CreateLaunch(repoCtx, resp.Launch)
+	end(err)
+	if err != nil {
+		return newError(StageCreateLaunch, fmt.Errorf("failed to create launch: %v", err))
+	}
+	if launch.Nonce != resp.Launch.Nonce {
+		return newError(StageCreateLaunch, fmt.Errorf(
+			"failed to create launch: data store did not persist the state nonce %s", resp.Launch.Nonce,
+		))
+	}
+	resp.Launch.ID = launch.ID
+
+	return nil
+}
+
+// PlatformKeySet returns the key set of the peregrine.Platform from the Service's key set cache, allowing other
+// platform signed messages (e.g. platform notifications) to be verified without fetching the key set again
+func (s *Service) PlatformKeySet(ctx context.Context, platform peregrine.Platform) (jwk.Set, error) {
+	keySet, err := s.platformJWKs(ctx, platform.Issuer, platform.KeySetURL)
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve %s keyset: %v", platform.KeySetURL, err)
+	}
+
+	return keySet, nil
+}
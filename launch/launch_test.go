@@ -2,6 +2,8 @@ package launch
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/rsa"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -25,11 +27,14 @@ const (
 	canvasTestLoginUrl       = "/canvaslms/api/lti/authorize_redirect"
 	testIssuer               = "https://stevenweathers.dev"
 	testTargetLinkURI        = "https://stevenweathers.dev/"
+	testCallbackURL          = "https://stevenweathers.dev/lti/callback"
 	testSubClaim             = "4cfa2adf-9389-425a-a7d1-436f987cdb11"
 	testClientID             = "150420000000000007"
 	testPlatformInstanceGUID = "someuuidforcanvaslms:canvas-lms"
 	testPlatformDeploymentID = "007:9ac4b5c1c2db02e7c70db53837fe8bd47a5e309c"
 	testJWTSecret            = "godofthunder"
+	testExtensionNamespace   = "https://www.instructure.com/"
+	testPlacementClaim       = "https://www.instructure.com/placement"
 )
 
 var (
@@ -47,12 +52,19 @@ var (
 
 func TestMain(m *testing.M) {
 	// Setup a mock JWK keyset and server
-	key, _ := jwk.FromRaw([]byte(testJWTSecret))
-	err := key.Set(jwk.KeyIDKey, "testkey")
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
 	if err != nil {
 		panic(err)
 	}
-	err = key.Set(jwk.AlgorithmKey, "HS256")
+	key, err := jwk.FromRaw(rsaKey)
+	if err != nil {
+		panic(err)
+	}
+	err = key.Set(jwk.KeyIDKey, "testkey")
+	if err != nil {
+		panic(err)
+	}
+	err = key.Set(jwk.AlgorithmKey, "RS256")
 	if err != nil {
 		panic(err)
 	}
@@ -104,6 +116,7 @@ func TestHandleOidcLoginHappyPath(t *testing.T) {
 	launchSvc := New(Config{
 		JWTKeySecret: testJWTSecret,
 		Issuer:       testIssuer,
+		CallbackURL:  testCallbackURL,
 	}, &mockStoreSvc{})
 
 	resp, err := launchSvc.HandleOidcLogin(context.Background(), peregrine.OIDCLoginRequestParams{
@@ -140,12 +153,12 @@ func TestHandleOidcLoginHappyPath(t *testing.T) {
 		t.Fatalf("expected OIDCLoginResponseParams.LTIMessageHint to be empty string")
 	}
 
-	launchID, err := validateState(launchSvc.config.JWTKeySecret, resp.OIDCLoginResponseParams.State)
+	ls, err := validateState(launchSvc.config.JWTKeySecret, resp.OIDCLoginResponseParams.State)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if launchID != testLaunchID {
-		t.Fatalf("expected OIDCLoginResponseParams.State to be generated JTW with launch id of %s got %s", testLaunchID, launchID)
+	if ls.launchID != testLaunchID {
+		t.Fatalf("expected OIDCLoginResponseParams.State to be generated JTW with launch id of %s got %s", testLaunchID, ls.launchID)
 	}
 }
 
@@ -154,6 +167,7 @@ func TestHandleOidcLoginHappyPathWithLTIMessageHint(t *testing.T) {
 	launchSvc := New(Config{
 		JWTKeySecret: testJWTSecret,
 		Issuer:       testIssuer,
+		CallbackURL:  testCallbackURL,
 	}, &mockStoreSvc{})
 
 	resp, err := launchSvc.HandleOidcLogin(context.Background(), peregrine.OIDCLoginRequestParams{
@@ -190,12 +204,12 @@ func TestHandleOidcLoginHappyPathWithLTIMessageHint(t *testing.T) {
 		t.Fatalf("expected OIDCLoginResponseParams.LTIMessageHint to be 42")
 	}
 
-	launchID, err := validateState(launchSvc.config.JWTKeySecret, resp.OIDCLoginResponseParams.State)
+	ls, err := validateState(launchSvc.config.JWTKeySecret, resp.OIDCLoginResponseParams.State)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if launchID != testLaunchID {
-		t.Fatalf("expected OIDCLoginResponseParams.State to be generated JTW with launch id of %s got %s", testLaunchID, launchID)
+	if ls.launchID != testLaunchID {
+		t.Fatalf("expected OIDCLoginResponseParams.State to be generated JTW with launch id of %s got %s", testLaunchID, ls.launchID)
 	}
 }
 
@@ -204,6 +218,7 @@ func TestHandleOidcLoginHappyPathWithDeploymentID(t *testing.T) {
 	launchSvc := New(Config{
 		JWTKeySecret: testJWTSecret,
 		Issuer:       testIssuer,
+		CallbackURL:  testCallbackURL,
 	}, &mockStoreSvc{})
 
 	resp, err := launchSvc.HandleOidcLogin(context.Background(), peregrine.OIDCLoginRequestParams{
@@ -240,12 +255,12 @@ func TestHandleOidcLoginHappyPathWithDeploymentID(t *testing.T) {
 		t.Fatalf("expected OIDCLoginResponseParams.LTIMessageHint to be empty string")
 	}
 
-	launchID, err := validateState(launchSvc.config.JWTKeySecret, resp.OIDCLoginResponseParams.State)
+	ls, err := validateState(launchSvc.config.JWTKeySecret, resp.OIDCLoginResponseParams.State)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if launchID != testLaunchID {
-		t.Fatalf("expected OIDCLoginResponseParams.State to be generated JTW with launch id of %s got %s", testLaunchID, launchID)
+	if ls.launchID != testLaunchID {
+		t.Fatalf("expected OIDCLoginResponseParams.State to be generated JTW with launch id of %s got %s", testLaunchID, ls.launchID)
 	}
 }
 
@@ -254,6 +269,7 @@ func TestHandleOidcLoginInvalidParams(t *testing.T) {
 	launchSvc := New(Config{
 		JWTKeySecret: testJWTSecret,
 		Issuer:       testIssuer,
+		CallbackURL:  testCallbackURL,
 	}, &mockStoreSvc{})
 
 	_, err := launchSvc.HandleOidcLogin(context.Background(), peregrine.OIDCLoginRequestParams{
@@ -274,6 +290,7 @@ func TestHandleOidcLoginClientIDNotFound(t *testing.T) {
 	launchSvc := New(Config{
 		JWTKeySecret: testJWTSecret,
 		Issuer:       testIssuer,
+		CallbackURL:  testCallbackURL,
 	}, &mockStoreSvcWithRegistrationNotFound{})
 
 	_, err := launchSvc.HandleOidcLogin(context.Background(), peregrine.OIDCLoginRequestParams{
@@ -294,6 +311,7 @@ func TestHandleOidcLoginIncorrectIssuer(t *testing.T) {
 	launchSvc := New(Config{
 		JWTKeySecret: testJWTSecret,
 		Issuer:       testIssuer,
+		CallbackURL:  testCallbackURL,
 	}, &mockStoreSvc{})
 
 	_, err := launchSvc.HandleOidcLogin(context.Background(), peregrine.OIDCLoginRequestParams{
@@ -314,6 +332,7 @@ func TestHandleOidcLoginUpsertDeploymentFailure(t *testing.T) {
 	launchSvc := New(Config{
 		JWTKeySecret: testJWTSecret,
 		Issuer:       testIssuer,
+		CallbackURL:  testCallbackURL,
 	}, &mockStoreSvcWithFailedDeploymentUpsert{})
 
 	_, err := launchSvc.HandleOidcLogin(context.Background(), peregrine.OIDCLoginRequestParams{
@@ -334,6 +353,7 @@ func TestHandleOidcLoginCreateLaunchFailure(t *testing.T) {
 	launchSvc := New(Config{
 		JWTKeySecret: testJWTSecret,
 		Issuer:       testIssuer,
+		CallbackURL:  testCallbackURL,
 	}, &mockStoreSvcWithFailedLaunchCreate{})
 
 	_, err := launchSvc.HandleOidcLogin(context.Background(), peregrine.OIDCLoginRequestParams{
@@ -354,9 +374,10 @@ func TestHandleOidcCallbackHappyPath(t *testing.T) {
 	launchSvc := New(Config{
 		JWTKeySecret: testJWTSecret,
 		Issuer:       testIssuer,
+		CallbackURL:  testCallbackURL,
 	}, &mockStoreSvc{})
 
-	state, err := createLaunchState(launchSvc.config.Issuer, launchSvc.config.JWTKeySecret, testLaunchID)
+	state, err := createLaunchState(launchSvc.config.Issuer, launchSvc.config.JWTKeySecret, testClientID, testLaunchID, testTargetLinkURI)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -377,7 +398,7 @@ func TestHandleOidcCallbackHappyPath(t *testing.T) {
 	if err != nil {
 		panic(err)
 	}
-	signedIdToken, err := jwt.Sign(tok, jwt.WithKey(jwa.HS256, testJwkKey))
+	signedIdToken, err := jwt.Sign(tok, jwt.WithKey(jwa.RS256, testJwkKey))
 	if err != nil {
 		panic(err)
 	}
@@ -400,9 +421,10 @@ func TestHandleOidcCallbackHappyPathWithLaunchDeploymentID(t *testing.T) {
 	launchSvc := New(Config{
 		JWTKeySecret: testJWTSecret,
 		Issuer:       testIssuer,
+		CallbackURL:  testCallbackURL,
 	}, &mockStoreSvc{})
 
-	state, err := createLaunchState(launchSvc.config.Issuer, launchSvc.config.JWTKeySecret, testLaunchWithDeploymentID)
+	state, err := createLaunchState(launchSvc.config.Issuer, launchSvc.config.JWTKeySecret, testClientID, testLaunchWithDeploymentID, testTargetLinkURI)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -423,7 +445,7 @@ func TestHandleOidcCallbackHappyPathWithLaunchDeploymentID(t *testing.T) {
 	if err != nil {
 		panic(err)
 	}
-	signedIdToken, err := jwt.Sign(tok, jwt.WithKey(jwa.HS256, testJwkKey))
+	signedIdToken, err := jwt.Sign(tok, jwt.WithKey(jwa.RS256, testJwkKey))
 	if err != nil {
 		panic(err)
 	}
@@ -446,9 +468,10 @@ func TestHandleOidcCallbackHappyPathWithPlatformInstanceID(t *testing.T) {
 	launchSvc := New(Config{
 		JWTKeySecret: testJWTSecret,
 		Issuer:       testIssuer,
+		CallbackURL:  testCallbackURL,
 	}, &mockStoreSvc{})
 
-	state, err := createLaunchState(launchSvc.config.Issuer, launchSvc.config.JWTKeySecret, testLaunchID)
+	state, err := createLaunchState(launchSvc.config.Issuer, launchSvc.config.JWTKeySecret, testClientID, testLaunchID, testTargetLinkURI)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -472,7 +495,7 @@ func TestHandleOidcCallbackHappyPathWithPlatformInstanceID(t *testing.T) {
 	if err != nil {
 		panic(err)
 	}
-	signedIdToken, err := jwt.Sign(tok, jwt.WithKey(jwa.HS256, testJwkKey))
+	signedIdToken, err := jwt.Sign(tok, jwt.WithKey(jwa.RS256, testJwkKey))
 	if err != nil {
 		panic(err)
 	}
@@ -490,11 +513,70 @@ func TestHandleOidcCallbackHappyPathWithPlatformInstanceID(t *testing.T) {
 	}
 }
 
+func TestHandleOidcCallbackHappyPathWithExtensionClaims(t *testing.T) {
+	t.Parallel()
+	launchSvc := New(Config{
+		JWTKeySecret: testJWTSecret,
+		Issuer:       testIssuer,
+		CallbackURL:  testCallbackURL,
+	}, &mockStoreSvc{})
+	launchSvc.RegisterClaimDecoder(testExtensionNamespace, func(claim string, value interface{}) (interface{}, error) {
+		return DecodeClaim[string](claim, value)
+	})
+
+	state, err := createLaunchState(launchSvc.config.Issuer, launchSvc.config.JWTKeySecret, testClientID, testLaunchID, testTargetLinkURI)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Create a mock id_token
+	tok, err := jwt.NewBuilder().
+		Issuer(canvasTestIssuer).
+		IssuedAt(time.Now()).
+		Audience([]string{testClientID}).
+		Subject(testSubClaim).
+		Expiration(time.Now().Add(time.Minute*10)).
+		Claim(nonceClaim, testNonce.String()).
+		Claim(ltiMessageTypeClaim, ltiMessageTypeClaimValue).
+		Claim(ltiVersionClaim, ltiVersionClaimValue).
+		Claim(ltiTargetLinkUriClaim, testTargetLinkURI).
+		Claim(ltiDeploymentIdClaim, testPlatformDeploymentID).
+		Claim(testPlacementClaim, "course_navigation").
+		Build()
+	if err != nil {
+		panic(err)
+	}
+	signedIdToken, err := jwt.Sign(tok, jwt.WithKey(jwa.RS256, testJwkKey))
+	if err != nil {
+		panic(err)
+	}
+
+	res, err := launchSvc.HandleOidcCallback(context.Background(), peregrine.OIDCAuthenticationResponse{
+		State:   state,
+		IDToken: string(signedIdToken),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.RawIDToken != string(signedIdToken) {
+		t.Fatal("expected RawIDToken to be the verified id_token")
+	}
+	if _, ok := res.RawClaims[testPlacementClaim]; !ok {
+		t.Fatalf("expected RawClaims to contain %s", testPlacementClaim)
+	}
+	placement, ok := Extension[string](res, testPlacementClaim)
+	if !ok || placement != "course_navigation" {
+		t.Fatalf("expected %s extension to equal course_navigation got %s", testPlacementClaim, placement)
+	}
+}
+
 func TestHandleOidcCallbackInvalidState(t *testing.T) {
 	t.Parallel()
 	launchSvc := New(Config{
 		JWTKeySecret: testJWTSecret,
 		Issuer:       testIssuer,
+		CallbackURL:  testCallbackURL,
 	}, &mockStoreSvc{})
 
 	_, err := launchSvc.HandleOidcCallback(context.Background(), peregrine.OIDCAuthenticationResponse{
@@ -506,14 +588,57 @@ func TestHandleOidcCallbackInvalidState(t *testing.T) {
 	}
 }
 
+type mockStoreSvcWithGetLaunchCount struct {
+	mockStoreSvc
+	getLaunchCalls int
+}
+
+func (s *mockStoreSvcWithGetLaunchCount) GetLaunch(ctx context.Context, id uuid.UUID) (peregrine.Launch, error) {
+	s.getLaunchCalls++
+	return s.mockStoreSvc.GetLaunch(ctx, id)
+}
+
+func TestHandleOidcCallbackForgedStateNotRead(t *testing.T) {
+	t.Parallel()
+	forged, err := createLaunchState(testIssuer, "notthetoolsecret", testClientID, testLaunchID, testTargetLinkURI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	withoutKid, err := jwt.Sign(jwt.New(), jwt.WithKey(jwa.HS256, []byte(testJWTSecret)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, state := range []string{forged, string(withoutKid)} {
+		store := &mockStoreSvcWithGetLaunchCount{}
+		launchSvc := New(Config{
+			JWTKeySecret: testJWTSecret,
+			Issuer:       testIssuer,
+			CallbackURL:  testCallbackURL,
+		}, store)
+
+		_, err = launchSvc.HandleOidcCallback(context.Background(), peregrine.OIDCAuthenticationResponse{
+			State:   state,
+			IDToken: signTestIDToken(t, testIDTokenBuilder()),
+		})
+		if ErrorStage(err) != StageValidateState {
+			t.Fatalf("expected error: %v", err)
+		}
+		if store.getLaunchCalls != 0 {
+			t.Fatalf("expected forged state to not get launch, got %d calls", store.getLaunchCalls)
+		}
+	}
+}
+
 func TestHandleOidcCallbackInvalidIDToken(t *testing.T) {
 	t.Parallel()
 	launchSvc := New(Config{
 		JWTKeySecret: testJWTSecret,
 		Issuer:       testIssuer,
+		CallbackURL:  testCallbackURL,
 	}, &mockStoreSvc{})
 
-	state, err := createLaunchState(launchSvc.config.Issuer, launchSvc.config.JWTKeySecret, testLaunchID)
+	state, err := createLaunchState(launchSvc.config.Issuer, launchSvc.config.JWTKeySecret, testClientID, testLaunchID, testTargetLinkURI)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -532,9 +657,10 @@ func TestHandleOidcCallbackLaunchIDNotFound(t *testing.T) {
 	launchSvc := New(Config{
 		JWTKeySecret: testJWTSecret,
 		Issuer:       testIssuer,
+		CallbackURL:  testCallbackURL,
 	}, &mockStoreSvc{})
 
-	state, err := createLaunchState(launchSvc.config.Issuer, launchSvc.config.JWTKeySecret, testDeploymentID)
+	state, err := createLaunchState(launchSvc.config.Issuer, launchSvc.config.JWTKeySecret, testClientID, testDeploymentID, testTargetLinkURI)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -555,7 +681,7 @@ func TestHandleOidcCallbackLaunchIDNotFound(t *testing.T) {
 	if err != nil {
 		panic(err)
 	}
-	signedIdToken, err := jwt.Sign(tok, jwt.WithKey(jwa.HS256, testJwkKey))
+	signedIdToken, err := jwt.Sign(tok, jwt.WithKey(jwa.RS256, testJwkKey))
 	if err != nil {
 		panic(err)
 	}
@@ -575,9 +701,10 @@ func TestHandleOidcCallbackDeploymentUpsertFailure(t *testing.T) {
 	launchSvc := New(Config{
 		JWTKeySecret: testJWTSecret,
 		Issuer:       testIssuer,
+		CallbackURL:  testCallbackURL,
 	}, &mockStoreSvcWithFailedDeploymentUpsert{})
 
-	state, err := createLaunchState(launchSvc.config.Issuer, launchSvc.config.JWTKeySecret, testLaunchID)
+	state, err := createLaunchState(launchSvc.config.Issuer, launchSvc.config.JWTKeySecret, testClientID, testLaunchID, testTargetLinkURI)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -598,7 +725,7 @@ func TestHandleOidcCallbackDeploymentUpsertFailure(t *testing.T) {
 	if err != nil {
 		panic(err)
 	}
-	signedIdToken, err := jwt.Sign(tok, jwt.WithKey(jwa.HS256, testJwkKey))
+	signedIdToken, err := jwt.Sign(tok, jwt.WithKey(jwa.RS256, testJwkKey))
 	if err != nil {
 		panic(err)
 	}
@@ -617,9 +744,10 @@ func TestHandleOidcCallbackWithUpdateLaunchFailure(t *testing.T) {
 	launchSvc := New(Config{
 		JWTKeySecret: testJWTSecret,
 		Issuer:       testIssuer,
+		CallbackURL:  testCallbackURL,
 	}, &mockStoreSvcWithFailedLaunchUpdate{})
 
-	state, err := createLaunchState(launchSvc.config.Issuer, launchSvc.config.JWTKeySecret, testLaunchID)
+	state, err := createLaunchState(launchSvc.config.Issuer, launchSvc.config.JWTKeySecret, testClientID, testLaunchID, testTargetLinkURI)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -640,7 +768,7 @@ func TestHandleOidcCallbackWithUpdateLaunchFailure(t *testing.T) {
 	if err != nil {
 		panic(err)
 	}
-	signedIdToken, err := jwt.Sign(tok, jwt.WithKey(jwa.HS256, testJwkKey))
+	signedIdToken, err := jwt.Sign(tok, jwt.WithKey(jwa.RS256, testJwkKey))
 	if err != nil {
 		panic(err)
 	}
@@ -660,9 +788,10 @@ func TestHandleOidcCallbackWithUpsertPlatformInstanceFailure(t *testing.T) {
 	launchSvc := New(Config{
 		JWTKeySecret: testJWTSecret,
 		Issuer:       testIssuer,
+		CallbackURL:  testCallbackURL,
 	}, &mockStoreSvcWithFailedPlatformInstanceUpsert{})
 
-	state, err := createLaunchState(launchSvc.config.Issuer, launchSvc.config.JWTKeySecret, testLaunchID)
+	state, err := createLaunchState(launchSvc.config.Issuer, launchSvc.config.JWTKeySecret, testClientID, testLaunchID, testTargetLinkURI)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -686,7 +815,7 @@ func TestHandleOidcCallbackWithUpsertPlatformInstanceFailure(t *testing.T) {
 	if err != nil {
 		panic(err)
 	}
-	signedIdToken, err := jwt.Sign(tok, jwt.WithKey(jwa.HS256, testJwkKey))
+	signedIdToken, err := jwt.Sign(tok, jwt.WithKey(jwa.RS256, testJwkKey))
 	if err != nil {
 		panic(err)
 	}
@@ -706,9 +835,10 @@ func TestHandleOidcCallbackInvalidSubjectClaim(t *testing.T) {
 	launchSvc := New(Config{
 		JWTKeySecret: testJWTSecret,
 		Issuer:       testIssuer,
+		CallbackURL:  testCallbackURL,
 	}, &mockStoreSvc{})
 
-	state, err := createLaunchState(launchSvc.config.Issuer, launchSvc.config.JWTKeySecret, testLaunchID)
+	state, err := createLaunchState(launchSvc.config.Issuer, launchSvc.config.JWTKeySecret, testClientID, testLaunchID, testTargetLinkURI)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -731,7 +861,7 @@ func TestHandleOidcCallbackInvalidSubjectClaim(t *testing.T) {
 	if err != nil {
 		panic(err)
 	}
-	signedIdToken, err := jwt.Sign(tok, jwt.WithKey(jwa.HS256, testJwkKey))
+	signedIdToken, err := jwt.Sign(tok, jwt.WithKey(jwa.RS256, testJwkKey))
 	if err != nil {
 		panic(err)
 	}
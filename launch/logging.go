@@ -0,0 +1,67 @@
+package launch
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/stevenweathers/peregrine-lti/peregrine"
+)
+
+// Log attribute keys used by the Service
+const (
+	logKeyIssuer         = "issuer"
+	logKeyClientID       = "client_id"
+	logKeyDeploymentID   = "deployment_id"
+	logKeyLaunchID       = "launch_id"
+	logKeyRegistrationID = "registration_id"
+	logKeyStage          = "stage"
+	logKeyRule           = "rule"
+	logKeyError          = "error"
+
+	// redactedValue replaces sensitive log attribute values unless Config LogSensitiveValues is set
+	redactedValue = "[REDACTED]"
+)
+
+// DiscardLogger returns a *slog.Logger dropping every record,
+// the default Logger of the packages of this module when no Config Logger is set
+func DiscardLogger() *slog.Logger {
+	return slog.New(discardHandler{})
+}
+
+// discardHandler is the slog.Handler of DiscardLogger
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+// sensitive returns the log attribute for a sensitive value (e.g. id_token, email or name)
+// redacting it unless Config LogSensitiveValues is set
+func (s *Service) sensitive(key string, value string) slog.Attr {
+	if value != "" && !s.config.LogSensitiveValues {
+		value = redactedValue
+	}
+	return slog.String(key, value)
+}
+
+// launchLogAttrs returns the issuer, client_id, deployment_id and launch_id log attributes of the launch
+func launchLogAttrs(launch peregrine.Launch) []any {
+	var issuer, clientID, deploymentID string
+	if launch.Registration != nil {
+		clientID = launch.Registration.ClientID
+		if launch.Registration.Platform != nil {
+			issuer = launch.Registration.Platform.Issuer
+		}
+	}
+	if launch.Deployment != nil {
+		deploymentID = launch.Deployment.PlatformDeploymentID
+	}
+
+	return []any{
+		slog.String(logKeyIssuer, issuer),
+		slog.String(logKeyClientID, clientID),
+		slog.String(logKeyDeploymentID, deploymentID),
+		slog.String(logKeyLaunchID, launch.ID.String()),
+	}
+}
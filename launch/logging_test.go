@@ -0,0 +1,111 @@
+package launch
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/stevenweathers/peregrine-lti/peregrine"
+)
+
+func TestHandleOidcLoginLogsDecisionPoints(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	launchSvc := New(Config{
+		JWTKeySecret: testJWTSecret,
+		Issuer:       testIssuer,
+		CallbackURL:  testCallbackURL,
+		Logger:       slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})),
+	}, &mockStoreSvc{})
+
+	_, err := launchSvc.HandleOidcLogin(context.Background(), peregrine.OIDCLoginRequestParams{
+		Issuer:          canvasTestIssuer,
+		LoginHint:       "32",
+		TargetLinkURI:   testTargetLinkURI,
+		ClientID:        testClientID,
+		LTIDeploymentID: testPlatformDeploymentID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logs := buf.String()
+	for _, expected := range []string{
+		`"msg":"registration found"`, `"msg":"deployment upserted"`, `"msg":"launch created"`,
+		`"client_id":"` + testClientID + `"`, `"deployment_id":"` + testPlatformDeploymentID + `"`,
+	} {
+		if !strings.Contains(logs, expected) {
+			t.Fatalf("expected logs to contain %s got %s", expected, logs)
+		}
+	}
+}
+
+func TestHandleOidcCallbackLogsRedactSensitiveValues(t *testing.T) {
+	t.Parallel()
+	idToken := signTestIDToken(t, testIDTokenBuilder().
+		Claim("name", "Thor Odinson").
+		Claim("email", "thor@asgard.test"))
+
+	for _, logSensitive := range []bool{false, true} {
+		var buf bytes.Buffer
+		launchSvc := New(Config{
+			JWTKeySecret:       testJWTSecret,
+			Issuer:             testIssuer,
+			CallbackURL:        testCallbackURL,
+			Logger:             slog.New(slog.NewJSONHandler(&buf, nil)),
+			LogSensitiveValues: logSensitive,
+		}, &mockStoreSvc{})
+
+		state, err := createLaunchState(testIssuer, testJWTSecret, testClientID, testLaunchID, testTargetLinkURI)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = launchSvc.HandleOidcCallback(context.Background(), peregrine.OIDCAuthenticationResponse{
+			State:   state,
+			IDToken: idToken,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		logs := buf.String()
+		if !strings.Contains(logs, `"msg":"launch completed"`) || !strings.Contains(logs, testLaunchID.String()) {
+			t.Fatalf("expected launch completed log got %s", logs)
+		}
+		if strings.Contains(logs, "thor@asgard.test") != logSensitive ||
+			strings.Contains(logs, "Thor Odinson") != logSensitive {
+			t.Fatalf("expected sensitive values to be logged %t got %s", logSensitive, logs)
+		}
+	}
+}
+
+func TestHandleOidcCallbackLogsIDTokenFailure(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	launchSvc := New(Config{
+		JWTKeySecret: testJWTSecret,
+		Issuer:       testIssuer,
+		CallbackURL:  testCallbackURL,
+		Logger:       slog.New(slog.NewJSONHandler(&buf, nil)),
+	}, &mockStoreSvc{})
+
+	state, err := createLaunchState(testIssuer, testJWTSecret, testClientID, testLaunchID, testTargetLinkURI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = launchSvc.HandleOidcCallback(context.Background(), peregrine.OIDCAuthenticationResponse{
+		State:   state,
+		IDToken: "not.a.jwt",
+	})
+	if err == nil {
+		t.Fatalf("expected error: %v", err)
+	}
+
+	logs := buf.String()
+	if !strings.Contains(logs, `"msg":"id_token validation failed"`) ||
+		!strings.Contains(logs, `"stage":"id_token"`) || !strings.Contains(logs, `"id_token":"[REDACTED]"`) {
+		t.Fatalf("expected redacted id_token failure logs got %s", logs)
+	}
+}
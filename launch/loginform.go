@@ -0,0 +1,102 @@
+package launch
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"sort"
+
+	"github.com/stevenweathers/peregrine-lti/peregrine"
+)
+
+// loginFormTemplate auto-submits the authentication request to the platform, the noscript button
+// lets the user submit the form when scripts are disabled
+var loginFormTemplate = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="referrer" content="no-referrer">
+<title>Launching</title>
+</head>
+<body>
+<form id="peregrine-login" method="post" action="{{.Action}}">
+{{- range .Fields}}
+<input type="hidden" name="{{.Name}}" value="{{.Value}}">
+{{- end}}
+<noscript><button type="submit">Continue</button></noscript>
+</form>
+<script{{if .Nonce}} nonce="{{.Nonce}}"{{end}}>document.getElementById("peregrine-login").submit();</script>
+</body>
+</html>
+`))
+
+type loginFormField struct {
+	Name  string
+	Value string
+}
+
+type loginFormData struct {
+	Action string
+	Fields []loginFormField
+	Nonce  string
+}
+
+// RenderLoginResponseForm writes an html page that auto-submits the authentication request as a POST form to the
+// peregrine.Platform AuthLoginURL, keeping the state, nonce and lti_message_hint out of the url,
+// callbackUrl is the redirect_uri used when the response has no RedirectURI and
+// cspNonce (OPTIONAL) is set as the nonce of the inline script for a Content-Security-Policy script-src nonce
+func RenderLoginResponseForm(
+	w io.Writer, response peregrine.OIDCLoginResponseParams, platformAuthLoginUrl, callbackUrl, cspNonce string,
+) error {
+	data := loginFormData{
+		Action: platformAuthLoginUrl,
+		Nonce:  cspNonce,
+	}
+	values := loginResponseValues(response, callbackUrl)
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range values[name] {
+			data.Fields = append(data.Fields, loginFormField{Name: name, Value: value})
+		}
+	}
+
+	if err := loginFormTemplate.Execute(w, data); err != nil {
+		return fmt.Errorf("failed to render OIDC login response form: %v", err)
+	}
+
+	return nil
+}
+
+// WriteLoginResponse sends the authentication request of the HandleOidcLoginResponse to the platform
+// with its AuthRequestMethod, either a GET redirect (see BuildLoginResponseRedirectURL)
+// or an auto-submitted POST form (see RenderLoginResponseForm)
+func WriteLoginResponse(
+	w http.ResponseWriter, r *http.Request, response HandleOidcLoginResponse, callbackUrl, cspNonce string,
+) error {
+	if response.AuthRequestMethod != peregrine.AuthRequestMethodPost {
+		redirURL, err := BuildLoginResponseRedirectURL(response.OIDCLoginResponseParams, response.RedirectURL, callbackUrl)
+		if err != nil {
+			return err
+		}
+		http.Redirect(w, r, redirURL, http.StatusFound)
+		return nil
+	}
+
+	var page bytes.Buffer
+	err := RenderLoginResponseForm(&page, response.OIDCLoginResponseParams, response.RedirectURL, callbackUrl, cspNonce)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.WriteHeader(http.StatusOK)
+	_, err = page.WriteTo(w)
+	return err
+}
@@ -0,0 +1,100 @@
+package launch
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stevenweathers/peregrine-lti/peregrine"
+)
+
+var testLoginResponseParams = peregrine.OIDCLoginResponseParams{
+	Scope:          "openid",
+	ResponseType:   "id_token",
+	ResponseMode:   "form_post",
+	Prompt:         "none",
+	ClientID:       "test_client_id",
+	LoginHint:      "test_login_hint",
+	LTIMessageHint: `"><script>alert(1)</script>`,
+	State:          "test_state",
+	Nonce:          "test_nonce",
+}
+
+func TestRenderLoginResponseForm(t *testing.T) {
+	t.Parallel()
+	var page bytes.Buffer
+	err := RenderLoginResponseForm(&page, testLoginResponseParams,
+		"https://canvas.test.instructure.com/api/lti/authorize_redirect", "/lti/callback", "cspnonce123",
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	body := page.String()
+	for _, expected := range []string{
+		`action="https://canvas.test.instructure.com/api/lti/authorize_redirect"`,
+		`<input type="hidden" name="state" value="test_state">`,
+		`<input type="hidden" name="redirect_uri" value="/lti/callback">`,
+		`<script nonce="cspnonce123">`,
+		`<noscript><button type="submit">Continue</button></noscript>`,
+	} {
+		if !strings.Contains(body, expected) {
+			t.Fatalf("expected login form to contain %s got %s", expected, body)
+		}
+	}
+	if strings.Contains(body, "<script>alert(1)</script>") {
+		t.Fatalf("expected lti_message_hint to be escaped got %s", body)
+	}
+}
+
+func TestRenderLoginResponseFormWithoutNonce(t *testing.T) {
+	t.Parallel()
+	var page bytes.Buffer
+	err := RenderLoginResponseForm(&page, testLoginResponseParams, "javascript:alert(1)", "/lti/callback", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	body := page.String()
+	if strings.Contains(body, "nonce=") || !strings.Contains(body, "<script>") {
+		t.Fatalf("expected script without nonce got %s", body)
+	}
+	if strings.Contains(body, `action="javascript:`) {
+		t.Fatalf("expected unsafe action url to be sanitized got %s", body)
+	}
+}
+
+func TestWriteLoginResponse(t *testing.T) {
+	t.Parallel()
+	r := httptest.NewRequest(http.MethodGet, "/lti/login", nil)
+
+	w := httptest.NewRecorder()
+	err := WriteLoginResponse(w, r, HandleOidcLoginResponse{
+		OIDCLoginResponseParams: testLoginResponseParams,
+		RedirectURL:             "https://canvas.test.instructure.com/api/lti/authorize_redirect",
+	}, "/lti/callback", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if w.Code != http.StatusFound || !strings.Contains(w.Header().Get("Location"), "state=test_state") {
+		t.Fatalf("expected redirect got %d %s", w.Code, w.Header().Get("Location"))
+	}
+
+	w = httptest.NewRecorder()
+	err = WriteLoginResponse(w, r, HandleOidcLoginResponse{
+		OIDCLoginResponseParams: testLoginResponseParams,
+		RedirectURL:             "https://canvas.test.instructure.com/api/lti/authorize_redirect",
+		AuthRequestMethod:       peregrine.AuthRequestMethodPost,
+	}, "/lti/callback", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if w.Code != http.StatusOK || w.Header().Get("Location") != "" {
+		t.Fatalf("expected form page got %d %s", w.Code, w.Header().Get("Location"))
+	}
+	if w.Header().Get("Cache-Control") != "no-store" || !strings.Contains(w.Body.String(), `method="post"`) {
+		t.Fatalf("expected uncached form page got %s", w.Body.String())
+	}
+}
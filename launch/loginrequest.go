@@ -0,0 +1,143 @@
+package launch
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+
+	"github.com/stevenweathers/peregrine-lti/internal/httpx"
+	"github.com/stevenweathers/peregrine-lti/peregrine"
+)
+
+// DefaultMaxLoginRequestBodySize is the default maximum size in bytes of a login initiation request body
+const DefaultMaxLoginRequestBodySize = 64 << 10
+
+// ParamSource is where a login initiation request parameter was read from
+type ParamSource string
+
+const (
+	// ParamSourceQuery is a parameter of the request url query
+	ParamSourceQuery ParamSource = "query"
+	// ParamSourceBody is a parameter of the form encoded request body
+	ParamSourceBody ParamSource = "body"
+	// ParamSourceQueryAndBody is a parameter of both the request url query and body
+	ParamSourceQueryAndBody ParamSource = "query_and_body"
+)
+
+// loginRequestParams are the login initiation request parameters mapped to peregrine.OIDCLoginRequestParams
+var loginRequestParams = map[string]func(p *peregrine.OIDCLoginRequestParams) *string{
+	"iss":               func(p *peregrine.OIDCLoginRequestParams) *string { return &p.Issuer },
+	"login_hint":        func(p *peregrine.OIDCLoginRequestParams) *string { return &p.LoginHint },
+	"target_link_uri":   func(p *peregrine.OIDCLoginRequestParams) *string { return &p.TargetLinkURI },
+	"lti_message_hint":  func(p *peregrine.OIDCLoginRequestParams) *string { return &p.LTIMessageHint },
+	"client_id":         func(p *peregrine.OIDCLoginRequestParams) *string { return &p.ClientID },
+	"lti_deployment_id": func(p *peregrine.OIDCLoginRequestParams) *string { return &p.LTIDeploymentID },
+}
+
+// LoginRequest is a parsed third-party login initiation request
+type LoginRequest struct {
+	// Params are the login initiation parameters to pass to HandleOidcLogin
+	Params peregrine.OIDCLoginRequestParams
+	// Sources are where each parameter of the request was read from keyed by parameter name
+	Sources map[string]ParamSource
+	// Extra are the parameters not mapped to Params, such as lti_storage_target or a platforms
+	// out of spec client_id or deployment_id variants
+	Extra url.Values
+}
+
+// ParseLoginRequest parses a login initiation request sent by the platform as either a GET with query parameters
+// or a POST with form encoded body parameters (or both), a parameter sent more than once with differing values or
+// lti_deployment_id and deployment_id with differing values are rejected with ErrConflictingLoginParam and a body larger than maxBodySize (defaults to
+// DefaultMaxLoginRequestBodySize when 0) is rejected with ErrLoginRequestTooLarge
+func ParseLoginRequest(r *http.Request, maxBodySize int64) (LoginRequest, error) {
+	req := LoginRequest{
+		Sources: map[string]ParamSource{},
+		Extra:   url.Values{},
+	}
+	if maxBodySize == 0 {
+		maxBodySize = DefaultMaxLoginRequestBodySize
+	}
+
+	query, err := url.ParseQuery(r.URL.RawQuery)
+	if err != nil {
+		return req, fmt.Errorf("failed to parse login request query: %v", err)
+	}
+	body, err := parseLoginRequestBody(r, maxBodySize)
+	if err != nil {
+		return req, err
+	}
+
+	values := url.Values{}
+	for name, vs := range query {
+		req.Sources[name] = ParamSourceQuery
+		values[name] = append(values[name], vs...)
+	}
+	for name, vs := range body {
+		if _, ok := req.Sources[name]; ok {
+			req.Sources[name] = ParamSourceQueryAndBody
+		} else {
+			req.Sources[name] = ParamSourceBody
+		}
+		values[name] = append(values[name], vs...)
+	}
+
+	for name, vs := range values {
+		for _, v := range vs[1:] {
+			if v != vs[0] {
+				return req, fmt.Errorf("login request param %s: %w", name, ErrConflictingLoginParam)
+			}
+		}
+		field, ok := loginRequestParams[name]
+		if !ok {
+			req.Extra[name] = vs
+			continue
+		}
+		*field(&req.Params) = vs[0]
+	}
+
+	// Canvas LMS does not follow LTI 1.3 spec for lti_deployment_id, see GetLoginParamsFromRequestFormValues
+	if deploymentID := req.Extra.Get("deployment_id"); deploymentID != "" {
+		if req.Params.LTIDeploymentID != "" && req.Params.LTIDeploymentID != deploymentID {
+			return req, fmt.Errorf("login request params lti_deployment_id and deployment_id: %w",
+				ErrConflictingLoginParam)
+		}
+		req.Params.LTIDeploymentID = deploymentID
+	}
+
+	return req, nil
+}
+
+// parseLoginRequestBody returns the form encoded body parameters of a POST request
+// leaving the request body readable by the caller
+func parseLoginRequestBody(r *http.Request, maxBodySize int64) (url.Values, error) {
+	if r.Method != http.MethodPost || r.Body == nil {
+		return url.Values{}, nil
+	}
+
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		mediaType, _, err := mime.ParseMediaType(ct)
+		if err != nil || mediaType != "application/x-www-form-urlencoded" {
+			return nil, fmt.Errorf("unsupported login request content type %s", ct)
+		}
+	}
+
+	body, err := httpx.ReadLimited(r.Body, maxBodySize)
+	if errors.Is(err, httpx.ErrTooLarge) {
+		return nil, fmt.Errorf("login request body exceeds %d bytes: %w", maxBodySize, ErrLoginRequestTooLarge)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read login request body: %v", err)
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse login request body: %v", err)
+	}
+
+	return values, nil
+}
@@ -0,0 +1,136 @@
+package launch
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseLoginRequestGet(t *testing.T) {
+	t.Parallel()
+	r := httptest.NewRequest(http.MethodGet,
+		"/lti/login?iss=test_iss&login_hint=32&target_link_uri=https%3A%2F%2Fstevenweathers.dev%2F"+
+			"&client_id=test_client_id&deployment_id=test_deployment_id&lti_storage_target=_parent", nil)
+
+	req, err := ParseLoginRequest(r, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if req.Params.Issuer != "test_iss" || req.Params.LoginHint != "32" || req.Params.ClientID != "test_client_id" {
+		t.Fatalf("unexpected params %+v", req.Params)
+	}
+	if req.Params.TargetLinkURI != testTargetLinkURI {
+		t.Fatalf("expected target_link_uri %s to equal %s", req.Params.TargetLinkURI, testTargetLinkURI)
+	}
+	if req.Params.LTIDeploymentID != "test_deployment_id" {
+		t.Fatalf("expected lti_deployment_id %s to equal test_deployment_id", req.Params.LTIDeploymentID)
+	}
+	if req.Extra.Get("lti_storage_target") != "_parent" {
+		t.Fatalf("expected lti_storage_target extra param got %v", req.Extra)
+	}
+	if req.Sources["iss"] != ParamSourceQuery {
+		t.Fatalf("expected iss source %s got %s", ParamSourceQuery, req.Sources["iss"])
+	}
+}
+
+func TestParseLoginRequestPostWithQuery(t *testing.T) {
+	t.Parallel()
+	body := "iss=test_iss&login_hint=32&target_link_uri=https%3A%2F%2Fstevenweathers.dev%2F&client_id=test_client_id"
+	r := httptest.NewRequest(http.MethodPost, "/lti/login?client_id=test_client_id&lti_message_hint=hint",
+		strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+
+	req, err := ParseLoginRequest(r, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if req.Params.Issuer != "test_iss" || req.Params.LTIMessageHint != "hint" {
+		t.Fatalf("unexpected params %+v", req.Params)
+	}
+	if req.Sources["iss"] != ParamSourceBody {
+		t.Fatalf("expected iss source %s got %s", ParamSourceBody, req.Sources["iss"])
+	}
+	if req.Sources["lti_message_hint"] != ParamSourceQuery {
+		t.Fatalf("expected lti_message_hint source %s got %s", ParamSourceQuery, req.Sources["lti_message_hint"])
+	}
+	if req.Sources["client_id"] != ParamSourceQueryAndBody {
+		t.Fatalf("expected client_id source %s got %s", ParamSourceQueryAndBody, req.Sources["client_id"])
+	}
+
+	read, _ := io.ReadAll(r.Body)
+	if string(read) != body {
+		t.Fatalf("expected request body to remain readable got %s", read)
+	}
+}
+
+func TestParseLoginRequestConflictingParam(t *testing.T) {
+	t.Parallel()
+	r := httptest.NewRequest(http.MethodPost, "/lti/login?iss=test_iss", strings.NewReader("iss=other_iss"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	_, err := ParseLoginRequest(r, 0)
+	if !errors.Is(err, ErrConflictingLoginParam) {
+		t.Fatalf("expected error: %v", err)
+	}
+}
+
+func TestParseLoginRequestConflictingDeploymentID(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name   string
+		query  string
+		errors bool
+	}{
+		{name: "differing values", query: "lti_deployment_id=test_deployment&deployment_id=other_deployment", errors: true},
+		{name: "duplicate deployment_id", query: "deployment_id=test_deployment&deployment_id=other_deployment", errors: true},
+		{name: "same values", query: "lti_deployment_id=test_deployment&deployment_id=test_deployment"},
+		{name: "deployment_id only", query: "deployment_id=test_deployment"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			r := httptest.NewRequest(http.MethodGet, "/lti/login?"+tt.query, nil)
+
+			req, err := ParseLoginRequest(r, 0)
+			if tt.errors {
+				if !errors.Is(err, ErrConflictingLoginParam) {
+					t.Fatalf("expected error: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if req.Params.LTIDeploymentID != "test_deployment" {
+				t.Fatalf("expected lti_deployment_id test_deployment got %s", req.Params.LTIDeploymentID)
+			}
+		})
+	}
+}
+
+func TestParseLoginRequestBodyTooLarge(t *testing.T) {
+	t.Parallel()
+	r := httptest.NewRequest(http.MethodPost, "/lti/login", strings.NewReader("iss="+strings.Repeat("a", 64)))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	_, err := ParseLoginRequest(r, 32)
+	if !errors.Is(err, ErrLoginRequestTooLarge) {
+		t.Fatalf("expected error: %v", err)
+	}
+}
+
+func TestParseLoginRequestUnsupportedContentType(t *testing.T) {
+	t.Parallel()
+	r := httptest.NewRequest(http.MethodPost, "/lti/login", strings.NewReader(`{"iss":"test_iss"}`))
+	r.Header.Set("Content-Type", "application/json")
+
+	_, err := ParseLoginRequest(r, 0)
+	if err == nil || !strings.Contains(err.Error(), "unsupported login request content type") {
+		t.Fatalf("expected error: %v", err)
+	}
+}
@@ -0,0 +1,102 @@
+package launch
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// NonceStore records the id_token nonces that have been used so that a replayed id_token is rejected,
+// any peregrine.ToolDataRepo implementing peregrine.NonceRepo is also a NonceStore
+type NonceStore interface {
+	// UseNonce should atomically record the nonce as used until expiresAt,
+	// reporting false when the nonce was already used and has not yet expired
+	UseNonce(ctx context.Context, nonce string, expiresAt time.Time) (bool, error)
+}
+
+// NonceStoreFunc is an adapter to allow the use of ordinary functions as a NonceStore
+type NonceStoreFunc func(ctx context.Context, nonce string, expiresAt time.Time) (bool, error)
+
+// UseNonce calls f(ctx, nonce, expiresAt)
+func (f NonceStoreFunc) UseNonce(ctx context.Context, nonce string, expiresAt time.Time) (bool, error) {
+	return f(ctx, nonce, expiresAt)
+}
+
+// MemoryNonceStore is an in-memory NonceStore, nonces are not shared across processes
+// so it is only suitable when a single instance of the tool handles callbacks
+type MemoryNonceStore struct {
+	now func() time.Time
+
+	mu        sync.Mutex
+	nonces    map[string]time.Time
+	lastSweep time.Time
+}
+
+// nonceSweepInterval is how often the MemoryNonceStore evicts expired nonces
+const nonceSweepInterval = time.Minute
+
+// NewMemoryNonceStore returns a new MemoryNonceStore
+func NewMemoryNonceStore() *MemoryNonceStore {
+	return &MemoryNonceStore{
+		now:       time.Now,
+		nonces:    make(map[string]time.Time),
+		lastSweep: time.Now(),
+	}
+}
+
+// UseNonce records the nonce as used until expiresAt reporting false when already used
+func (m *MemoryNonceStore) UseNonce(_ context.Context, nonce string, expiresAt time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	if now.Sub(m.lastSweep) >= nonceSweepInterval {
+		m.lastSweep = now
+		for n, exp := range m.nonces {
+			if !now.Before(exp) {
+				delete(m.nonces, n)
+			}
+		}
+	}
+
+	if exp, ok := m.nonces[nonce]; ok && now.Before(exp) {
+		return false, nil
+	}
+	m.nonces[nonce] = expiresAt
+
+	return true, nil
+}
+
+// nonceExpiresAt returns when a used nonce can be forgotten, the id_token exp (with the clock skew) capped at the
+// DefaultStateTTL as the nonce is bound to a state that expires within it, so that a platform controlled exp
+// can not keep the nonce in the NonceStore indefinitely
+func nonceExpiresAt(now time.Time, exp time.Time, clockSkew time.Duration) time.Time {
+	ceiling := now.Add(DefaultStateTTL)
+	if exp.IsZero() {
+		return ceiling
+	}
+	if expiresAt := exp.Add(clockSkew); expiresAt.Before(ceiling) {
+		return expiresAt
+	}
+
+	return ceiling
+}
+
+// useNonce checks and sets the id_token nonce with the NonceStore until expiresAt, see nonceExpiresAt
+func (s *Service) useNonce(ctx context.Context, nonce string, expiresAt time.Time) error {
+
+	ctx, span := s.config.Tracer.Start(ctx, "peregrine.UseNonce")
+	defer span.End()
+
+	ok, err := s.nonceStore.UseNonce(ctx, nonce, expiresAt)
+	if err != nil {
+		span.RecordError(err)
+		return newError(StageNonce, fmt.Errorf("failed to use nonce %s: %v", nonce, err))
+	}
+	if !ok {
+		return newError(StageNonce, fmt.Errorf("%w: nonce %s has already been used", ErrNonceReplayed, nonce))
+	}
+
+	return nil
+}
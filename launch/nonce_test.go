@@ -0,0 +1,232 @@
+package launch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stevenweathers/peregrine-lti/peregrine"
+)
+
+// mockStoreSvcStateless fails any launch record calls and implements peregrine.NonceRepo
+type mockStoreSvcStateless struct {
+	mockStoreSvc
+	mu     sync.Mutex
+	nonces map[string]time.Time
+}
+
+func (s *mockStoreSvcStateless) GetLaunch(ctx context.Context, id uuid.UUID) (peregrine.Launch, error) {
+	return peregrine.Launch{}, fmt.Errorf("unexpected GetLaunch for stateless launch")
+}
+
+func (s *mockStoreSvcStateless) CreateLaunch(ctx context.Context, launch peregrine.Launch) (peregrine.Launch, error) {
+	return launch, fmt.Errorf("unexpected CreateLaunch for stateless launch")
+}
+
+func (s *mockStoreSvcStateless) UpdateLaunch(ctx context.Context, launch peregrine.Launch) (peregrine.Launch, error) {
+	return launch, fmt.Errorf("unexpected UpdateLaunch for stateless launch")
+}
+
+func (s *mockStoreSvcStateless) UseNonce(ctx context.Context, nonce string, expiresAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.nonces[nonce]; ok {
+		return false, nil
+	}
+	s.nonces[nonce] = expiresAt
+	return true, nil
+}
+
+func TestMemoryNonceStore(t *testing.T) {
+	t.Parallel()
+	now := time.Now()
+	store := NewMemoryNonceStore()
+	store.now = func() time.Time { return now }
+	store.lastSweep = now
+
+	ok, err := store.UseNonce(context.Background(), testNonce.String(), now.Add(time.Minute))
+	if err != nil || !ok {
+		t.Fatalf("expected unused nonce to be used: %v", err)
+	}
+	ok, err = store.UseNonce(context.Background(), testNonce.String(), now.Add(time.Minute))
+	if err != nil || ok {
+		t.Fatalf("expected used nonce to be rejected: %v", err)
+	}
+
+	now = now.Add(nonceSweepInterval)
+	ok, err = store.UseNonce(context.Background(), uuid.NewString(), now.Add(time.Minute))
+	if err != nil || !ok {
+		t.Fatalf("expected unused nonce to be used: %v", err)
+	}
+	if _, exists := store.nonces[testNonce.String()]; exists {
+		t.Fatalf("expected expired nonce to be evicted")
+	}
+}
+
+func TestNonceExpiresAt(t *testing.T) {
+	t.Parallel()
+	now := time.Now()
+	ceiling := now.Add(DefaultStateTTL)
+
+	tests := []struct {
+		name     string
+		exp      time.Time
+		expected time.Time
+	}{
+		{name: "exp with skew", exp: now.Add(time.Minute), expected: now.Add(time.Minute + DefaultIDTokenClockSkew)},
+		{name: "far future exp is capped", exp: now.Add(365 * 24 * time.Hour), expected: ceiling},
+		{name: "missing exp", exp: time.Time{}, expected: ceiling},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := nonceExpiresAt(now, tt.exp, DefaultIDTokenClockSkew); !got.Equal(tt.expected) {
+				t.Fatalf("expected nonce expiry %v got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestStatelessLaunch(t *testing.T) {
+	t.Parallel()
+	dataSvc := &mockStoreSvcStateless{nonces: make(map[string]time.Time)}
+	launchSvc := New(Config{
+		JWTKeySecret: testJWTSecret,
+		Issuer:       testIssuer,
+		CallbackURL:  testCallbackURL,
+		Stateless:    true,
+	}, dataSvc)
+
+	loginResp, err := launchSvc.HandleOidcLogin(context.Background(), peregrine.OIDCLoginRequestParams{
+		Issuer:          canvasTestIssuer,
+		LoginHint:       "32",
+		TargetLinkURI:   testTargetLinkURI,
+		ClientID:        testClientID,
+		LTIDeploymentID: testPlatformDeploymentID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	callbackParams := peregrine.OIDCAuthenticationResponse{
+		State: loginResp.OIDCLoginResponseParams.State,
+		IDToken: signTestIDToken(t, testIDTokenBuilder().
+			Claim(nonceClaim, loginResp.OIDCLoginResponseParams.Nonce)),
+	}
+	res, err := launchSvc.HandleOidcCallback(context.Background(), callbackParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Launch.ID == uuid.Nil || res.Launch.Used == nil || res.Launch.Registration.ID != testRegistrationID {
+		t.Fatalf("expected launch from state got %+v", res.Launch)
+	}
+	if _, ok := dataSvc.nonces[loginResp.OIDCLoginResponseParams.Nonce]; !ok {
+		t.Fatalf("expected nonce to be recorded by the data store")
+	}
+
+	_, err = launchSvc.HandleOidcCallback(context.Background(), callbackParams)
+	if !errors.Is(err, ErrNonceReplayed) || ErrorStage(err) != StageNonce {
+		t.Fatalf("expected error: %v", err)
+	}
+}
+
+func TestStatelessLaunchMemoryNonceStore(t *testing.T) {
+	t.Parallel()
+	launchSvc := New(Config{
+		JWTKeySecret: testJWTSecret,
+		Issuer:       testIssuer,
+		CallbackURL:  testCallbackURL,
+		Stateless:    true,
+	}, &mockStoreSvc{})
+	if _, ok := launchSvc.nonceStore.(*MemoryNonceStore); !ok {
+		t.Fatalf("expected MemoryNonceStore when data store does not implement peregrine.NonceRepo")
+	}
+
+	loginResp, err := launchSvc.HandleOidcLogin(context.Background(), peregrine.OIDCLoginRequestParams{
+		Issuer:        canvasTestIssuer,
+		LoginHint:     "32",
+		TargetLinkURI: testTargetLinkURI,
+		ClientID:      testClientID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	callbackParams := peregrine.OIDCAuthenticationResponse{
+		State: loginResp.OIDCLoginResponseParams.State,
+		IDToken: signTestIDToken(t, testIDTokenBuilder().
+			Claim(nonceClaim, loginResp.OIDCLoginResponseParams.Nonce)),
+	}
+	if _, err = launchSvc.HandleOidcCallback(context.Background(), callbackParams); err != nil {
+		t.Fatal(err)
+	}
+	_, err = launchSvc.HandleOidcCallback(context.Background(), callbackParams)
+	if !errors.Is(err, ErrNonceReplayed) {
+		t.Fatalf("expected error: %v", err)
+	}
+}
+
+func TestHandleOidcCallbackNonceReplayed(t *testing.T) {
+	t.Parallel()
+	launchSvc := New(Config{
+		JWTKeySecret: testJWTSecret,
+		Issuer:       testIssuer,
+		CallbackURL:  testCallbackURL,
+	}, &mockStoreSvc{})
+
+	state, err := createLaunchState(testIssuer, testJWTSecret, testClientID, testLaunchID, testTargetLinkURI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	callbackParams := peregrine.OIDCAuthenticationResponse{
+		State:   state,
+		IDToken: signTestIDToken(t, testIDTokenBuilder()),
+	}
+
+	if _, err = launchSvc.HandleOidcCallback(context.Background(), callbackParams); err != nil {
+		t.Fatal(err)
+	}
+	_, err = launchSvc.HandleOidcCallback(context.Background(), callbackParams)
+	if !errors.Is(err, ErrNonceReplayed) || ErrorStage(err) != StageNonce {
+		t.Fatalf("expected error: %v", err)
+	}
+}
+
+func TestHandleOidcCallbackNonceStore(t *testing.T) {
+	t.Parallel()
+	var usedNonce string
+	var usedUntil time.Time
+	expiresAt := time.Now().Add(time.Minute * 5).Truncate(time.Second)
+	launchSvc := New(Config{
+		JWTKeySecret: testJWTSecret,
+		Issuer:       testIssuer,
+		CallbackURL:  testCallbackURL,
+		NonceStore: NonceStoreFunc(func(ctx context.Context, nonce string, expiresAt time.Time) (bool, error) {
+			usedNonce, usedUntil = nonce, expiresAt
+			return false, fmt.Errorf("nonce store forced failure")
+		}),
+	}, &mockStoreSvc{})
+
+	state, err := createLaunchState(testIssuer, testJWTSecret, testClientID, testLaunchID, testTargetLinkURI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = launchSvc.HandleOidcCallback(context.Background(), peregrine.OIDCAuthenticationResponse{
+		State:   state,
+		IDToken: signTestIDToken(t, testIDTokenBuilder().Expiration(expiresAt)),
+	})
+	if ErrorStage(err) != StageNonce || errors.Is(err, ErrNonceReplayed) {
+		t.Fatalf("expected error: %v", err)
+	}
+
+	if usedNonce != testNonce.String() || !usedUntil.Equal(expiresAt.Add(DefaultIDTokenClockSkew)) {
+		t.Fatalf("expected nonce %s to be used until the id_token exp with skew got %s %s", testNonce, usedNonce, usedUntil)
+	}
+}
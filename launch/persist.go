@@ -0,0 +1,95 @@
+package launch
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/stevenweathers/peregrine-lti/peregrine"
+)
+
+// upsertLaunchData persists the User, Context, ResourceLink and Membership of the launch,
+// contexts and resource links are only unique to a deployment so are skipped when the launch has no Deployment
+func (s *Service) upsertLaunchData(ctx context.Context, repo peregrine.LaunchDataRepo, resp *HandleOidcCallbackResponse) error {
+	claims := resp.Claims
+
+	if claims.SUB != "" {
+		repoCtx, end := s.startRepoSpan(ctx, "UpsertUserBySUB")
+		user, err := repo.UpsertUserBySUB(repoCtx, peregrine.User{
+			Registration: &peregrine.Registration{
+				ID: resp.Launch.Registration.ID,
+			},
+			SUB:        claims.SUB,
+			Name:       claims.Name,
+			GivenName:  claims.GivenName,
+			FamilyName: claims.FamilyName,
+			Email:      claims.Email,
+			Locale:     claims.Locale,
+		})
+		end(err)
+		if err != nil {
+			return fmt.Errorf("failed to upsert user by sub %s: %v", claims.SUB, err)
+		}
+		resp.User = &user
+	}
+
+	if resp.Launch.Deployment == nil {
+		return nil
+	}
+	deployment := &peregrine.Deployment{
+		ID: resp.Launch.Deployment.ID,
+	}
+
+	if claims.Context.ID != "" {
+		repoCtx, end := s.startRepoSpan(ctx, "UpsertContextByContextID")
+		lmsContext, err := repo.UpsertContextByContextID(repoCtx, peregrine.Context{
+			Deployment: deployment,
+			ContextID:  claims.Context.ID,
+			Type:       claims.Context.Type,
+			Label:      claims.Context.Label,
+			Title:      claims.Context.Title,
+		})
+		end(err)
+		if err != nil {
+			return fmt.Errorf("failed to upsert context by context id %s: %v", claims.Context.ID, err)
+		}
+		resp.Context = &lmsContext
+	}
+
+	if claims.ResourceLink.ID != "" {
+		repoCtx, end := s.startRepoSpan(ctx, "UpsertResourceLinkByResourceLinkID")
+		link, err := repo.UpsertResourceLinkByResourceLinkID(repoCtx, peregrine.ResourceLink{
+			Deployment:     deployment,
+			Context:        resp.Context,
+			ResourceLinkID: claims.ResourceLink.ID,
+			Title:          claims.ResourceLink.Title,
+			Description:    claims.ResourceLink.Description,
+		})
+		end(err)
+		if err != nil {
+			return fmt.Errorf("failed to upsert resource link by resource link id %s: %v", claims.ResourceLink.ID, err)
+		}
+		resp.ResourceLink = &link
+	}
+
+	if resp.User != nil && resp.Context != nil {
+		roles := claims.Roles
+		if roles == nil {
+			roles = []string{}
+		}
+		repoCtx, end := s.startRepoSpan(ctx, "UpsertMembership")
+		membership, err := repo.UpsertMembership(repoCtx, peregrine.Membership{
+			User:    resp.User,
+			Context: resp.Context,
+			Roles:   roles,
+		})
+		end(err)
+		if err != nil {
+			return fmt.Errorf(
+				"failed to upsert membership for user %s in context %s: %v", resp.User.ID, resp.Context.ID, err,
+			)
+		}
+		resp.Membership = &membership
+	}
+
+	return nil
+}
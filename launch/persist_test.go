@@ -0,0 +1,148 @@
+package launch
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stevenweathers/peregrine-lti/peregrine"
+)
+
+const (
+	contextClaim      = "https://purl.imsglobal.org/spec/lti/claim/context"
+	resourceLinkClaim = "https://purl.imsglobal.org/spec/lti/claim/resource_link"
+	rolesClaim        = "https://purl.imsglobal.org/spec/lti/claim/roles"
+	testContextID     = "c1d887f0a1a2b3c4"
+	testResourceLink  = "200d101f-2c14-434a-a0f3-57c2a42369fd"
+	testInstructor    = "http://purl.imsglobal.org/vocab/lis/v2/membership#Instructor"
+)
+
+var (
+	testUserID         = uuid.MustParse("0b0f9a2c-8c1b-4f5e-9b7c-3a3c9d3a1e11")
+	testContextUUID    = uuid.MustParse("f5a3d7b2-6b0c-4c5d-8e9f-1a2b3c4d5e6f")
+	testResourceLinkID = uuid.MustParse("9c8b7a6d-5e4f-4a3b-9c2d-1e0f9a8b7c6d")
+	testMembershipID   = uuid.MustParse("1a2b3c4d-5e6f-4a7b-8c9d-0e1f2a3b4c5d")
+)
+
+// mockStoreSvcWithLaunchData mocks a data store implementing peregrine.LaunchDataRepo
+type mockStoreSvcWithLaunchData struct {
+	mockStoreSvc
+	failMembership bool
+}
+
+func (s *mockStoreSvcWithLaunchData) UpsertUserBySUB(ctx context.Context, user peregrine.User) (peregrine.User, error) {
+	user.ID = testUserID
+	return user, nil
+}
+
+func (s *mockStoreSvcWithLaunchData) UpsertContextByContextID(ctx context.Context, lmsContext peregrine.Context) (peregrine.Context, error) {
+	lmsContext.ID = testContextUUID
+	return lmsContext, nil
+}
+
+func (s *mockStoreSvcWithLaunchData) UpsertResourceLinkByResourceLinkID(ctx context.Context, link peregrine.ResourceLink) (peregrine.ResourceLink, error) {
+	link.ID = testResourceLinkID
+	return link, nil
+}
+
+func (s *mockStoreSvcWithLaunchData) UpsertMembership(ctx context.Context, membership peregrine.Membership) (peregrine.Membership, error) {
+	if s.failMembership {
+		return membership, fmt.Errorf("upsert membership forced failure")
+	}
+	membership.ID = testMembershipID
+	return membership, nil
+}
+
+func TestHandleOidcCallbackUpsertsLaunchData(t *testing.T) {
+	t.Parallel()
+	launchSvc := New(Config{
+		JWTKeySecret: testJWTSecret,
+		Issuer:       testIssuer,
+		CallbackURL:  testCallbackURL,
+	}, &mockStoreSvcWithLaunchData{})
+
+	state, err := createLaunchState(testIssuer, testJWTSecret, testClientID, testLaunchID, testTargetLinkURI)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := launchSvc.HandleOidcCallback(context.Background(), peregrine.OIDCAuthenticationResponse{
+		State: state,
+		IDToken: signTestIDToken(t, testIDTokenBuilder().
+			Claim(contextClaim, map[string]interface{}{"id": testContextID, "title": "Intro to Falconry"}).
+			Claim(resourceLinkClaim, map[string]interface{}{"id": testResourceLink}).
+			Claim(rolesClaim, []string{testInstructor})),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.User == nil || res.User.ID != testUserID || res.User.SUB != testSubClaim {
+		t.Fatalf("expected launch user to be upserted got %+v", res.User)
+	}
+	if res.Context == nil || res.Context.ContextID != testContextID || res.Context.Deployment.ID != testDeploymentID {
+		t.Fatalf("expected launch context to be upserted got %+v", res.Context)
+	}
+	if res.ResourceLink == nil || res.ResourceLink.Context.ID != testContextUUID {
+		t.Fatalf("expected launch resource link to be upserted got %+v", res.ResourceLink)
+	}
+	if res.Membership == nil || res.Membership.Roles[0] != testInstructor {
+		t.Fatalf("expected launch membership to be upserted got %+v", res.Membership)
+	}
+}
+
+func TestHandleOidcCallbackUpsertsLaunchDataAnonymous(t *testing.T) {
+	t.Parallel()
+	launchSvc := New(Config{
+		JWTKeySecret: testJWTSecret,
+		Issuer:       testIssuer,
+		CallbackURL:  testCallbackURL,
+	}, &mockStoreSvcWithLaunchData{})
+
+	state, err := createLaunchState(testIssuer, testJWTSecret, testClientID, testLaunchID, testTargetLinkURI)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := launchSvc.HandleOidcCallback(context.Background(), peregrine.OIDCAuthenticationResponse{
+		State: state,
+		IDToken: signTestIDToken(t, testIDTokenBuilder().
+			Subject("").
+			Claim(contextClaim, map[string]interface{}{"id": testContextID})),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.User != nil || res.Membership != nil {
+		t.Fatalf("expected no user or membership for anonymous launch")
+	}
+	if res.Context == nil {
+		t.Fatalf("expected launch context to be upserted for anonymous launch")
+	}
+}
+
+func TestHandleOidcCallbackUpsertMembershipFailure(t *testing.T) {
+	t.Parallel()
+	launchSvc := New(Config{
+		JWTKeySecret: testJWTSecret,
+		Issuer:       testIssuer,
+		CallbackURL:  testCallbackURL,
+	}, &mockStoreSvcWithLaunchData{failMembership: true})
+
+	state, err := createLaunchState(testIssuer, testJWTSecret, testClientID, testLaunchID, testTargetLinkURI)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = launchSvc.HandleOidcCallback(context.Background(), peregrine.OIDCAuthenticationResponse{
+		State: state,
+		IDToken: signTestIDToken(t, testIDTokenBuilder().
+			Claim(contextClaim, map[string]interface{}{"id": testContextID})),
+	})
+	if err == nil || !strings.Contains(err.Error(), "upsert membership forced failure") {
+		t.Fatalf("expected error: %v", err)
+	}
+}
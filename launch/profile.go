@@ -0,0 +1,74 @@
+package launch
+
+// RuleSeverity is how a failed IDTokenRule is handled
+type RuleSeverity string
+
+const (
+	// SeverityError fails the launch
+	SeverityError RuleSeverity = "error"
+	// SeverityWarning reports the failed rule in the HandleOidcCallbackResponse Warnings
+	SeverityWarning RuleSeverity = "warning"
+	// SeverityIgnore skips the rule
+	SeverityIgnore RuleSeverity = "ignore"
+)
+
+// ValidationProfile sets the RuleSeverity of the id_token validation rules, a rule not in Rules is a SeverityError
+type ValidationProfile struct {
+	// Name identifies the profile
+	Name string
+	// Rules are the severities of the rules
+	Rules map[IDTokenRule]RuleSeverity
+}
+
+// StrictProfile fails the launch on any failed rule, as required for 1EdTech certification
+var StrictProfile = ValidationProfile{
+	Name:  "strict",
+	Rules: map[IDTokenRule]RuleSeverity{},
+}
+
+// LenientProfile tolerates known platform deviations from the specification, reporting them as warnings
+var LenientProfile = ValidationProfile{
+	Name: "lenient",
+	Rules: map[IDTokenRule]RuleSeverity{
+		RuleSubjectRequired: SeverityWarning,
+		RuleRoles:           SeverityWarning,
+		RuleResourceLinkID:  SeverityWarning,
+	},
+}
+
+// securityRules are the rules that always fail the launch regardless of the ValidationProfile or overrides
+var securityRules = map[IDTokenRule]bool{
+	RuleAlgorithm:       true,
+	RuleSignature:       true,
+	RuleIssuer:          true,
+	RuleAudience:        true,
+	RuleAuthorizedParty: true,
+	RuleExpiration:      true,
+	RuleNotBefore:       true,
+	RuleIssuedAt:        true,
+	RuleNonce:           true,
+	RuleDeploymentID:    true,
+	RuleMessageType:     true,
+	RuleClaims:          true,
+}
+
+// severities returns the rule severities of the profile with the overrides applied,
+// the zero value ValidationProfile is the LenientProfile
+func (p ValidationProfile) severities(overrides map[IDTokenRule]RuleSeverity) map[IDTokenRule]RuleSeverity {
+	if p.Rules == nil {
+		p = LenientProfile
+	}
+
+	severities := make(map[IDTokenRule]RuleSeverity, len(p.Rules)+len(overrides))
+	for rule, severity := range p.Rules {
+		severities[rule] = severity
+	}
+	for rule, severity := range overrides {
+		severities[rule] = severity
+	}
+	for rule := range securityRules {
+		delete(severities, rule)
+	}
+
+	return severities
+}
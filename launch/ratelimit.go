@@ -0,0 +1,49 @@
+package launch
+
+import (
+	"context"
+)
+
+type remoteIPContextKey struct{}
+
+// RateLimitKey identifies the source of a login request
+type RateLimitKey struct {
+	// ClientID is the login requests client_id, empty when omitted by the Platform
+	ClientID string
+	// Issuer is the login requests iss
+	Issuer string
+	// RemoteIP is the IP address of the login request set with ContextWithRemoteIP, empty when not set
+	RemoteIP string
+}
+
+// RateLimiter limits login requests before any data store calls are made
+type RateLimiter interface {
+	// Allow reports whether the login request identified by key may proceed
+	Allow(ctx context.Context, key RateLimitKey) bool
+}
+
+// ContextWithRemoteIP returns a copy of ctx carrying the remote IP address of the incoming request
+// (e.g. derived from the *http.Request RemoteAddr) for use by a RateLimiter
+func ContextWithRemoteIP(ctx context.Context, remoteIP string) context.Context {
+	return context.WithValue(ctx, remoteIPContextKey{}, remoteIP)
+}
+
+// RemoteIPFromContext returns the remote IP address set by ContextWithRemoteIP
+func RemoteIPFromContext(ctx context.Context) (string, bool) {
+	remoteIP, ok := ctx.Value(remoteIPContextKey{}).(string)
+	return remoteIP, ok
+}
+
+// allowLogin checks the configured RateLimiter allows the login request
+func (s *Service) allowLogin(ctx context.Context, clientID string, issuer string) bool {
+	if s.config.RateLimiter == nil {
+		return true
+	}
+
+	remoteIP, _ := RemoteIPFromContext(ctx)
+	return s.config.RateLimiter.Allow(ctx, RateLimitKey{
+		ClientID: clientID,
+		Issuer:   issuer,
+		RemoteIP: remoteIP,
+	})
+}
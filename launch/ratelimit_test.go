@@ -0,0 +1,245 @@
+package launch
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stevenweathers/peregrine-lti/peregrine"
+)
+
+// mockRateLimiter allows the first n login requests recording their keys
+type mockRateLimiter struct {
+	mu      sync.Mutex
+	allowed int
+	keys    []RateLimitKey
+}
+
+func (l *mockRateLimiter) Allow(ctx context.Context, key RateLimitKey) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.keys = append(l.keys, key)
+	if l.allowed == 0 {
+		return false
+	}
+	l.allowed--
+	return true
+}
+
+// mockStoreSvcWithDeferredLaunch persists the nonce given to CreateLaunch and counts launches created
+type mockStoreSvcWithDeferredLaunch struct {
+	mockStoreSvc
+	mu        sync.Mutex
+	created   []peregrine.Launch
+	dropNonce bool
+}
+
+func (s *mockStoreSvcWithDeferredLaunch) CreateLaunch(ctx context.Context, launch peregrine.Launch) (
+	peregrine.Launch, error,
+) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	launch.ID = testLaunchID
+	if s.dropNonce {
+		launch.Nonce = testNonce
+	}
+	s.created = append(s.created, launch)
+	return launch, nil
+}
+
+func TestHandleOidcLoginRateLimited(t *testing.T) {
+	t.Parallel()
+	limiter := &mockRateLimiter{allowed: 1}
+	launchSvc := New(Config{
+		JWTKeySecret: testJWTSecret,
+		Issuer:       testIssuer,
+		CallbackURL:  testCallbackURL,
+		RateLimiter:  limiter,
+	}, &mockStoreSvc{})
+
+	ctx := ContextWithRemoteIP(context.Background(), "203.0.113.7")
+	params := peregrine.OIDCLoginRequestParams{
+		Issuer:        canvasTestIssuer,
+		LoginHint:     "32",
+		TargetLinkURI: testTargetLinkURI,
+		ClientID:      testClientID,
+	}
+
+	if _, err := launchSvc.HandleOidcLogin(ctx, params); err != nil {
+		t.Fatal(err)
+	}
+	_, err := launchSvc.HandleOidcLogin(ctx, params)
+	if !errors.Is(err, ErrRateLimited) || ErrorStage(err) != StageRateLimit {
+		t.Fatalf("expected error: %v", err)
+	}
+
+	expected := RateLimitKey{ClientID: testClientID, Issuer: canvasTestIssuer, RemoteIP: "203.0.113.7"}
+	if len(limiter.keys) != 2 || limiter.keys[1] != expected {
+		t.Fatalf("expected rate limit key %+v got %+v", expected, limiter.keys)
+	}
+}
+
+func TestDeferLaunchCreation(t *testing.T) {
+	t.Parallel()
+	dataSvc := &mockStoreSvcWithDeferredLaunch{}
+	launchSvc := New(Config{
+		JWTKeySecret:        testJWTSecret,
+		Issuer:              testIssuer,
+		CallbackURL:         testCallbackURL,
+		DeferLaunchCreation: true,
+	}, dataSvc)
+
+	loginResp, err := launchSvc.HandleOidcLogin(context.Background(), peregrine.OIDCLoginRequestParams{
+		Issuer:          canvasTestIssuer,
+		LoginHint:       "32",
+		TargetLinkURI:   testTargetLinkURI,
+		ClientID:        testClientID,
+		LTIDeploymentID: testPlatformDeploymentID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dataSvc.created) != 0 {
+		t.Fatalf("expected login not to create a launch")
+	}
+	if strings.Count(loginResp.OIDCLoginResponseParams.State, ".") != 4 {
+		t.Fatalf("expected encrypted state got %s", loginResp.OIDCLoginResponseParams.State)
+	}
+	if strings.Contains(loginResp.OIDCLoginResponseParams.State, loginResp.OIDCLoginResponseParams.Nonce) {
+		t.Fatalf("expected nonce not to be readable from state")
+	}
+
+	res, err := launchSvc.HandleOidcCallback(context.Background(), peregrine.OIDCAuthenticationResponse{
+		State: loginResp.OIDCLoginResponseParams.State,
+		IDToken: signTestIDToken(t, testIDTokenBuilder().
+			Claim(nonceClaim, loginResp.OIDCLoginResponseParams.Nonce)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(dataSvc.created) != 1 || dataSvc.created[0].Used == nil {
+		t.Fatalf("expected callback to create a used launch got %+v", dataSvc.created)
+	}
+	if res.Launch.ID != testLaunchID || res.Launch.Nonce.String() != loginResp.OIDCLoginResponseParams.Nonce {
+		t.Fatalf("expected created launch with state nonce got %+v", res.Launch)
+	}
+	if res.Launch.Deployment == nil || res.Launch.Deployment.ID != testDeploymentID {
+		t.Fatalf("expected deployment to be upserted at callback got %+v", res.Launch.Deployment)
+	}
+}
+
+func TestEncryptedStateNotAcceptedByDefault(t *testing.T) {
+	t.Parallel()
+	deferredSvc := New(Config{
+		JWTKeySecret:        testJWTSecret,
+		Issuer:              testIssuer,
+		CallbackURL:         testCallbackURL,
+		DeferLaunchCreation: true,
+	}, &mockStoreSvcWithDeferredLaunch{})
+	loginResp, err := deferredSvc.HandleOidcLogin(context.Background(), peregrine.OIDCLoginRequestParams{
+		Issuer:        canvasTestIssuer,
+		LoginHint:     "32",
+		TargetLinkURI: testTargetLinkURI,
+		ClientID:      testClientID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the same tool without DeferLaunchCreation or Stateless only accepts the JWS state
+	launchSvc := New(Config{
+		JWTKeySecret: testJWTSecret,
+		Issuer:       testIssuer,
+		CallbackURL:  testCallbackURL,
+	}, &mockStoreSvcWithDeferredLaunch{})
+	_, err = launchSvc.HandleOidcCallback(context.Background(), peregrine.OIDCAuthenticationResponse{
+		State: loginResp.OIDCLoginResponseParams.State,
+		IDToken: signTestIDToken(t, testIDTokenBuilder().
+			Claim(nonceClaim, loginResp.OIDCLoginResponseParams.Nonce)),
+	})
+	if ErrorStage(err) != StageValidateState {
+		t.Fatalf("expected error: %v", err)
+	}
+}
+
+func TestEncryptedStateKeyDerivation(t *testing.T) {
+	t.Parallel()
+	key, err := encryptedStateKey(testJWTSecret)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(key) != 32 {
+		t.Fatalf("expected a 256 bit key got %d bytes", len(key))
+	}
+	if digest := sha256.Sum256([]byte(testJWTSecret)); bytes.Equal(key, digest[:]) {
+		t.Fatal("expected the key to be derived with HKDF")
+	}
+}
+
+func TestDeferLaunchCreationInvalidState(t *testing.T) {
+	t.Parallel()
+	launchSvc := New(Config{
+		JWTKeySecret:        testJWTSecret,
+		Issuer:              testIssuer,
+		CallbackURL:         testCallbackURL,
+		DeferLaunchCreation: true,
+	}, &mockStoreSvcWithDeferredLaunch{})
+
+	loginResp, err := launchSvc.HandleOidcLogin(context.Background(), peregrine.OIDCLoginRequestParams{
+		Issuer:        canvasTestIssuer,
+		LoginHint:     "32",
+		TargetLinkURI: testTargetLinkURI,
+		ClientID:      testClientID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otherSvc := New(Config{
+		JWTKeySecret:        "othersecret",
+		Issuer:              testIssuer,
+		CallbackURL:         testCallbackURL,
+		DeferLaunchCreation: true,
+	}, &mockStoreSvcWithDeferredLaunch{})
+	_, err = otherSvc.HandleOidcCallback(context.Background(), peregrine.OIDCAuthenticationResponse{
+		State:   loginResp.OIDCLoginResponseParams.State,
+		IDToken: signTestIDToken(t, testIDTokenBuilder()),
+	})
+	if ErrorStage(err) != StageValidateState || !strings.Contains(err.Error(), "failed to validate state:") {
+		t.Fatalf("expected error: %v", err)
+	}
+}
+
+func TestDeferLaunchCreationNonceNotPersisted(t *testing.T) {
+	t.Parallel()
+	launchSvc := New(Config{
+		JWTKeySecret:        testJWTSecret,
+		Issuer:              testIssuer,
+		CallbackURL:         testCallbackURL,
+		DeferLaunchCreation: true,
+	}, &mockStoreSvcWithDeferredLaunch{dropNonce: true})
+
+	loginResp, err := launchSvc.HandleOidcLogin(context.Background(), peregrine.OIDCLoginRequestParams{
+		Issuer:        canvasTestIssuer,
+		LoginHint:     "32",
+		TargetLinkURI: testTargetLinkURI,
+		ClientID:      testClientID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = launchSvc.HandleOidcCallback(context.Background(), peregrine.OIDCAuthenticationResponse{
+		State: loginResp.OIDCLoginResponseParams.State,
+		IDToken: signTestIDToken(t, testIDTokenBuilder().
+			Claim(nonceClaim, loginResp.OIDCLoginResponseParams.Nonce)),
+	})
+	if ErrorStage(err) != StageCreateLaunch || !strings.Contains(err.Error(), "did not persist the state nonce") {
+		t.Fatalf("expected error: %v", err)
+	}
+}
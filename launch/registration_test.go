@@ -0,0 +1,103 @@
+package launch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stevenweathers/peregrine-lti/peregrine"
+)
+
+const testOtherClientID = "150420000000000008"
+
+// mockStoreSvcWithIssuerLookup mocks a data store supporting peregrine.RegistrationIssuerRepo
+// where the issuer has two registrations only one of which has the test deployment
+type mockStoreSvcWithIssuerLookup struct {
+	mockStoreSvc
+}
+
+func (s *mockStoreSvcWithIssuerLookup) GetRegistrationsByIssuer(ctx context.Context, issuer string, platformDeploymentID string) ([]peregrine.Registration, error) {
+	if issuer != canvasTestIssuer {
+		return nil, nil
+	}
+	registrations := []peregrine.Registration{{
+		ID:       testRegistrationID,
+		ClientID: testClientID,
+		Platform: &happyPathPlatform,
+	}}
+	if platformDeploymentID == testPlatformDeploymentID {
+		return registrations, nil
+	}
+	if platformDeploymentID != "" {
+		return nil, nil
+	}
+
+	return append(registrations, peregrine.Registration{
+		ID:       uuid.MustParse("2f1b6e6c-5ad6-4c3e-9b56-6a8d0c1a9f43"),
+		ClientID: testOtherClientID,
+		Platform: &happyPathPlatform,
+	}), nil
+}
+
+func TestHandleOidcLoginRegistrationByIssuerAndDeployment(t *testing.T) {
+	t.Parallel()
+	launchSvc := New(Config{
+		JWTKeySecret: testJWTSecret,
+		Issuer:       testIssuer,
+		CallbackURL:  testCallbackURL,
+	}, &mockStoreSvcWithIssuerLookup{})
+
+	resp, err := launchSvc.HandleOidcLogin(context.Background(), peregrine.OIDCLoginRequestParams{
+		Issuer:          canvasTestIssuer,
+		LoginHint:       "32",
+		TargetLinkURI:   testTargetLinkURI,
+		LTIDeploymentID: testPlatformDeploymentID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.OIDCLoginResponseParams.ClientID != testClientID {
+		t.Fatalf("expected OIDCLoginResponseParams.ClientID %s to equal %s", resp.OIDCLoginResponseParams.ClientID, testClientID)
+	}
+}
+
+func TestHandleOidcLoginRegistrationByIssuerAmbiguous(t *testing.T) {
+	t.Parallel()
+	launchSvc := New(Config{
+		JWTKeySecret: testJWTSecret,
+		Issuer:       testIssuer,
+		CallbackURL:  testCallbackURL,
+	}, &mockStoreSvcWithIssuerLookup{})
+
+	_, err := launchSvc.HandleOidcLogin(context.Background(), peregrine.OIDCLoginRequestParams{
+		Issuer:          canvasTestIssuer,
+		LoginHint:       "32",
+		TargetLinkURI:   testTargetLinkURI,
+		LTIDeploymentID: "unknown-deployment",
+	})
+	if !errors.Is(err, ErrAmbiguousRegistration) {
+		t.Fatalf("expected ErrAmbiguousRegistration: %v", err)
+	}
+}
+
+func TestHandleOidcLoginRegistrationByIssuerNotFound(t *testing.T) {
+	t.Parallel()
+	launchSvc := New(Config{
+		JWTKeySecret: testJWTSecret,
+		Issuer:       testIssuer,
+		CallbackURL:  testCallbackURL,
+	}, &mockStoreSvcWithIssuerLookup{})
+
+	_, err := launchSvc.HandleOidcLogin(context.Background(), peregrine.OIDCLoginRequestParams{
+		Issuer:        "https://canvas.instructure.com",
+		LoginHint:     "32",
+		TargetLinkURI: testTargetLinkURI,
+	})
+	expected := fmt.Sprintf("failed to get registration by issuer https://canvas.instructure.com: %s", ErrRegistrationNotFound)
+	if !errors.Is(err, ErrRegistrationNotFound) || err.Error() != expected {
+		t.Fatalf("expected ErrRegistrationNotFound: %v", err)
+	}
+}
@@ -0,0 +1,35 @@
+package launch
+
+import (
+	"fmt"
+
+	"github.com/stevenweathers/peregrine-lti/peregrine"
+)
+
+// checkRegistrationStatus returns ErrRegistrationInactive when the registration is not active
+func checkRegistrationStatus(registration peregrine.Registration) error {
+	if !registration.Status.IsActive() {
+		return fmt.Errorf("registration %s is %s: %w", registration.ClientID, registration.Status, ErrRegistrationInactive)
+	}
+
+	return nil
+}
+
+// checkDeploymentAllowed returns ErrDeploymentNotAllowed when the registration has a DeploymentAllowlist
+// without the platform deployment id
+func checkDeploymentAllowed(registration peregrine.Registration, platformDeploymentID string) error {
+	if len(registration.DeploymentAllowlist) > 0 && !containsString(registration.DeploymentAllowlist, platformDeploymentID) {
+		return fmt.Errorf("deployment %s: %w", platformDeploymentID, ErrDeploymentNotAllowed)
+	}
+
+	return nil
+}
+
+// checkDeploymentStatus returns ErrDeploymentInactive when the deployment is not active
+func checkDeploymentStatus(deployment *peregrine.Deployment) error {
+	if deployment != nil && !deployment.Status.IsActive() {
+		return fmt.Errorf("deployment %s is %s: %w", deployment.PlatformDeploymentID, deployment.Status, ErrDeploymentInactive)
+	}
+
+	return nil
+}
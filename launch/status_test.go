@@ -0,0 +1,183 @@
+package launch
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stevenweathers/peregrine-lti/peregrine"
+)
+
+type mockStoreSvcWithStatus struct {
+	mockStoreSvc
+	registrationStatus  peregrine.Status
+	deploymentStatus    peregrine.Status
+	deploymentAllowlist []string
+}
+
+func (s *mockStoreSvcWithStatus) GetRegistrationByClientID(ctx context.Context, clientId string) (peregrine.Registration, error) {
+	reg, err := s.mockStoreSvc.GetRegistrationByClientID(ctx, clientId)
+	reg.Status = s.registrationStatus
+	reg.DeploymentAllowlist = s.deploymentAllowlist
+	return reg, err
+}
+
+func (s *mockStoreSvcWithStatus) UpsertDeploymentByPlatformDeploymentID(ctx context.Context, deployment peregrine.Deployment) (peregrine.Deployment, error) {
+	deployment, err := s.mockStoreSvc.UpsertDeploymentByPlatformDeploymentID(ctx, deployment)
+	deployment.Status = s.deploymentStatus
+	return deployment, err
+}
+
+func (s *mockStoreSvcWithStatus) GetLaunch(ctx context.Context, id uuid.UUID) (peregrine.Launch, error) {
+	l, err := s.mockStoreSvc.GetLaunch(ctx, id)
+	if l.Registration != nil {
+		l.Registration.Status = s.registrationStatus
+		l.Registration.DeploymentAllowlist = s.deploymentAllowlist
+	}
+	if l.Deployment != nil {
+		l.Deployment.Status = s.deploymentStatus
+	}
+	return l, err
+}
+
+func TestStatusIsActive(t *testing.T) {
+	t.Parallel()
+	for status, active := range map[peregrine.Status]bool{
+		"":                          true,
+		peregrine.StatusActive:      true,
+		peregrine.StatusSuspended:   false,
+		peregrine.StatusRevoked:     false,
+		peregrine.Status("unknown"): false,
+	} {
+		if status.IsActive() != active {
+			t.Fatalf("expected status %q active to be %v", status, active)
+		}
+	}
+}
+
+func TestHandleOidcLoginStatus(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name  string
+		store *mockStoreSvcWithStatus
+		err   error
+		stage Stage
+	}{
+		{
+			name:  "suspended registration",
+			store: &mockStoreSvcWithStatus{registrationStatus: peregrine.StatusSuspended},
+			err:   ErrRegistrationInactive,
+			stage: StageRegistrationStatus,
+		},
+		{
+			name:  "revoked deployment",
+			store: &mockStoreSvcWithStatus{deploymentStatus: peregrine.StatusRevoked},
+			err:   ErrDeploymentInactive,
+			stage: StageDeploymentStatus,
+		},
+		{
+			name:  "deployment not in allowlist",
+			store: &mockStoreSvcWithStatus{deploymentAllowlist: []string{"someotherdeployment"}},
+			err:   ErrDeploymentNotAllowed,
+			stage: StageDeploymentStatus,
+		},
+		{
+			name:  "active registration and allowed deployment",
+			store: &mockStoreSvcWithStatus{registrationStatus: peregrine.StatusActive, deploymentAllowlist: []string{testPlatformDeploymentID}},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			launchSvc := New(Config{
+				JWTKeySecret: testJWTSecret,
+				Issuer:       testIssuer,
+				CallbackURL:  testCallbackURL,
+			}, tt.store)
+
+			_, err := launchSvc.HandleOidcLogin(context.Background(), peregrine.OIDCLoginRequestParams{
+				Issuer:          canvasTestIssuer,
+				LoginHint:       "32",
+				TargetLinkURI:   testTargetLinkURI,
+				ClientID:        testClientID,
+				LTIDeploymentID: testPlatformDeploymentID,
+			})
+			if tt.err == nil {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.err) || ErrorStage(err) != tt.stage {
+				t.Fatalf("expected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestHandleOidcCallbackStatus(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		store    *mockStoreSvcWithStatus
+		launchID uuid.UUID
+		err      error
+		stage    Stage
+	}{
+		{
+			name:     "revoked registration",
+			store:    &mockStoreSvcWithStatus{registrationStatus: peregrine.StatusRevoked},
+			launchID: testLaunchID,
+			err:      ErrRegistrationInactive,
+			stage:    StageRegistrationStatus,
+		},
+		{
+			name:     "suspended deployment",
+			store:    &mockStoreSvcWithStatus{deploymentStatus: peregrine.StatusSuspended},
+			launchID: testLaunchID,
+			err:      ErrDeploymentInactive,
+			stage:    StageDeploymentStatus,
+		},
+		{
+			name:     "launch deployment deactivated after login",
+			store:    &mockStoreSvcWithStatus{deploymentStatus: peregrine.StatusRevoked},
+			launchID: testLaunchWithDeploymentID,
+			err:      ErrDeploymentInactive,
+			stage:    StageDeploymentStatus,
+		},
+		{
+			name:     "deployment removed from allowlist",
+			store:    &mockStoreSvcWithStatus{deploymentAllowlist: []string{"someotherdeployment"}},
+			launchID: testLaunchWithDeploymentID,
+			err:      ErrDeploymentNotAllowed,
+			stage:    StageDeploymentStatus,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			launchSvc := New(Config{
+				JWTKeySecret: testJWTSecret,
+				Issuer:       testIssuer,
+				CallbackURL:  testCallbackURL,
+			}, tt.store)
+
+			state, err := createLaunchState(testIssuer, testJWTSecret, testClientID, tt.launchID, testTargetLinkURI)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = launchSvc.HandleOidcCallback(context.Background(), peregrine.OIDCAuthenticationResponse{
+				State:   state,
+				IDToken: signTestIDToken(t, testIDTokenBuilder()),
+			})
+			if !errors.Is(err, tt.err) || ErrorStage(err) != tt.stage {
+				t.Fatalf("expected error: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package launch
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// targetLinkURIAllowed reports whether the target is within one of the allowed URLs
+func targetLinkURIAllowed(allowed []string, target string) bool {
+	t, err := url.Parse(target)
+	if err != nil || t.User != nil || t.Opaque != "" {
+		return false
+	}
+
+	for _, a := range allowed {
+		u, err := url.Parse(a)
+		if err != nil {
+			continue
+		}
+		if !strings.EqualFold(u.Scheme, t.Scheme) || !strings.EqualFold(u.Host, t.Host) {
+			continue
+		}
+		base := strings.TrimSuffix(u.EscapedPath(), "/")
+		path := t.EscapedPath()
+		if base == "" || path == base || strings.HasPrefix(path, base+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// callbackOrigin returns the origin (scheme and host) of the tools CallbackURL, the default AllowedTargetLinkURIs
+// so that the target_link_uri the tool redirects to can not be another site, empty when the CallbackURL is not
+// an absolute http(s) url
+func callbackOrigin(callbackURL string) string {
+	u, err := url.Parse(callbackURL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return ""
+	}
+
+	return u.Scheme + "://" + u.Host
+}
+
+// checkTargetLinkURI returns ErrTargetLinkURINotAllowed when the target is not within the tools AllowedTargetLinkURIs
+func checkTargetLinkURI(toolCfg ToolConfig, target string) error {
+	if !targetLinkURIAllowed(toolCfg.AllowedTargetLinkURIs, target) {
+		return fmt.Errorf("target_link_uri %s: %w", target, ErrTargetLinkURINotAllowed)
+	}
+
+	return nil
+}
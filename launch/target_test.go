@@ -0,0 +1,140 @@
+package launch
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stevenweathers/peregrine-lti/peregrine"
+)
+
+func TestTargetLinkURIAllowed(t *testing.T) {
+	t.Parallel()
+	allowed := []string{"https://stevenweathers.dev/lti/", "https://tool.example"}
+	tests := []struct {
+		target  string
+		allowed bool
+	}{
+		{target: "https://stevenweathers.dev/lti", allowed: true},
+		{target: "https://stevenweathers.dev/lti/assignments/1?tab=2", allowed: true},
+		{target: "https://STEVENWEATHERS.dev/lti/assignments", allowed: true},
+		{target: "https://tool.example/anything", allowed: true},
+		{target: "https://stevenweathers.dev/ltix", allowed: false},
+		{target: "https://stevenweathers.dev/", allowed: false},
+		{target: "http://stevenweathers.dev/lti", allowed: false},
+		{target: "https://evil.example/lti", allowed: false},
+		{target: "https://stevenweathers.dev@evil.example/lti", allowed: false},
+		{target: "https://user@stevenweathers.dev/lti", allowed: false},
+		{target: "javascript:alert(1)", allowed: false},
+	}
+
+	for _, tt := range tests {
+		if got := targetLinkURIAllowed(allowed, tt.target); got != tt.allowed {
+			t.Fatalf("expected %s allowed to be %v got %v", tt.target, tt.allowed, got)
+		}
+	}
+	if targetLinkURIAllowed(nil, "https://evil.example") {
+		t.Fatal("expected no target to be allowed with an empty allowlist")
+	}
+}
+
+func TestResolveToolConfigDefaultsToCallbackOrigin(t *testing.T) {
+	t.Parallel()
+	launchSvc := New(Config{
+		JWTKeySecret: testJWTSecret,
+		Issuer:       "https://stevenweathers.dev",
+		CallbackURL:  "https://lti.stevenweathers.dev/lti/callback",
+	}, &mockStoreSvc{})
+
+	toolCfg, err := launchSvc.resolveToolConfig(context.Background(), peregrine.Registration{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err = checkTargetLinkURI(toolCfg, "https://lti.stevenweathers.dev/assignments/1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// the Issuer only identifies the tool in the state, it is not an allowed origin
+	for _, target := range []string{"https://stevenweathers.dev/lti", "https://evil.example/"} {
+		if err = checkTargetLinkURI(toolCfg, target); !errors.Is(err, ErrTargetLinkURINotAllowed) {
+			t.Fatalf("expected error: %v", err)
+		}
+	}
+
+	launchSvc = New(Config{JWTKeySecret: testJWTSecret, Issuer: "peregrine"}, &mockStoreSvc{})
+	_, err = launchSvc.resolveToolConfig(context.Background(), peregrine.Registration{})
+	if err == nil || !strings.Contains(err.Error(), "MISSING_ALLOWED_TARGET_LINK_URIS") {
+		t.Fatalf("expected error: %v", err)
+	}
+}
+
+func TestHandleOidcLoginTargetLinkURINotAllowed(t *testing.T) {
+	t.Parallel()
+	launchSvc := New(Config{
+		JWTKeySecret:          testJWTSecret,
+		Issuer:                testIssuer,
+		CallbackURL:           testCallbackURL,
+		AllowedTargetLinkURIs: []string{"https://tool.example"},
+	}, &mockStoreSvc{})
+
+	_, err := launchSvc.HandleOidcLogin(context.Background(), peregrine.OIDCLoginRequestParams{
+		Issuer:        canvasTestIssuer,
+		LoginHint:     "32",
+		TargetLinkURI: testTargetLinkURI,
+		ClientID:      testClientID,
+	})
+	if !errors.Is(err, ErrTargetLinkURINotAllowed) || ErrorStage(err) != StageTargetLinkURI {
+		t.Fatalf("expected error: %v", err)
+	}
+}
+
+func TestHandleOidcCallbackTargetLinkURI(t *testing.T) {
+	t.Parallel()
+	launchSvc := New(Config{
+		JWTKeySecret:          testJWTSecret,
+		Issuer:                testIssuer,
+		CallbackURL:           testCallbackURL,
+		AllowedTargetLinkURIs: []string{testTargetLinkURI},
+	}, &mockStoreSvc{})
+
+	state, err := createLaunchState(testIssuer, testJWTSecret, testClientID, testLaunchID, testTargetLinkURI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := launchSvc.HandleOidcCallback(context.Background(), peregrine.OIDCAuthenticationResponse{
+		State:   state,
+		IDToken: signTestIDToken(t, testIDTokenBuilder()),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.TargetLinkURI != testTargetLinkURI {
+		t.Fatalf("expected TargetLinkURI %s got %s", testTargetLinkURI, resp.TargetLinkURI)
+	}
+	if resp.Launch.TargetLinkURI != testTargetLinkURI {
+		t.Fatalf("expected Launch.TargetLinkURI %s got %s", testTargetLinkURI, resp.Launch.TargetLinkURI)
+	}
+}
+
+func TestHandleOidcCallbackTargetLinkURINotAllowed(t *testing.T) {
+	t.Parallel()
+	launchSvc := New(Config{
+		JWTKeySecret:          testJWTSecret,
+		Issuer:                testIssuer,
+		CallbackURL:           testCallbackURL,
+		AllowedTargetLinkURIs: []string{"https://tool.example"},
+		RuleOverrides:         map[IDTokenRule]RuleSeverity{RuleTargetLinkURIMatch: SeverityIgnore},
+	}, &mockStoreSvc{})
+
+	state, err := createLaunchState(testIssuer, testJWTSecret, testClientID, testLaunchID, "https://tool.example/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = launchSvc.HandleOidcCallback(context.Background(), peregrine.OIDCAuthenticationResponse{
+		State:   state,
+		IDToken: signTestIDToken(t, testIDTokenBuilder()),
+	})
+	if !errors.Is(err, ErrTargetLinkURINotAllowed) || ErrorStage(err) != StageTargetLinkURI {
+		t.Fatalf("expected error: %v", err)
+	}
+}
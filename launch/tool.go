@@ -0,0 +1,101 @@
+package launch
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/stevenweathers/peregrine-lti/peregrine"
+)
+
+type toolIDContextKey struct{}
+
+// ToolConfig is the configuration of a tool identity served by the Service
+type ToolConfig struct {
+	// Issuer (REQUIRED) is the issuer used to sign the state JWT
+	Issuer string
+	// JWTKeySecret (REQUIRED) is the secret used to create the state JWT,
+	// each tool identity should use its own secret so that state can not be used across tools
+	JWTKeySecret string
+	// AllowedMessageTypes (OPTIONAL) are the accepted id_token message_type claim values,
+	// defaults to LtiResourceLinkRequest
+	AllowedMessageTypes []string
+	// CallbackURL (OPTIONAL) is the tools redirect_uri set in the peregrine.OIDCLoginResponseParams
+	CallbackURL string
+	// AllowedTargetLinkURIs (OPTIONAL) are the tools own URLs a target_link_uri must be within,
+	// defaults to the origin of the CallbackURL, see Config AllowedTargetLinkURIs
+	AllowedTargetLinkURIs []string
+}
+
+// ToolConfigResolver resolves the ToolConfig of the tool identity handling a launch
+type ToolConfigResolver interface {
+	// ResolveToolConfig returns the ToolConfig for the peregrine.Registration the launch belongs to,
+	// ctx is the context passed to the Service handler (e.g. the incoming *http.Request context) see ToolIDFromContext
+	ResolveToolConfig(ctx context.Context, registration peregrine.Registration) (ToolConfig, error)
+}
+
+// ToolConfigResolverFunc is an adapter to allow the use of ordinary functions as a ToolConfigResolver
+type ToolConfigResolverFunc func(ctx context.Context, registration peregrine.Registration) (ToolConfig, error)
+
+// ResolveToolConfig calls f(ctx, registration)
+func (f ToolConfigResolverFunc) ResolveToolConfig(ctx context.Context, registration peregrine.Registration) (
+	ToolConfig, error,
+) {
+	return f(ctx, registration)
+}
+
+// ContextWithToolID returns a copy of ctx carrying the tool identity of the incoming request
+// (e.g. derived from the request host) for use by a ToolConfigResolver
+func ContextWithToolID(ctx context.Context, toolID string) context.Context {
+	return context.WithValue(ctx, toolIDContextKey{}, toolID)
+}
+
+// ToolIDFromContext returns the tool identity set by ContextWithToolID
+func ToolIDFromContext(ctx context.Context) (string, bool) {
+	toolID, ok := ctx.Value(toolIDContextKey{}).(string)
+	return toolID, ok
+}
+
+// ToolConfig returns the ToolConfig of the single tool identity a Service without a ToolConfigResolver serves,
+// e.g. to describe the tool with the toolconfig package
+func (c Config) ToolConfig() ToolConfig {
+	return ToolConfig{
+		Issuer:                c.Issuer,
+		JWTKeySecret:          c.JWTKeySecret,
+		AllowedMessageTypes:   c.AllowedMessageTypes,
+		CallbackURL:           c.CallbackURL,
+		AllowedTargetLinkURIs: c.AllowedTargetLinkURIs,
+	}
+}
+
+// resolveToolConfig returns the ToolConfig for the registration using the configured ToolConfigResolver
+// falling back to the Service Config
+func (s *Service) resolveToolConfig(ctx context.Context, registration peregrine.Registration) (ToolConfig, error) {
+	var toolCfg ToolConfig
+
+	if s.config.ToolConfigResolver == nil {
+		toolCfg = s.config.ToolConfig()
+	} else {
+		var err error
+		toolCfg, err = s.config.ToolConfigResolver.ResolveToolConfig(ctx, registration)
+		if err != nil {
+			return toolCfg, err
+		}
+	}
+
+	if toolCfg.JWTKeySecret == "" {
+		return toolCfg, fmt.Errorf("MISSING_JWT_KEY_SECRET")
+	}
+	if len(toolCfg.AllowedMessageTypes) == 0 {
+		toolCfg.AllowedMessageTypes = []string{ltiMessageTypeClaimValue}
+	}
+	if len(toolCfg.AllowedTargetLinkURIs) == 0 {
+		if origin := callbackOrigin(toolCfg.CallbackURL); origin != "" {
+			toolCfg.AllowedTargetLinkURIs = []string{origin}
+		}
+	}
+	if len(toolCfg.AllowedTargetLinkURIs) == 0 {
+		return toolCfg, fmt.Errorf("MISSING_ALLOWED_TARGET_LINK_URIS: set AllowedTargetLinkURIs or an absolute CallbackURL")
+	}
+
+	return toolCfg, nil
+}
@@ -0,0 +1,190 @@
+package launch
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/stevenweathers/peregrine-lti/peregrine"
+)
+
+const (
+	testToolAIssuer = "https://tool-a.stevenweathers.dev"
+	// testToolATargetLinkURI is within tool a's default AllowedTargetLinkURIs
+	testToolATargetLinkURI = testToolAIssuer + "/lti/launch"
+	testToolASecret        = "toolasecret"
+	testToolBIssuer        = "https://tool-b.stevenweathers.dev"
+	testToolBSecret        = "toolbsecret"
+)
+
+// testIDTokenBuilder returns a jwt.Builder with the required claims for a valid test id_token
+func testIDTokenBuilder() *jwt.Builder {
+	return jwt.NewBuilder().
+		Issuer(canvasTestIssuer).
+		IssuedAt(time.Now()).
+		Audience([]string{testClientID}).
+		Subject(testSubClaim).
+		Expiration(time.Now().Add(time.Minute*10)).
+		Claim(nonceClaim, testNonce.String()).
+		Claim(ltiMessageTypeClaim, ltiMessageTypeClaimValue).
+		Claim(ltiVersionClaim, ltiVersionClaimValue).
+		Claim(ltiTargetLinkUriClaim, testTargetLinkURI).
+		Claim(ltiDeploymentIdClaim, testPlatformDeploymentID)
+}
+
+// signTestIDToken signs the id_token built by the builder with the mock platform key
+func signTestIDToken(t *testing.T, builder *jwt.Builder) string {
+	tok, err := builder.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed, err := jwt.Sign(tok, jwt.WithKey(jwa.RS256, testJwkKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(signed)
+}
+
+// testToolResolver resolves tool a or tool b by the tool id in the context
+var testToolResolver = ToolConfigResolverFunc(func(ctx context.Context, registration peregrine.Registration) (ToolConfig, error) {
+	toolID, _ := ToolIDFromContext(ctx)
+	switch toolID {
+	case "a":
+		return ToolConfig{
+			Issuer:       testToolAIssuer,
+			JWTKeySecret: testToolASecret,
+			CallbackURL:  testToolAIssuer + "/lti/callback",
+		}, nil
+	case "b":
+		return ToolConfig{
+			Issuer:                testToolBIssuer,
+			JWTKeySecret:          testToolBSecret,
+			AllowedMessageTypes:   []string{"LtiDeepLinkingRequest"},
+			AllowedTargetLinkURIs: []string{testTargetLinkURI},
+		}, nil
+	}
+	return ToolConfig{}, fmt.Errorf("TOOL_NOT_FOUND")
+})
+
+func TestHandleOidcLoginWithToolConfigResolver(t *testing.T) {
+	t.Parallel()
+	launchSvc := New(Config{ToolConfigResolver: testToolResolver}, &mockStoreSvc{})
+	ctx := ContextWithToolID(context.Background(), "a")
+
+	resp, err := launchSvc.HandleOidcLogin(ctx, peregrine.OIDCLoginRequestParams{
+		Issuer:        canvasTestIssuer,
+		LoginHint:     "32",
+		TargetLinkURI: testToolATargetLinkURI,
+		ClientID:      testClientID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.OIDCLoginResponseParams.RedirectURI != testToolAIssuer+"/lti/callback" {
+		t.Fatalf("expected redirect_uri %s to be tool a callback url", resp.OIDCLoginResponseParams.RedirectURI)
+	}
+	if _, err = validateState(testToolASecret, resp.OIDCLoginResponseParams.State); err != nil {
+		t.Fatalf("expected state to be signed with tool a secret: %v", err)
+	}
+	if _, err = validateState(testToolBSecret, resp.OIDCLoginResponseParams.State); err == nil {
+		t.Fatal("expected state to not be valid for tool b secret")
+	}
+
+	_, err = launchSvc.HandleOidcLogin(context.Background(), peregrine.OIDCLoginRequestParams{
+		Issuer:        canvasTestIssuer,
+		LoginHint:     "32",
+		TargetLinkURI: testTargetLinkURI,
+		ClientID:      testClientID,
+	})
+	if err == nil || !strings.Contains(err.Error(), "failed to resolve tool config for client id") {
+		t.Fatalf("expected error: %v", err)
+	}
+}
+
+func TestWriteLoginResponseWithToolConfigResolver(t *testing.T) {
+	t.Parallel()
+	launchSvc := New(Config{ToolConfigResolver: testToolResolver}, &mockStoreSvc{})
+	ctx := ContextWithToolID(context.Background(), "a")
+
+	resp, err := launchSvc.HandleOidcLogin(ctx, peregrine.OIDCLoginRequestParams{
+		Issuer:        canvasTestIssuer,
+		LoginHint:     "32",
+		TargetLinkURI: testToolATargetLinkURI,
+		ClientID:      testClientID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/lti/login", nil)
+	if err = WriteLoginResponse(w, r, resp, "https://stevenweathers.dev/lti/callback", ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	location, err := url.Parse(w.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if location.Query().Get("redirect_uri") != testToolAIssuer+"/lti/callback" {
+		t.Fatalf("expected redirect_uri %s to be tool a callback url", location.Query().Get("redirect_uri"))
+	}
+}
+
+func TestHandleOidcCallbackWithToolConfigResolver(t *testing.T) {
+	t.Parallel()
+	launchSvc := New(Config{ToolConfigResolver: testToolResolver}, &mockStoreSvc{})
+	ctx := ContextWithToolID(context.Background(), "a")
+
+	state, err := createLaunchState(testToolAIssuer, testToolASecret, testClientID, testLaunchID, testToolATargetLinkURI)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := launchSvc.HandleOidcCallback(ctx, peregrine.OIDCAuthenticationResponse{
+		State:   state,
+		IDToken: signTestIDToken(t, testIDTokenBuilder().Claim(ltiTargetLinkUriClaim, testToolATargetLinkURI)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Launch.Used == nil {
+		t.Fatal("expected Launch.Used to not be nil")
+	}
+
+	// state signed by tool a can not be used with tool b
+	_, err = launchSvc.HandleOidcCallback(ContextWithToolID(context.Background(), "b"), peregrine.OIDCAuthenticationResponse{
+		State:   state,
+		IDToken: signTestIDToken(t, testIDTokenBuilder()),
+	})
+	if err == nil || !strings.Contains(err.Error(), "failed to validate state:") {
+		t.Fatalf("expected error: %v", err)
+	}
+}
+
+func TestHandleOidcCallbackMessageTypeNotAllowed(t *testing.T) {
+	t.Parallel()
+	launchSvc := New(Config{ToolConfigResolver: testToolResolver}, &mockStoreSvc{})
+	ctx := ContextWithToolID(context.Background(), "b")
+
+	state, err := createLaunchState(testToolBIssuer, testToolBSecret, testClientID, testLaunchID, testTargetLinkURI)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = launchSvc.HandleOidcCallback(ctx, peregrine.OIDCAuthenticationResponse{
+		State:   state,
+		IDToken: signTestIDToken(t, testIDTokenBuilder()),
+	})
+	if err == nil || !strings.Contains(err.Error(), "LtiResourceLinkRequest is not an allowed message type") {
+		t.Fatalf("expected error: %v", err)
+	}
+}
@@ -1,16 +1,80 @@
 package launch
 
 import (
+	"log/slog"
+	"sync"
+	"time"
+
 	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/stevenweathers/peregrine-lti/instrument"
 	"github.com/stevenweathers/peregrine-lti/peregrine"
 )
 
 // Config holds all the configuration's for Service
 type Config struct {
-	// Issuer (REQUIRED) is the issuer used to sign the state JWT
+	// Issuer (REQUIRED unless ToolConfigResolver is set) is the issuer used to sign the state JWT
 	Issuer string
-	// JWTKeySecret (REQUIRED) is the secret used to create the state JWT
+	// JWTKeySecret (REQUIRED unless ToolConfigResolver is set) is the secret used to create the state JWT
 	JWTKeySecret string
+	// AllowedMessageTypes (OPTIONAL) are the accepted id_token message_type claim values,
+	// defaults to LtiResourceLinkRequest
+	AllowedMessageTypes []string
+	// CallbackURL (OPTIONAL) is the tools redirect_uri set in the peregrine.OIDCLoginResponseParams
+	CallbackURL string
+	// AllowedTargetLinkURIs (OPTIONAL) are the tools own URLs the login and id_token target_link_uri must be within,
+	// a target_link_uri is within an allowed URL with the same scheme and host when its path is the allowed path
+	// or beneath it (e.g. https://tool.example/lti allows https://tool.example/lti/assignments/1),
+	// defaults to the origin (scheme and host) of the CallbackURL, a launch fails at StageToolConfig when
+	// neither is set
+	AllowedTargetLinkURIs []string
+	// ToolConfigResolver (OPTIONAL) resolves a ToolConfig per launch allowing a single Service to serve
+	// multiple tool identities, when not set the ToolConfig is built from this Config
+	ToolConfigResolver ToolConfigResolver
+	// MaxIDTokenAge (OPTIONAL) is the maximum time since the id_token iat, defaults to DefaultMaxIDTokenAge
+	MaxIDTokenAge time.Duration
+	// IDTokenClockSkew (OPTIONAL) is the leeway allowed when validating the id_token exp, nbf and iat,
+	// defaults to DefaultIDTokenClockSkew
+	IDTokenClockSkew time.Duration
+	// IDTokenSigningAlgorithms (OPTIONAL) are the accepted id_token alg header values,
+	// defaults to DefaultIDTokenSigningAlgorithms
+	IDTokenSigningAlgorithms []string
+	// TrustedAudiences (OPTIONAL) are the audiences other than the client_id the tool accepts in the id_token aud,
+	// by default an id_token with any other audience is rejected
+	TrustedAudiences []string
+	// ValidationProfile (OPTIONAL) sets which id_token validation rules fail the launch and which are reported as
+	// warnings, defaults to LenientProfile, see StrictProfile
+	ValidationProfile ValidationProfile
+	// RuleOverrides (OPTIONAL) overrides the ValidationProfile severity per rule, the security rules
+	// (alg, signature, iss, aud, azp, exp, iat, nonce, deployment_id,
+	// message_type and claims) can not be overridden
+	RuleOverrides map[IDTokenRule]RuleSeverity
+	// RateLimiter (OPTIONAL) limits login requests by client_id, issuer and remote IP, see ContextWithRemoteIP
+	RateLimiter RateLimiter
+	// DeferLaunchCreation (OPTIONAL) defers creating the peregrine.Launch (and upserting the login's
+	// peregrine.Deployment) until the callback, the launch's registration, deployment and nonce are instead carried
+	// in an encrypted state so that login requests make no data store writes
+	DeferLaunchCreation bool
+	// Stateless (OPTIONAL) neither creates nor gets a peregrine.Launch, the launch's registration, deployment and
+	// nonce are carried in an encrypted state and the NonceStore rejects replayed id_tokens
+	Stateless bool
+	// NonceStore (OPTIONAL) atomically checks and records the id_token nonce of every callback rejecting replays,
+	// defaults to the data store when it implements peregrine.NonceRepo otherwise a MemoryNonceStore
+	NonceStore NonceStore
+	// EntitlementChecker (OPTIONAL) decides whether a verified launch is licensed before it is completed,
+	// the decision is set on the HandleOidcCallbackResponse Entitlement
+	EntitlementChecker EntitlementChecker
+	// Hooks (OPTIONAL) are run at points in the launch flow, see Hooks
+	Hooks Hooks
+	// Tracer (OPTIONAL) records spans for the handlers, data store calls and platform key set fetches,
+	// defaults to instrument.Noop
+	Tracer instrument.Tracer
+	// Meter (OPTIONAL) records launch outcome, latency and key set cache metrics, defaults to instrument.Noop
+	Meter instrument.Meter
+	// Logger (OPTIONAL) logs the key decision points of the launch flow, defaults to discarding logs
+	Logger *slog.Logger
+	// LogSensitiveValues (OPTIONAL) disables redacting sensitive values such as the id_token, email and name
+	// from logs, intended for local debugging only
+	LogSensitiveValues bool
 }
 
 // Service provides handlers for the LTI launch
@@ -18,6 +82,11 @@ type Service struct {
 	config   Config
 	dataSvc  peregrine.ToolDataRepo
 	jwkCache *jwk.Cache
+	// nonceStore is the resolved Config NonceStore
+	nonceStore NonceStore
+
+	decodersMu    sync.RWMutex
+	claimDecoders map[string]ClaimDecoder
 }
 
 // HandleOidcLoginResponse contains the login params and redirect url to proceed with LTI launch
@@ -25,10 +94,39 @@ type HandleOidcLoginResponse struct {
 	OIDCLoginResponseParams peregrine.OIDCLoginResponseParams
 	// RedirectURL is the url for the Platform launch authentication
 	RedirectURL string
+	// AuthRequestMethod is the peregrine.Platform AuthRequestMethod the authentication request is sent with,
+	// see WriteLoginResponse
+	AuthRequestMethod string
 }
 
 // HandleOidcCallbackResponse contains the lti 1.3 claims and peregrine.Launch of the successful LTI launch
 type HandleOidcCallbackResponse struct {
 	Claims peregrine.LTI1p3Claims
 	Launch peregrine.Launch
+	// RawIDToken is the verified id_token jwt as received from the Platform
+	RawIDToken string
+	// RawClaims contains every claim of the verified id_token, including those not mapped to
+	// peregrine.LTI1p3Claims such as vendor specific or LTI Advantage service claims, see Claim
+	RawClaims map[string]interface{}
+	// Extensions contains the values decoded by registered ClaimDecoder's keyed by claim name, see Extension
+	Extensions map[string]interface{}
+	// User (OPTIONAL) is the launching peregrine.User, set when the data store implements peregrine.LaunchDataRepo
+	// and the launch is not anonymous
+	User *peregrine.User
+	// Context (OPTIONAL) is the peregrine.Context of the launch, set when the data store implements
+	// peregrine.LaunchDataRepo and the launch includes a context claim
+	Context *peregrine.Context
+	// ResourceLink (OPTIONAL) is the peregrine.ResourceLink of the launch, set when the data store implements
+	// peregrine.LaunchDataRepo and the launch includes a resource link claim
+	ResourceLink *peregrine.ResourceLink
+	// Membership (OPTIONAL) is the peregrine.Membership of the User in the Context
+	Membership *peregrine.Membership
+	// TargetLinkURI is the validated target_link_uri of the launch the user should be sent to,
+	// it matches the login target_link_uri and is within the AllowedTargetLinkURIs
+	TargetLinkURI string
+	// Warnings are the id_token validation rules that failed with a SeverityWarning, see ValidationProfile
+	Warnings []*IDTokenError
+	// Entitlement (OPTIONAL) is the EntitlementChecker decision, set when an EntitlementChecker is configured
+	// including when the launch is denied so that the tool can show an upgrade page
+	Entitlement *Entitlement
 }
@@ -12,6 +12,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/lestrrat-go/jwx/v2/jwa"
 	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jws"
 	"github.com/lestrrat-go/jwx/v2/jwt"
 )
 
@@ -27,15 +28,58 @@ func getPlatformJWKs(ctx context.Context, jwkCache *jwk.Cache, jwkURL string) (j
 	return jwkCache.Get(ctx, jwkURL)
 }
 
-// createLaunchState builds a jwt to act as the state value for the oidc login flow returning jwt as a string
-func createLaunchState(issuer string, jwtKeySecret string, launchID uuid.UUID) (string, error) {
+// getRegistrationByIssuer resolves the single Registration for the issuer, narrowing by the platform deployment id
+// when provided and falling back to the issuer alone when the deployment is not yet known
+func getRegistrationByIssuer(
+	ctx context.Context, repo peregrine.RegistrationIssuerRepo, issuer string, platformDeploymentID string,
+) (peregrine.Registration, error) {
+	var registrations []peregrine.Registration
+	var err error
+
+	if platformDeploymentID != "" {
+		registrations, err = repo.GetRegistrationsByIssuer(ctx, issuer, platformDeploymentID)
+		if err != nil {
+			return peregrine.Registration{}, err
+		}
+	}
+	if len(registrations) == 0 {
+		registrations, err = repo.GetRegistrationsByIssuer(ctx, issuer, "")
+		if err != nil {
+			return peregrine.Registration{}, err
+		}
+	}
+
+	switch len(registrations) {
+	case 0:
+		return peregrine.Registration{}, ErrRegistrationNotFound
+	case 1:
+		return registrations[0], nil
+	default:
+		return peregrine.Registration{}, fmt.Errorf(
+			"%w: %d registrations found, client_id is required", ErrAmbiguousRegistration, len(registrations),
+		)
+	}
+}
+
+// DefaultStateTTL is how long the state of a login is valid for, a Launch not used within
+// the DefaultStateTTL is abandoned and can no longer be completed
+const DefaultStateTTL = time.Minute * 10
+
+// createLaunchState builds a jwt to act as the state value for the oidc login flow returning jwt as a string,
+// the targetLinkURI of the login is carried so that the id_token target_link_uri can be checked against it
+// and the clientID of the registration is set as the key id header so the tool identity (and its key)
+// can be resolved before the state is verified
+func createLaunchState(
+	issuer string, jwtKeySecret string, clientID string, launchID uuid.UUID, targetLinkURI string,
+) (string, error) {
 	var state string
 	// Build a JWT!
 	tok, err := jwt.NewBuilder().
 		Issuer(issuer).
 		IssuedAt(time.Now()).
-		Expiration(time.Now().Add(time.Minute*10)).
+		Expiration(time.Now().Add(DefaultStateTTL)).
 		Claim(launchIDClaim, launchID.String()).
+		Claim(targetLinkURIClaim, targetLinkURI).
 		Build()
 	if err != nil {
 		return state, fmt.Errorf("failed to create launch %s state jwt: %v", launchID, err)
@@ -46,7 +90,12 @@ func createLaunchState(issuer string, jwtKeySecret string, launchID uuid.UUID) (
 		return state, fmt.Errorf("failed to create launch %s state jwk from configured secret: %v", launchID, err)
 	}
 
-	signed, err := jwt.Sign(tok, jwt.WithKey(jwa.HS256, key))
+	headers := jws.NewHeaders()
+	if err = headers.Set(jws.KeyIDKey, clientID); err != nil {
+		return state, fmt.Errorf("failed to set launch %s state key id: %v", launchID, err)
+	}
+
+	signed, err := jwt.Sign(tok, jwt.WithKey(jwa.HS256, key, jws.WithProtectedHeaders(headers)))
 	if err != nil {
 		return state, fmt.Errorf("failed to sign launch %s state jwt: %v", launchID, err)
 	}
@@ -103,7 +152,8 @@ func GetCallbackParamsFromRequestFormValues(r *http.Request) (peregrine.OIDCAuth
 	return resp, nil
 }
 
-// BuildLoginResponseRedirectURL generates a form_post url to redirect to the peregrine.Platform AuthLoginURL
+// BuildLoginResponseRedirectURL generates a form_post url to redirect to the peregrine.Platform AuthLoginURL,
+// callbackUrl is the redirect_uri used when the response has no RedirectURI
 func BuildLoginResponseRedirectURL(
 	response peregrine.OIDCLoginResponseParams, platformAuthLoginUrl, callbackUrl string,
 ) (string, error) {
@@ -115,17 +165,8 @@ func BuildLoginResponseRedirectURL(
 	}
 
 	q := redirReq.Query()
-	q.Add("scope", response.Scope)
-	q.Add("response_type", response.ResponseType)
-	q.Add("response_mode", response.ResponseMode)
-	q.Add("prompt", response.Prompt)
-	q.Add("client_id", response.ClientID)
-	q.Add("redirect_uri", callbackUrl)
-	q.Add("state", response.State)
-	q.Add("nonce", response.Nonce)
-	q.Add("login_hint", response.LoginHint)
-	if response.LTIMessageHint != "" {
-		q.Add("lti_message_hint", response.LTIMessageHint)
+	for name, values := range loginResponseValues(response, callbackUrl) {
+		q[name] = append(q[name], values...)
 	}
 
 	redirReq.RawQuery = q.Encode()
@@ -133,3 +174,39 @@ func BuildLoginResponseRedirectURL(
 
 	return redirURL, nil
 }
+
+// loginResponseValues returns the authentication request parameters of the login response,
+// the redirect_uri is the response RedirectURI of the resolved tool falling back to callbackUrl when empty
+func loginResponseValues(response peregrine.OIDCLoginResponseParams, callbackUrl string) url.Values {
+	redirectURI := response.RedirectURI
+	if redirectURI == "" {
+		redirectURI = callbackUrl
+	}
+
+	v := url.Values{}
+	v.Add("scope", response.Scope)
+	v.Add("response_type", response.ResponseType)
+	v.Add("response_mode", response.ResponseMode)
+	v.Add("prompt", response.Prompt)
+	v.Add("client_id", response.ClientID)
+	v.Add("redirect_uri", redirectURI)
+	v.Add("state", response.State)
+	v.Add("nonce", response.Nonce)
+	v.Add("login_hint", response.LoginHint)
+	if response.LTIMessageHint != "" {
+		v.Add("lti_message_hint", response.LTIMessageHint)
+	}
+
+	return v
+}
+
+// containsString returns whether the value is in the values slice
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -35,7 +35,7 @@ func TestGetPlatformJWKs(t *testing.T) {
 
 func TestCreateLaunchState(t *testing.T) {
 	t.Parallel()
-	launchState, err := createLaunchState(testIssuer, testJWTSecret, testLaunchID)
+	launchState, err := createLaunchState(testIssuer, testJWTSecret, testClientID, testLaunchID, testTargetLinkURI)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -50,11 +50,17 @@ func TestCreateLaunchState(t *testing.T) {
 	if !ok || lid.(string) != testLaunchID.String() {
 		t.Fatalf("expected state launch ID %s to equal %s", lid.(string), testLaunchID.String())
 	}
+	if target, _ := claims[targetLinkURIClaim].(string); target != testTargetLinkURI {
+		t.Fatalf("expected state target_link_uri %s to equal %s", target, testTargetLinkURI)
+	}
+	if kid, err := stateKeyID(launchState); err != nil || kid != testClientID {
+		t.Fatalf("expected state kid %s to equal %s: %v", kid, testClientID, err)
+	}
 }
 
 func TestCreateLaunchStateEmptyJWTSecret(t *testing.T) {
 	t.Parallel()
-	_, err := createLaunchState(testIssuer, "", testLaunchID)
+	_, err := createLaunchState(testIssuer, "", testClientID, testLaunchID, testTargetLinkURI)
 	if err == nil || !strings.Contains(err.Error(), "failed to create launch 5daca535-415c-4bfe-8a0e-a7fba8f5d1eb state jwk from configured secret") {
 		t.Fatalf("expected error: %v", err)
 	}
@@ -226,3 +232,28 @@ func TestBuildLoginResponseRedirectURLBadPlatformAuthLoginUrl(t *testing.T) {
 		t.Fatalf("expected error: %v", err)
 	}
 }
+
+func TestPlatformKeySet(t *testing.T) {
+	t.Parallel()
+	launchSvc := New(Config{
+		JWTKeySecret: testJWTSecret,
+		Issuer:       testIssuer,
+		CallbackURL:  testCallbackURL,
+	}, &mockStoreSvc{})
+
+	keySet, err := launchSvc.PlatformKeySet(context.Background(), happyPathPlatform)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := keySet.LookupKeyID(testJwkKey.KeyID()); !ok {
+		t.Fatalf("expected platform key set to contain key %s", testJwkKey.KeyID())
+	}
+
+	_, err = launchSvc.PlatformKeySet(context.Background(), peregrine.Platform{
+		Issuer:    canvasTestIssuer,
+		KeySetURL: testSrvUrl + "/canvaslms/api/lti/security/badjwks",
+	})
+	if err == nil || !strings.Contains(err.Error(), "unable to retrieve") {
+		t.Fatalf("expected error: %v", err)
+	}
+}
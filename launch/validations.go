@@ -1,8 +1,8 @@
 package launch
 
 import (
-	"context"
 	"fmt"
+	"time"
 
 	"github.com/lestrrat-go/jwx/v2/jwa"
 	"github.com/mitchellh/mapstructure"
@@ -11,6 +11,7 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jws"
 	"github.com/lestrrat-go/jwx/v2/jwt"
 )
 
@@ -22,14 +23,19 @@ const (
 	ltiVersionClaim          = "https://purl.imsglobal.org/spec/lti/claim/version"
 	ltiVersionClaimValue     = "1.3.0"
 	ltiTargetLinkUriClaim    = "https://purl.imsglobal.org/spec/lti/claim/target_link_uri"
+	ltiRolesClaim            = "https://purl.imsglobal.org/spec/lti/claim/roles"
+	targetLinkURIClaim       = "lti_target_link_uri"
 	nonceClaim               = "nonce"
+	azpClaim                 = "azp"
 )
 
-func validateLoginRequestParams(params peregrine.OIDCLoginRequestParams) error {
+// validateLoginRequestParams validates the required login request params,
+// client_id is only required when the registration can not be resolved by issuer
+func validateLoginRequestParams(params peregrine.OIDCLoginRequestParams, clientIDOptional bool) error {
 	if params.Issuer == "" {
 		return fmt.Errorf("MISSING_ISS")
 	}
-	if params.ClientID == "" {
+	if params.ClientID == "" && !clientIDOptional {
 		return fmt.Errorf("MISSING_CLIENT_ID")
 	}
 	if params.LoginHint == "" {
@@ -42,56 +48,163 @@ func validateLoginRequestParams(params peregrine.OIDCLoginRequestParams) error {
 	return nil
 }
 
-// validateState parses the jwt with the configured key and returns the Launch.ID from the jwt claims
-func validateState(jwtKeySecret string, state string) (uuid.UUID, error) {
-	launchID := uuid.New()
+// launchState is the content of the state jwt created by createLaunchState
+type launchState struct {
+	launchID uuid.UUID
+	// targetLinkURI is the target_link_uri of the login, empty for a state created without it
+	targetLinkURI string
+}
+
+// validateState parses the jwt with the configured key and returns the launchState from the jwt claims
+func validateState(jwtKeySecret string, state string) (launchState, error) {
+	var ls launchState
 
 	key, err := jwk.FromRaw([]byte(jwtKeySecret))
 	if err != nil {
-		return launchID, fmt.Errorf("failed to create JWK key with configured secret: %v", err)
+		return ls, fmt.Errorf("failed to create JWK key with configured secret: %v", err)
 	}
 
 	verifiedToken, err := jwt.Parse([]byte(state), jwt.WithKey(jwa.HS256, key))
 	if err != nil {
-		return launchID, fmt.Errorf("failed to verify JWS: %v", err)
+		return ls, fmt.Errorf("failed to verify JWS: %v", err)
 	}
 	claims := verifiedToken.PrivateClaims()
 	lid, ok := claims[launchIDClaim]
 	if !ok {
-		return launchID, fmt.Errorf("%s claim not found in launch state jwt", launchIDClaim)
+		return ls, fmt.Errorf("%s claim not found in launch state jwt", launchIDClaim)
+	}
+	ls.launchID, err = uuid.Parse(lid.(string))
+	if err != nil {
+		return ls, fmt.Errorf("%s claim not a uuid", launchIDClaim)
 	}
-	launchID, err = uuid.Parse(lid.(string))
+	ls.targetLinkURI, _ = claims[targetLinkURIClaim].(string)
+
+	return ls, nil
+}
+
+// stateKeyID returns the client_id set as the key id header of the state jwt by createLaunchState,
+// the claims are not read until the state is verified with validateState
+func stateKeyID(state string) (string, error) {
+	msg, err := jws.Parse([]byte(state))
 	if err != nil {
-		return launchID, fmt.Errorf("%s claim not a uuid", launchIDClaim)
+		return "", fmt.Errorf("failed to parse state jwt: %v", err)
+	}
+	signatures := msg.Signatures()
+	if len(signatures) != 1 {
+		return "", fmt.Errorf("state jwt must have a single signature")
+	}
+	clientID := signatures[0].ProtectedHeaders().KeyID()
+	if clientID == "" {
+		return "", fmt.Errorf("missing kid")
 	}
 
-	return launchID, nil
+	return clientID, nil
 }
 
 // parseIDToken validates the id_token jwt with the peregrine.Platform key set returning peregrine.LTI1p3Claims
-func parseIDToken(ctx context.Context, jwkCache *jwk.Cache, launch peregrine.Launch, idToken string) (peregrine.LTI1p3Claims, error) {
+// along with the verified jwt.Token so that claims not mapped to peregrine.LTI1p3Claims are not lost,
+// each failed rule is returned as an IDTokenError unless the ValidationProfile records it as a warning of v
+func parseIDToken(
+	keySet jwk.Set, launch peregrine.Launch, idToken string, v *idTokenValidation,
+) (peregrine.LTI1p3Claims, jwt.Token, error) {
 	var lti1p3Claims peregrine.LTI1p3Claims
-	keysetUrl := launch.Registration.Platform.KeySetURL
+	clientID := launch.Registration.ClientID
 
-	keySet, err := getPlatformJWKs(ctx, jwkCache, keysetUrl)
+	msg, err := jws.Parse([]byte(idToken))
 	if err != nil {
-		return lti1p3Claims, fmt.Errorf("unable to retrieve %s keyset: %v", keysetUrl, err)
-	}
-
-	// validate that the id_token jwt is can be parsed and return a verified token
-	// including verifying the issuer and client_id from peregrine.Launch.Platform
-	// as well as checking for LTI 1.3 required claims
-	verifiedToken, err := jwt.Parse([]byte(idToken), jwt.WithKeySet(keySet),
-		jwt.WithIssuer(launch.Registration.Platform.Issuer),
-		jwt.WithAudience(launch.Registration.ClientID),
-		jwt.WithClaimValue(nonceClaim, launch.Nonce.String()),
-		jwt.WithRequiredClaim(ltiDeploymentIdClaim),
-		jwt.WithClaimValue(ltiMessageTypeClaim, ltiMessageTypeClaimValue),
-		jwt.WithClaimValue(ltiVersionClaim, ltiVersionClaimValue),
-		jwt.WithRequiredClaim(ltiTargetLinkUriClaim),
-	)
+		return lti1p3Claims, nil, newIDTokenError(RuleSignature, "%v", err)
+	}
+	for _, sig := range msg.Signatures() {
+		alg := sig.ProtectedHeaders().Algorithm().String()
+		if !containsString(v.signingAlgorithms, alg) {
+			return lti1p3Claims, nil, newIDTokenError(RuleAlgorithm, "alg %s is not an allowed algorithm", alg)
+		}
+	}
+
+	// the signature is verified with the platforms key set while the claims are validated below
+	// so that each failed rule can be reported distinctly
+	verifiedToken, err := jwt.Parse([]byte(idToken), jwt.WithKeySet(keySet), jwt.WithValidate(false))
 	if err != nil {
-		return lti1p3Claims, fmt.Errorf("invalid id_token: %v", err)
+		return lti1p3Claims, nil, newIDTokenError(RuleSignature, "%v", err)
+	}
+
+	if verifiedToken.Issuer() != launch.Registration.Platform.Issuer {
+		return lti1p3Claims, nil, newIDTokenError(RuleIssuer,
+			"iss %s does not match platform issuer %s", verifiedToken.Issuer(), launch.Registration.Platform.Issuer,
+		)
+	}
+
+	audiences := verifiedToken.Audience()
+	if !containsString(audiences, clientID) {
+		return lti1p3Claims, nil, newIDTokenError(RuleAudience, "aud does not contain client_id %s", clientID)
+	}
+	for _, aud := range audiences {
+		if aud != clientID && !containsString(v.trustedAudiences, aud) {
+			return lti1p3Claims, nil, newIDTokenError(RuleAudience, "aud %s is not a trusted audience", aud)
+		}
+	}
+
+	azp, _ := verifiedToken.PrivateClaims()[azpClaim].(string)
+	if azp == "" && len(audiences) > 1 {
+		return lti1p3Claims, nil, newIDTokenError(RuleAuthorizedParty,
+			"azp is required when the id_token has multiple audiences",
+		)
+	}
+	if azp != "" && azp != clientID {
+		return lti1p3Claims, nil, newIDTokenError(RuleAuthorizedParty, "azp %s does not match client_id %s", azp, clientID)
+	}
+
+	exp := verifiedToken.Expiration()
+	if exp.IsZero() {
+		return lti1p3Claims, nil, newIDTokenError(RuleExpiration, "exp is required")
+	}
+	if !v.now.Add(-v.clockSkew).Before(exp) {
+		return lti1p3Claims, nil, newIDTokenError(RuleExpiration, "id_token expired at %s", exp.UTC().Format(time.RFC3339))
+	}
+
+	if nbf := verifiedToken.NotBefore(); !nbf.IsZero() && nbf.After(v.now.Add(v.clockSkew)) {
+		return lti1p3Claims, nil, newIDTokenError(RuleNotBefore, "id_token is not valid before %s", nbf.UTC().Format(time.RFC3339))
+	}
+
+	iat := verifiedToken.IssuedAt()
+	if iat.IsZero() {
+		return lti1p3Claims, nil, newIDTokenError(RuleIssuedAt, "iat is required")
+	}
+	if iat.After(v.now.Add(v.clockSkew)) {
+		return lti1p3Claims, nil, newIDTokenError(RuleIssuedAt, "iat %s is in the future", iat.UTC().Format(time.RFC3339))
+	}
+	if v.now.Sub(iat) > v.maxAge+v.clockSkew {
+		return lti1p3Claims, nil, newIDTokenError(RuleIssuedAt,
+			"iat %s is older than the maximum age of %s", iat.UTC().Format(time.RFC3339), v.maxAge,
+		)
+	}
+
+	claims := verifiedToken.PrivateClaims()
+	if nonce, _ := claims[nonceClaim].(string); nonce != launch.Nonce.String() {
+		return lti1p3Claims, nil, newIDTokenError(RuleNonce, "nonce does not match the launch nonce")
+	}
+	if _, ok := claims[ltiDeploymentIdClaim]; !ok {
+		return lti1p3Claims, nil, newIDTokenError(RuleDeploymentID, "%s claim is required", ltiDeploymentIdClaim)
+	}
+	if _, ok := claims[ltiMessageTypeClaim]; !ok {
+		return lti1p3Claims, nil, newIDTokenError(RuleMessageType, "%s claim is required", ltiMessageTypeClaim)
+	}
+	if version, _ := claims[ltiVersionClaim].(string); version != ltiVersionClaimValue {
+		if err = v.check(newIDTokenError(RuleVersion,
+			"%s claim must be %s", ltiVersionClaim, ltiVersionClaimValue,
+		)); err != nil {
+			return lti1p3Claims, nil, err
+		}
+	}
+	if _, ok := claims[ltiTargetLinkUriClaim]; !ok {
+		if err = v.check(newIDTokenError(RuleTargetLinkURI, "%s claim is required", ltiTargetLinkUriClaim)); err != nil {
+			return lti1p3Claims, nil, err
+		}
+	}
+	if _, ok := claims[ltiRolesClaim]; !ok {
+		if err = v.check(newIDTokenError(RuleRoles, "%s claim is required", ltiRolesClaim)); err != nil {
+			return lti1p3Claims, nil, err
+		}
 	}
 
 	cfg := &mapstructure.DecoderConfig{
@@ -100,23 +213,57 @@ func parseIDToken(ctx context.Context, jwkCache *jwk.Cache, launch peregrine.Lau
 		TagName:  "json",
 	}
 	decoder, _ := mapstructure.NewDecoder(cfg)
-	err = decoder.Decode(verifiedToken.PrivateClaims())
+	err = decoder.Decode(claims)
 	if err != nil {
-		return lti1p3Claims, fmt.Errorf("failed to decode LTI claims %v", err)
+		return lti1p3Claims, nil, newIDTokenError(RuleClaims, "failed to decode LTI claims %v", err)
 	}
 	lti1p3Claims.SUB = verifiedToken.Subject()
 
+	if !containsString(v.allowedMessageTypes, lti1p3Claims.MessageType) {
+		return lti1p3Claims, nil, newIDTokenError(RuleMessageType,
+			"%s %s is not an allowed message type", ltiMessageTypeClaim, lti1p3Claims.MessageType,
+		)
+	}
+	if lti1p3Claims.MessageType == ltiMessageTypeClaimValue && lti1p3Claims.ResourceLink.ID == "" {
+		if err = v.check(newIDTokenError(RuleResourceLinkID,
+			"resource_link id is required for a %s", ltiMessageTypeClaimValue,
+		)); err != nil {
+			return lti1p3Claims, nil, err
+		}
+	}
+	if v.targetLinkURI != "" && lti1p3Claims.TargetLinkURI != v.targetLinkURI {
+		if err = v.check(newIDTokenError(RuleTargetLinkURIMatch,
+			"%s %s does not match login target_link_uri %s",
+			ltiTargetLinkUriClaim, lti1p3Claims.TargetLinkURI, v.targetLinkURI,
+		)); err != nil {
+			return lti1p3Claims, nil, err
+		}
+	}
+
 	if lti1p3Claims.SUB != "" && (len(lti1p3Claims.SUB) > 255) {
-		return lti1p3Claims, fmt.Errorf("sub %s in id_token exceeds 255 characters", lti1p3Claims.SUB)
+		if err = v.check(newIDTokenError(RuleSubject,
+			"sub %s in id_token exceeds 255 characters", lti1p3Claims.SUB,
+		)); err != nil {
+			return lti1p3Claims, nil, err
+		}
+	}
+	// only an anonymous launch may omit the sub, a launch identifying the user must include it
+	if lti1p3Claims.SUB == "" && (lti1p3Claims.Name != "" || lti1p3Claims.GivenName != "" ||
+		lti1p3Claims.FamilyName != "" || lti1p3Claims.Email != "") {
+		if err = v.check(newIDTokenError(RuleSubjectRequired,
+			"sub is required when the id_token includes user identity claims",
+		)); err != nil {
+			return lti1p3Claims, nil, err
+		}
 	}
 
 	// validate deployment_id exists and if launch had deployment_id that it matches
 	if launch.Deployment != nil && lti1p3Claims.DeploymentID != launch.Deployment.PlatformDeploymentID {
-		return lti1p3Claims, fmt.Errorf(
+		return lti1p3Claims, nil, newIDTokenError(RuleDeploymentID,
 			"launch platform_deployment_id %s does not match id_token deployment_id %s",
 			launch.Deployment.PlatformDeploymentID, lti1p3Claims.DeploymentID,
 		)
 	}
 
-	return lti1p3Claims, nil
+	return lti1p3Claims, verifiedToken, nil
 }
@@ -15,6 +15,7 @@ func TestValidateLoginRequestParams(t *testing.T) {
 			LoginHint:     "test_login_hint",
 			TargetLinkURI: "test_target_link_uri",
 		},
+		false,
 	)
 	if err != nil {
 		t.Fatalf(`validateLoginRequestParams = %v error`, err)
@@ -24,6 +25,7 @@ func TestValidateLoginRequestParams(t *testing.T) {
 		peregrine.OIDCLoginRequestParams{
 			Issuer: "",
 		},
+		false,
 	)
 	if err.Error() != "MISSING_ISS" {
 		t.Fatalf(`expected MISSING_ISS error for validateLoginRequestParams`)
@@ -34,6 +36,7 @@ func TestValidateLoginRequestParams(t *testing.T) {
 			Issuer:   "test_issuer",
 			ClientID: "",
 		},
+		false,
 	)
 	if err.Error() != "MISSING_CLIENT_ID" {
 		t.Fatalf(`expected MISSING_CLIENT_ID error for validateLoginRequestParams`)
@@ -45,6 +48,7 @@ func TestValidateLoginRequestParams(t *testing.T) {
 			ClientID:  "test_client_id",
 			LoginHint: "",
 		},
+		false,
 	)
 	if err.Error() != "MISSING_LOGIN_HINT" {
 		t.Fatalf(`expected MISSING_LOGIN_HINT error for validateLoginRequestParams`)
@@ -56,8 +60,22 @@ func TestValidateLoginRequestParams(t *testing.T) {
 			ClientID:  "test_client_id",
 			LoginHint: "test_login_hint",
 		},
+		false,
 	)
 	if err.Error() != "MISSING_TARGET_LINK_URI" {
 		t.Fatalf(`expected MISSING_TARGET_LINK_URI error for validateLoginRequestParams`)
 	}
+
+	err = validateLoginRequestParams(
+		peregrine.OIDCLoginRequestParams{
+			Issuer:        "test_issuer",
+			ClientID:      "",
+			LoginHint:     "test_login_hint",
+			TargetLinkURI: "test_target_link_uri",
+		},
+		true,
+	)
+	if err != nil {
+		t.Fatalf(`expected client_id to be optional for validateLoginRequestParams: %v`, err)
+	}
 }
@@ -235,6 +235,33 @@ type LISClaim struct {
 	ResultSourcedID string `json:"result_sourcedid"`
 }
 
+// PlatformNotificationServiceClaim as per the 1EdTech Platform Notification Service,
+// the tool manages its notice handler subscriptions through the PlatformNotificationServiceURL
+type PlatformNotificationServiceClaim struct {
+	// ServiceVersions (REQUIRED) are the supported versions of the service e.g. 1.0
+	ServiceVersions []string `json:"service_versions"`
+	// PlatformNotificationServiceURL (REQUIRED) is the url of the platforms notice handlers endpoint
+	PlatformNotificationServiceURL string `json:"platform_notification_service_url"`
+	// Scope (REQUIRED) are the scopes the tool may request to access the service
+	Scope []string `json:"scope"`
+	// NoticeTypesSupported (REQUIRED) are the notice types the platform may send e.g. LtiHelloWorldNotice
+	NoticeTypesSupported []string `json:"notice_types_supported"`
+}
+
+// GroupsServiceClaim as per the 1EdTech Course Groups service,
+// the tool reads the groups and group sets of the launch's context through the ContextGroupsURL and ContextGroupSetsURL
+type GroupsServiceClaim struct {
+	// Scope (REQUIRED) are the scopes the tool may request to access the service
+	Scope []string `json:"scope"`
+	// ContextGroupsURL (REQUIRED) is the url of the context's groups endpoint
+	ContextGroupsURL string `json:"context_groups_url"`
+	// ContextGroupSetsURL (OPTIONAL) is the url of the context's group sets endpoint,
+	// omitted when the platform does not support group sets
+	ContextGroupSetsURL string `json:"context_group_sets_url"`
+	// ServiceVersions (REQUIRED) are the supported versions of the service e.g. 1.0
+	ServiceVersions []string `json:"service_versions"`
+}
+
 // LTI1p3Claims contains all the claims as per the LTI 1.3 spec
 // see https://www.imsglobal.org/spec/lti/v1p3#required-message-claims
 // and https://www.imsglobal.org/spec/lti/v1p3#optional-message-claims
@@ -308,4 +335,10 @@ type LTI1p3Claims struct {
 	// A custom property value must always be of type string. Note that "empty-string" is a valid custom value ("")
 	// note also that null is not a valid custom value.
 	Custom map[string]string `json:"https://purl.imsglobal.org/spec/lti/claim/custom"`
+	// PlatformNotificationService (OPTIONAL) claim is included when the platform supports the
+	// Platform Notification Service
+	PlatformNotificationService PlatformNotificationServiceClaim `json:"https://purl.imsglobal.org/spec/lti/claim/platformnotificationservice"`
+	// GroupsService (OPTIONAL) claim is included when the platform supports the Course Groups service
+	// for the launch's context
+	GroupsService GroupsServiceClaim `json:"https://purl.imsglobal.org/spec/lti-gs/claim/groupsservice"`
 }
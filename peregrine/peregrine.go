@@ -2,23 +2,43 @@ package peregrine
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+// ErrRegistrationNotFound should be returned (or wrapped) by ToolDataRepo GetRegistrationByClientID when no
+// Registration has the client_id, distinguishing an unknown client_id from a data store failure
+var ErrRegistrationNotFound = errors.New("REGISTRATION_NOT_FOUND")
+
 // Platform represents the LMS Platform unique by Issuer
 type Platform struct {
 	// ID (REQUIRED) is the tools UUID for the Platform
 	ID uuid.UUID
 	// Issuer (REQUIRED) is the id_token JWT issuer ex. https://canvas.instructure.com
 	Issuer string
-	// KeySetURL (REQUIRED) or URL for the Platform JWK key set ex. https://sso.canvaslms.com/api/lti/authorize_redirect
+	// KeySetURL (REQUIRED) or URL for the Platform JWK key set ex. https://sso.canvaslms.com/api/lti/security/jwks
 	KeySetURL string
 	// AuthLoginURL (REQUIRED) is the url for the Platform launch authentication
+	// ex. https://sso.canvaslms.com/api/lti/authorize_redirect
 	AuthLoginURL string
+	// TokenURL (OPTIONAL) is the url for the Platform OAuth2 access token service used by LTI Advantage services
+	// ex. https://sso.canvaslms.com/login/oauth2/token
+	TokenURL string
+	// AuthRequestMethod (OPTIONAL) is how the authentication request is sent to the AuthLoginURL,
+	// AuthRequestMethodGet (the default) redirects with the request in the url query
+	// while AuthRequestMethodPost auto-submits an html form keeping the state and nonce out of the url
+	AuthRequestMethod string
 }
 
+const (
+	// AuthRequestMethodGet sends the authentication request as a GET redirect
+	AuthRequestMethodGet = "GET"
+	// AuthRequestMethodPost sends the authentication request as an auto-submitted POST form
+	AuthRequestMethodPost = "POST"
+)
+
 // PlatformInstance composes properties associated with the platform instance initiating the launch
 // optional https://purl.imsglobal.org/spec/lti/claim/tool_platform claim.
 //
@@ -56,6 +76,29 @@ type Registration struct {
 	Platform *Platform
 	// ClientID (REQUIRED) or client_id is the tool registration ID in the Platform Instance
 	ClientID string
+	// Status (OPTIONAL) of the Registration, launches are only allowed for an active Registration,
+	// defaults to StatusActive
+	Status Status
+	// DeploymentAllowlist (OPTIONAL) are the PlatformDeploymentIDs allowed to launch the tool,
+	// when set a launch from any other deployment is rejected instead of its Deployment being created
+	DeploymentAllowlist []string
+}
+
+// Status is the lifecycle status of a Registration or Deployment
+type Status string
+
+const (
+	// StatusActive allows launches, an empty Status is active
+	StatusActive Status = "active"
+	// StatusSuspended temporarily blocks launches e.g. while a subscription is unpaid
+	StatusSuspended Status = "suspended"
+	// StatusRevoked permanently blocks launches e.g. once the tool is uninstalled
+	StatusRevoked Status = "revoked"
+)
+
+// IsActive returns whether the Status allows launches
+func (s Status) IsActive() bool {
+	return s == "" || s == StatusActive
 }
 
 // Deployment
@@ -76,6 +119,9 @@ type Deployment struct {
 	Name string
 	// Description (OPTIONAL) of the Deployment e.g. In Course Navigation
 	Description string
+	// Status (OPTIONAL) of the Deployment, launches are only allowed for an active Deployment,
+	// defaults to StatusActive
+	Status Status
 }
 
 // Launch is the LTI tool launch event in the PlatformInstance
@@ -90,23 +136,144 @@ type Launch struct {
 	Deployment *Deployment
 	// PlatformInstance (OPTIONAL) is the PlatformInstance in which the Launch event occurred
 	PlatformInstance *PlatformInstance
+	// TargetLinkURI (OPTIONAL) is the target_link_uri of the login request that the id_token
+	// target_link_uri must match
+	TargetLinkURI string
 	// Used (OPTIONAL) is the timestamp of when the Launch was completed, upon completion the
 	// Launch should not be reusable (whether querying by Nonce or ID)
 	Used *time.Time
 }
 
+// User is the launching end user of a Registration unique by SUB
+type User struct {
+	// ID (REQUIRED) is the tools UUID for the User
+	ID uuid.UUID
+	// Registration (REQUIRED) is the Registration the User launched from, the SUB is only unique to the Issuer
+	Registration *Registration
+	// SUB (REQUIRED) is the Platform's stable id for the User
+	SUB string
+	// Name (OPTIONAL) is the Users full name in displayable form
+	Name string
+	// GivenName (OPTIONAL) is the Users given name(s) or first name(s)
+	GivenName string
+	// FamilyName (OPTIONAL) is the Users surname(s) or last name(s)
+	FamilyName string
+	// Email (OPTIONAL) is the Users preferred e-mail address
+	Email string
+	// Locale (OPTIONAL) is the Users preferred locale as a BCP47 language tag
+	Locale string
+}
+
+// Context is the Platform context (e.g. course) a launch occurred in unique by Deployment and ContextID
+type Context struct {
+	// ID (REQUIRED) is the tools UUID for the Context
+	ID uuid.UUID
+	// Deployment (REQUIRED) is the Deployment the Context belongs to, the ContextID is only unique to the Deployment
+	Deployment *Deployment
+	// ContextID (REQUIRED) is the Platform's stable id for the context
+	ContextID string
+	// Type (OPTIONAL) are the context type URIs
+	Type []string
+	// Label (OPTIONAL) is the short descriptive name for the context e.g. course code
+	Label string
+	// Title (OPTIONAL) is the full descriptive name for the context e.g. course title
+	Title string
+}
+
+// ResourceLink is the placement of the tool within a Platform context unique by Deployment and ResourceLinkID
+type ResourceLink struct {
+	// ID (REQUIRED) is the tools UUID for the ResourceLink
+	ID uuid.UUID
+	// Deployment (REQUIRED) is the Deployment the ResourceLink belongs to,
+	// the ResourceLinkID is only unique to the Deployment
+	Deployment *Deployment
+	// Context (OPTIONAL) is the Context the ResourceLink is placed in
+	Context *Context
+	// ResourceLinkID (REQUIRED) is the Platform's stable id for the resource link
+	ResourceLinkID string
+	// Title (OPTIONAL) is the descriptive title for the resource link
+	Title string
+	// Description (OPTIONAL) is the descriptive phrase for the resource link
+	Description string
+}
+
+// Membership is the Users roles within a Context unique by User and Context
+type Membership struct {
+	// ID (REQUIRED) is the tools UUID for the Membership
+	ID uuid.UUID
+	// User (REQUIRED) is the member User
+	User *User
+	// Context (REQUIRED) is the Context the User is a member of
+	Context *Context
+	// Roles (REQUIRED) are the role URIs of the User in the Context as of the latest launch
+	Roles []string
+}
+
 // ToolDataRepo is intended to be a storage (e.g. DB) service for an LTI Tools registration and launch
 type ToolDataRepo interface {
 	// UpsertPlatformInstanceByGUID should create a PlatformInstance if not existing returning PlatformInstance with ID
 	UpsertPlatformInstanceByGUID(ctx context.Context, instance PlatformInstance) (PlatformInstance, error)
-	// GetRegistrationByClientID should return a Registration by ClientID
+	// GetRegistrationByClientID should return a Registration by ClientID including its Status,
+	// returning an error wrapping ErrRegistrationNotFound when no Registration has the ClientID
 	GetRegistrationByClientID(ctx context.Context, clientId string) (Registration, error)
 	// UpsertDeploymentByPlatformDeploymentID should create a Deployment if not existing returning a Deployment with ID
+	// and its current Status
 	UpsertDeploymentByPlatformDeploymentID(ctx context.Context, deployment Deployment) (Deployment, error)
-	// GetLaunch should return a Launch by ID
+	// GetLaunch should return a Launch by ID including its TargetLinkURI and the current Status of its
+	// Registration and Deployment
 	GetLaunch(ctx context.Context, id uuid.UUID) (Launch, error)
-	// CreateLaunch should create a Launch returning Launch with ID and Nonce
+	// CreateLaunch should create a Launch returning Launch with ID and Nonce, when the Nonce is already set
+	// (see launch.Config DeferLaunchCreation) it must be persisted as given, along with the TargetLinkURI
 	CreateLaunch(ctx context.Context, launch Launch) (Launch, error)
 	// UpdateLaunch should update a Launch by ID
 	UpdateLaunch(ctx context.Context, launch Launch) (Launch, error)
 }
+
+// RegistrationIssuerRepo is an OPTIONAL extension of ToolDataRepo used to resolve the Registration
+// when a Platform omits the optional client_id from the login initiation request
+type RegistrationIssuerRepo interface {
+	// GetRegistrationsByIssuer should return the Registrations of the Platform by Issuer, when platformDeploymentID
+	// is not empty only the Registrations with a Deployment by PlatformDeploymentID should be returned
+	GetRegistrationsByIssuer(ctx context.Context, issuer string, platformDeploymentID string) ([]Registration, error)
+}
+
+// LaunchDataRepo is an OPTIONAL extension of ToolDataRepo, when implemented the launch's
+// User, Context, ResourceLink and Membership are upserted on each successful launch
+type LaunchDataRepo interface {
+	// UpsertUserBySUB should create or update a User by Registration and SUB returning User with ID
+	UpsertUserBySUB(ctx context.Context, user User) (User, error)
+	// UpsertContextByContextID should create or update a Context by Deployment and ContextID returning Context with ID
+	UpsertContextByContextID(ctx context.Context, lmsContext Context) (Context, error)
+	// UpsertResourceLinkByResourceLinkID should create or update a ResourceLink by Deployment and ResourceLinkID
+	// returning ResourceLink with ID
+	UpsertResourceLinkByResourceLinkID(ctx context.Context, link ResourceLink) (ResourceLink, error)
+	// UpsertMembership should create or update a Membership by User and Context returning Membership with ID
+	UpsertMembership(ctx context.Context, membership Membership) (Membership, error)
+}
+
+// LaunchRetentionRepo is an OPTIONAL extension of ToolDataRepo used by the retention.Janitor to purge expired
+// Launches, implementations need to record when each Launch was created
+type LaunchRetentionRepo interface {
+	// DeleteExpiredLaunches should delete at most limit Launches that were either created before unusedBefore
+	// and never Used, or Used before usedBefore, returning the number of unused and used Launches deleted
+	DeleteExpiredLaunches(ctx context.Context, unusedBefore time.Time, usedBefore time.Time, limit int) (
+		unused int, used int, err error,
+	)
+}
+
+// NonceRepo is an OPTIONAL extension of ToolDataRepo used as the launch.NonceStore,
+// allowing used nonces to be shared by every instance of the tool
+type NonceRepo interface {
+	// UseNonce should atomically record the nonce as used until expiresAt (e.g. an insert with a unique constraint),
+	// reporting false when the nonce was already used and has not yet expired
+	UseNonce(ctx context.Context, nonce string, expiresAt time.Time) (bool, error)
+}
+
+// AccessTokenSource provides OAuth2 access tokens for the LTI Advantage services of a Registration's Platform,
+// e.g. by the client_credentials grant with a signed JWT assertion sent to the Platform TokenURL
+// (see https://www.imsglobal.org/spec/security/v1p0/#using-json-web-tokens-with-oauth-2-0-client-credentials-grant)
+type AccessTokenSource interface {
+	// AccessToken should return a bearer access token for the Registration granting the scopes,
+	// implementations are expected to cache tokens until they expire
+	AccessToken(ctx context.Context, registration Registration, scopes []string) (string, error)
+}
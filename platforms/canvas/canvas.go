@@ -0,0 +1,107 @@
+package canvas
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/stevenweathers/peregrine-lti/launch"
+)
+
+const (
+	// ClaimNamespace is the namespace of Instructure's Canvas specific id_token claims
+	ClaimNamespace = "https://www.instructure.com/"
+	// PlacementClaim is the Canvas placement (e.g. course_navigation) the tool was launched from
+	PlacementClaim = "https://www.instructure.com/placement"
+	// LTI11LegacyUserIDClaim is the users LTI 1.1 user_id, sent by Canvas to aid migrating tools from LTI 1.1
+	LTI11LegacyUserIDClaim = "https://purl.imsglobal.org/spec/lti/claim/lti11_legacy_user_id"
+)
+
+// Custom field names used by DefaultCustomFields for Canvas variable substitutions
+const (
+	CustomFieldUserID      = "canvas_user_id"
+	CustomFieldUserLoginID = "canvas_user_login_id"
+	CustomFieldCourseID    = "canvas_course_id"
+	CustomFieldAccountID   = "canvas_account_id"
+	CustomFieldAPIDomain   = "canvas_api_domain"
+)
+
+// DefaultCustomFields returns the developer key custom_fields that LaunchExtensions reads,
+// mapping each custom field name to its Canvas variable substitution
+// see https://canvas.instructure.com/doc/api/file.tools_variable_substitutions.html
+func DefaultCustomFields() map[string]string {
+	return map[string]string{
+		CustomFieldUserID:      "$Canvas.user.id",
+		CustomFieldUserLoginID: "$Canvas.user.loginId",
+		CustomFieldCourseID:    "$Canvas.course.id",
+		CustomFieldAccountID:   "$Canvas.account.id",
+		CustomFieldAPIDomain:   "$Canvas.api.domain",
+	}
+}
+
+// LaunchExtensions contains the non-standard data Canvas sends with an LTI 1.3 launch
+type LaunchExtensions struct {
+	// Placement (OPTIONAL) is the Canvas placement the tool was launched from e.g. course_navigation
+	Placement string
+	// LTI11LegacyUserID (OPTIONAL) is the users LTI 1.1 user_id
+	LTI11LegacyUserID string
+	// UserID (OPTIONAL) is the Canvas user id from the $Canvas.user.id custom field substitution
+	UserID string
+	// UserLoginID (OPTIONAL) is the Canvas user login id from the $Canvas.user.loginId custom field substitution
+	UserLoginID string
+	// CourseID (OPTIONAL) is the Canvas course id from the $Canvas.course.id custom field substitution
+	CourseID string
+	// AccountID (OPTIONAL) is the Canvas account id from the $Canvas.account.id custom field substitution
+	AccountID string
+	// APIDomain (OPTIONAL) is the Canvas API domain from the $Canvas.api.domain custom field substitution
+	APIDomain string
+}
+
+// RegisterClaimDecoders registers the Canvas claim decoders with the launch.Service
+// so that Canvas claims are available in launch.HandleOidcCallbackResponse Extensions
+func RegisterClaimDecoders(svc *launch.Service) {
+	svc.RegisterClaimDecoder(PlacementClaim, func(claim string, value interface{}) (interface{}, error) {
+		return launch.DecodeClaim[string](claim, value)
+	})
+	svc.RegisterClaimDecoder(LTI11LegacyUserIDClaim, func(claim string, value interface{}) (interface{}, error) {
+		return launch.DecodeClaim[string](claim, value)
+	})
+}
+
+// FromLaunch decodes the Canvas LaunchExtensions from a verified launch,
+// custom field values are expected to use the DefaultCustomFields names
+func FromLaunch(resp launch.HandleOidcCallbackResponse) (LaunchExtensions, error) {
+	ext := LaunchExtensions{}
+
+	for claim, field := range map[string]*string{
+		PlacementClaim:         &ext.Placement,
+		LTI11LegacyUserIDClaim: &ext.LTI11LegacyUserID,
+	} {
+		if _, ok := resp.RawClaims[claim]; !ok {
+			continue
+		}
+		value, err := launch.Claim[string](resp, claim)
+		if err != nil {
+			return ext, fmt.Errorf("failed to decode canvas launch extensions: %v", err)
+		}
+		*field = value
+	}
+
+	ext.UserID = customValue(resp.Claims.Custom, CustomFieldUserID)
+	ext.UserLoginID = customValue(resp.Claims.Custom, CustomFieldUserLoginID)
+	ext.CourseID = customValue(resp.Claims.Custom, CustomFieldCourseID)
+	ext.AccountID = customValue(resp.Claims.Custom, CustomFieldAccountID)
+	ext.APIDomain = customValue(resp.Claims.Custom, CustomFieldAPIDomain)
+
+	return ext, nil
+}
+
+// customValue returns the custom field value, Canvas sends the variable name itself (e.g. $Canvas.course.id)
+// when the substitution is not available in the launch context which is treated as an empty value
+func customValue(custom map[string]string, name string) string {
+	value := custom[name]
+	if strings.HasPrefix(value, "$") {
+		return ""
+	}
+
+	return value
+}
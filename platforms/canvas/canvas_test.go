@@ -0,0 +1,129 @@
+package canvas
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stevenweathers/peregrine-lti/launch"
+	"github.com/stevenweathers/peregrine-lti/peregrine"
+)
+
+func TestFromLaunch(t *testing.T) {
+	t.Parallel()
+	ext, err := FromLaunch(launch.HandleOidcCallbackResponse{
+		Claims: peregrine.LTI1p3Claims{
+			Custom: map[string]string{
+				CustomFieldUserID:    "42",
+				CustomFieldCourseID:  "1337",
+				CustomFieldAPIDomain: "canvas.test.instructure.com",
+				CustomFieldAccountID: "$Canvas.account.id",
+			},
+		},
+		RawClaims: map[string]interface{}{
+			PlacementClaim:         "course_navigation",
+			LTI11LegacyUserIDClaim: "535fa085f22b4655f48cd5a36a9215f64c062838",
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if ext.Placement != "course_navigation" {
+		t.Fatalf("expected placement %s to equal course_navigation", ext.Placement)
+	}
+	if ext.LTI11LegacyUserID != "535fa085f22b4655f48cd5a36a9215f64c062838" {
+		t.Fatalf("expected lti11_legacy_user_id %s to equal 535fa085f22b4655f48cd5a36a9215f64c062838", ext.LTI11LegacyUserID)
+	}
+	if ext.UserID != "42" {
+		t.Fatalf("expected user id %s to equal 42", ext.UserID)
+	}
+	if ext.CourseID != "1337" {
+		t.Fatalf("expected course id %s to equal 1337", ext.CourseID)
+	}
+	if ext.APIDomain != "canvas.test.instructure.com" {
+		t.Fatalf("expected api domain %s to equal canvas.test.instructure.com", ext.APIDomain)
+	}
+	if ext.AccountID != "" {
+		t.Fatalf("expected unsubstituted account id %s to be empty", ext.AccountID)
+	}
+}
+
+func TestFromLaunchInvalidClaim(t *testing.T) {
+	t.Parallel()
+	_, err := FromLaunch(launch.HandleOidcCallbackResponse{
+		RawClaims: map[string]interface{}{
+			PlacementClaim: []string{"course_navigation"},
+		},
+	})
+	if err == nil || !strings.Contains(err.Error(), "failed to decode canvas launch extensions") {
+		t.Fatalf("expected error: %v", err)
+	}
+}
+
+func TestDeveloperKeyConfigJSON(t *testing.T) {
+	t.Parallel()
+	cfg := DeveloperKeyConfig{
+		Title:             "Peregrine",
+		Description:       "Peregrine LTI tool",
+		OIDCInitiationURL: "https://stevenweathers.dev/lti/login",
+		TargetLinkURI:     "https://stevenweathers.dev/",
+		PublicJWKURL:      "https://stevenweathers.dev/lti/jwks",
+		Extensions: []DeveloperKeyExtension{{
+			Platform:     PlatformName,
+			PrivacyLevel: PrivacyLevelPublic,
+			Settings: DeveloperKeySettings{
+				Placements: []Placement{{
+					Placement:   "course_navigation",
+					Enabled:     true,
+					MessageType: MessageTypeResourceLink,
+				}},
+			},
+		}},
+		CustomFields: DefaultCustomFields(),
+	}
+
+	b, err := cfg.JSON()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var decoded map[string]interface{}
+	if err = json.Unmarshal(b, &decoded); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if decoded["oidc_initiation_url"] != "https://stevenweathers.dev/lti/login" {
+		t.Fatalf("expected oidc_initiation_url to be rendered")
+	}
+	if _, ok := decoded["scopes"].([]interface{}); !ok {
+		t.Fatalf("expected scopes to be rendered as an empty array")
+	}
+	custom := decoded["custom_fields"].(map[string]interface{})
+	if custom[CustomFieldCourseID] != "$Canvas.course.id" {
+		t.Fatalf("expected %s custom field to be rendered", CustomFieldCourseID)
+	}
+}
+
+func TestDeveloperKeyConfigValidate(t *testing.T) {
+	t.Parallel()
+	cfg := DeveloperKeyConfig{
+		Title:             "Peregrine",
+		Description:       "Peregrine LTI tool",
+		OIDCInitiationURL: "https://stevenweathers.dev/lti/login",
+		TargetLinkURI:     "https://stevenweathers.dev/",
+	}
+	if err := cfg.Validate(); err == nil || err.Error() != "MISSING_PUBLIC_JWK" {
+		t.Fatalf("expected MISSING_PUBLIC_JWK error: %v", err)
+	}
+
+	cfg.PublicJWKURL = "https://stevenweathers.dev/lti/jwks"
+	cfg.Extensions = []DeveloperKeyExtension{{Platform: "moodle"}}
+	if err := cfg.Validate(); err == nil || err.Error() != "INVALID_EXTENSION_PLATFORM" {
+		t.Fatalf("expected INVALID_EXTENSION_PLATFORM error: %v", err)
+	}
+
+	cfg.OIDCInitiationURL = ""
+	if _, err := cfg.JSON(); err == nil || !strings.Contains(err.Error(), "INVALID_OIDC_INITIATION_URL") {
+		t.Fatalf("expected INVALID_OIDC_INITIATION_URL error: %v", err)
+	}
+}
@@ -0,0 +1,126 @@
+package canvas
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+const (
+	// PlatformName is the platform value of the Canvas developer key extension
+	PlatformName = "canvas.instructure.com"
+	// PrivacyLevelPublic sends the users name and email along with the launch
+	PrivacyLevelPublic = "public"
+	// PrivacyLevelNameOnly sends only the users name along with the launch
+	PrivacyLevelNameOnly = "name_only"
+	// PrivacyLevelEmailOnly sends only the users email along with the launch
+	PrivacyLevelEmailOnly = "email_only"
+	// PrivacyLevelAnonymous sends no user identifying information along with the launch
+	PrivacyLevelAnonymous = "anonymous"
+	// MessageTypeResourceLink is the LTI 1.3 resource link launch message type
+	MessageTypeResourceLink = "LtiResourceLinkRequest"
+	// MessageTypeDeepLinking is the LTI Advantage deep linking message type
+	MessageTypeDeepLinking = "LtiDeepLinkingRequest"
+)
+
+// DeveloperKeyConfig is the Canvas LTI developer key JSON configuration
+// see https://canvas.instructure.com/doc/api/file.lti_dev_key_config.html
+type DeveloperKeyConfig struct {
+	// Title (REQUIRED) is the default name of the tool
+	Title string `json:"title"`
+	// Description (REQUIRED) is the description of the tool
+	Description string `json:"description"`
+	// OIDCInitiationURL (REQUIRED) is the tools OIDC third party initiated login url
+	OIDCInitiationURL string `json:"oidc_initiation_url"`
+	// TargetLinkURI (REQUIRED) is the tools default launch url
+	TargetLinkURI string `json:"target_link_uri"`
+	// PublicJWKURL (REQUIRED unless PublicJWK is set) is the tools JWK key set url
+	PublicJWKURL string `json:"public_jwk_url,omitempty"`
+	// PublicJWK (REQUIRED unless PublicJWKURL is set) is the tools public JWK
+	PublicJWK json.RawMessage `json:"public_jwk,omitempty"`
+	// Scopes (OPTIONAL) are the LTI Advantage scopes the tool requests
+	Scopes []string `json:"scopes"`
+	// Extensions (REQUIRED) contains the Canvas specific tool settings
+	Extensions []DeveloperKeyExtension `json:"extensions"`
+	// CustomFields (OPTIONAL) are sent with every launch, values may use Canvas variable substitutions
+	CustomFields map[string]string `json:"custom_fields,omitempty"`
+}
+
+// DeveloperKeyExtension is the Canvas platform extension of the DeveloperKeyConfig
+type DeveloperKeyExtension struct {
+	// Domain (OPTIONAL) is the domain of the tool
+	Domain string `json:"domain,omitempty"`
+	// ToolID (OPTIONAL) is an identifier for the tool
+	ToolID string `json:"tool_id,omitempty"`
+	// Platform (REQUIRED) must be canvas.instructure.com
+	Platform string `json:"platform"`
+	// PrivacyLevel (REQUIRED) determines which user identifying claims are sent e.g. PrivacyLevelPublic
+	PrivacyLevel string `json:"privacy_level"`
+	// Settings (REQUIRED) contains the tools placements
+	Settings DeveloperKeySettings `json:"settings"`
+}
+
+// DeveloperKeySettings are the Canvas tool settings of a DeveloperKeyExtension
+type DeveloperKeySettings struct {
+	// Text (OPTIONAL) is the default text shown for the tool in Canvas
+	Text string `json:"text,omitempty"`
+	// IconURL (OPTIONAL) is the default icon shown for the tool in Canvas
+	IconURL string `json:"icon_url,omitempty"`
+	// Placements (REQUIRED) are the Canvas locations the tool is available from
+	Placements []Placement `json:"placements"`
+}
+
+// Placement is a Canvas location the tool can be launched from
+// see https://canvas.instructure.com/doc/api/file.placements_overview.html
+type Placement struct {
+	// Placement (REQUIRED) is the Canvas placement name e.g. course_navigation
+	Placement string `json:"placement"`
+	// Text (OPTIONAL) is the text shown for the placement
+	Text string `json:"text,omitempty"`
+	// Enabled (OPTIONAL) determines whether the placement is enabled
+	Enabled bool `json:"enabled"`
+	// IconURL (OPTIONAL) is the icon shown for the placement
+	IconURL string `json:"icon_url,omitempty"`
+	// MessageType (OPTIONAL) is the LTI message type of the placement launch, defaults to MessageTypeResourceLink
+	MessageType string `json:"message_type,omitempty"`
+	// TargetLinkURI (OPTIONAL) is the placement specific launch url
+	TargetLinkURI string `json:"target_link_uri,omitempty"`
+}
+
+// Validate checks the DeveloperKeyConfig for the values Canvas requires
+func (c DeveloperKeyConfig) Validate() error {
+	if c.Title == "" {
+		return fmt.Errorf("MISSING_TITLE")
+	}
+	if c.Description == "" {
+		return fmt.Errorf("MISSING_DESCRIPTION")
+	}
+	if _, err := url.ParseRequestURI(c.OIDCInitiationURL); err != nil {
+		return fmt.Errorf("INVALID_OIDC_INITIATION_URL")
+	}
+	if _, err := url.ParseRequestURI(c.TargetLinkURI); err != nil {
+		return fmt.Errorf("INVALID_TARGET_LINK_URI")
+	}
+	if c.PublicJWKURL == "" && len(c.PublicJWK) == 0 {
+		return fmt.Errorf("MISSING_PUBLIC_JWK")
+	}
+	for _, ext := range c.Extensions {
+		if ext.Platform != PlatformName {
+			return fmt.Errorf("INVALID_EXTENSION_PLATFORM")
+		}
+	}
+
+	return nil
+}
+
+// JSON validates and renders the DeveloperKeyConfig as the JSON to paste into a Canvas developer key
+func (c DeveloperKeyConfig) JSON() ([]byte, error) {
+	if err := c.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid canvas developer key config: %v", err)
+	}
+	if c.Scopes == nil {
+		c.Scopes = []string{}
+	}
+
+	return json.MarshalIndent(c, "", "  ")
+}
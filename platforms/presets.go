@@ -0,0 +1,307 @@
+package platforms
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/stevenweathers/peregrine-lti/peregrine"
+)
+
+const (
+	hostPlaceholder     = "{host}"
+	clientIDPlaceholder = "{client_id}"
+)
+
+// Known platform quirks, see Preset Quirks
+const (
+	// QuirkDeploymentIDLoginParam the platform sends deployment_id instead of lti_deployment_id in the login
+	// initiation request, see launch.GetLoginParamsFromRequestFormValues
+	QuirkDeploymentIDLoginParam = "DEPLOYMENT_ID_LOGIN_PARAM"
+	// QuirkSharedIssuer every tenant of the platform shares the same issuer,
+	// registrations are distinguished by client_id only
+	QuirkSharedIssuer = "SHARED_ISSUER"
+	// QuirkOptionalClientID the platform may omit client_id from the login initiation request,
+	// see peregrine.RegistrationIssuerRepo
+	QuirkOptionalClientID = "OPTIONAL_CLIENT_ID"
+	// QuirkPerClientKeySet the platform key set url is specific to the tools client_id
+	QuirkPerClientKeySet = "PER_CLIENT_KEY_SET"
+	// QuirkCentralTokenURL the platform access token url is hosted centrally rather than on the tenant host
+	QuirkCentralTokenURL = "CENTRAL_TOKEN_URL"
+	// QuirkPerToolTokenURL the platform access token url is specific to the tool registration
+	// and must be copied from the platforms tool registration page
+	QuirkPerToolTokenURL = "PER_TOOL_TOKEN_URL"
+	// QuirkIssuerIncludesPath the platform issuer is the full site url which may include a path
+	QuirkIssuerIncludesPath = "ISSUER_INCLUDES_PATH"
+)
+
+// Quirk documents a known deviation or peculiarity of a platform from the LTI 1.3 spec
+type Quirk struct {
+	// Code is the unique identifier of the Quirk e.g. QuirkDeploymentIDLoginParam
+	Code string
+	// Description is the human-readable description of the Quirk
+	Description string
+}
+
+// Tenant identifies the platform instance a Preset builds a peregrine.Platform for
+type Tenant struct {
+	// Host (REQUIRED for self-hosted platforms) is the hostname of the platform instance ex. moodle.school.edu
+	Host string
+	// ClientID (REQUIRED for platforms with QuirkPerClientKeySet) is the tools client_id in the platform
+	ClientID string
+}
+
+// Preset contains the well known values needed to configure a peregrine.Platform for an LMS,
+// urls may contain {host} and {client_id} placeholders which are filled in from the Tenant
+type Preset struct {
+	// Key is the unique identifier of the Preset in the catalogue ex. canvas
+	Key string
+	// Name is the human-readable name of the Preset
+	Name string
+	// Issuer is the platforms id_token issuer
+	Issuer string
+	// KeySetURL is the platforms JWK key set url
+	KeySetURL string
+	// AuthLoginURL is the platforms OIDC authentication url
+	AuthLoginURL string
+	// TokenURL is the platforms OAuth2 access token url, empty when it can not be derived
+	TokenURL string
+	// Quirks are the platforms known deviations from the LTI 1.3 spec
+	Quirks []Quirk
+}
+
+var (
+	// Canvas is Instructure's hosted Canvas LMS production environment
+	Canvas = Preset{
+		Key:          "canvas",
+		Name:         "Canvas LMS",
+		Issuer:       "https://canvas.instructure.com",
+		KeySetURL:    "https://sso.canvaslms.com/api/lti/security/jwks",
+		AuthLoginURL: "https://sso.canvaslms.com/api/lti/authorize_redirect",
+		TokenURL:     "https://sso.canvaslms.com/login/oauth2/token",
+		Quirks:       canvasQuirks(),
+	}
+	// CanvasBeta is Instructure's hosted Canvas LMS beta environment
+	CanvasBeta = Preset{
+		Key:          "canvas-beta",
+		Name:         "Canvas LMS (Beta)",
+		Issuer:       "https://canvas.beta.instructure.com",
+		KeySetURL:    "https://sso.beta.canvaslms.com/api/lti/security/jwks",
+		AuthLoginURL: "https://sso.beta.canvaslms.com/api/lti/authorize_redirect",
+		TokenURL:     "https://sso.beta.canvaslms.com/login/oauth2/token",
+		Quirks:       canvasQuirks(),
+	}
+	// CanvasTest is Instructure's hosted Canvas LMS test environment
+	CanvasTest = Preset{
+		Key:          "canvas-test",
+		Name:         "Canvas LMS (Test)",
+		Issuer:       "https://canvas.test.instructure.com",
+		KeySetURL:    "https://sso.test.canvaslms.com/api/lti/security/jwks",
+		AuthLoginURL: "https://sso.test.canvaslms.com/api/lti/authorize_redirect",
+		TokenURL:     "https://sso.test.canvaslms.com/login/oauth2/token",
+		Quirks:       canvasQuirks(),
+	}
+	// Moodle is a self-hosted Moodle LMS site, the Tenant Host should include any site path
+	Moodle = Preset{
+		Key:          "moodle",
+		Name:         "Moodle",
+		Issuer:       "https://{host}",
+		KeySetURL:    "https://{host}/mod/lti/certs.php",
+		AuthLoginURL: "https://{host}/mod/lti/auth.php",
+		TokenURL:     "https://{host}/mod/lti/token.php",
+		Quirks: []Quirk{
+			{
+				Code:        QuirkIssuerIncludesPath,
+				Description: "The issuer is the Moodle site wwwroot which may include a path and has no trailing slash",
+			},
+			{
+				Code:        QuirkOptionalClientID,
+				Description: "Older Moodle versions omit client_id from the login initiation request",
+			},
+		},
+	}
+	// BlackboardLearn is Anthology's Blackboard Learn, registered through the Blackboard developer portal
+	BlackboardLearn = Preset{
+		Key:          "blackboard",
+		Name:         "Blackboard Learn",
+		Issuer:       "https://blackboard.com",
+		KeySetURL:    "https://developer.blackboard.com/api/v1/management/applications/{client_id}/jwks.json",
+		AuthLoginURL: "https://developer.blackboard.com/api/v1/gateway/oidcauth",
+		TokenURL:     "https://developer.blackboard.com/api/v1/gateway/oauth2/jwttoken",
+		Quirks: []Quirk{
+			{
+				Code:        QuirkSharedIssuer,
+				Description: "Every Blackboard Learn instance uses the https://blackboard.com issuer",
+			},
+			{
+				Code:        QuirkPerClientKeySet,
+				Description: "The key set url is specific to the tools developer portal application id (client_id)",
+			},
+			{
+				Code:        QuirkOptionalClientID,
+				Description: "Older Blackboard Learn versions omit client_id from the login initiation request",
+			},
+		},
+	}
+	// D2LBrightspace is D2L's Brightspace LMS
+	D2LBrightspace = Preset{
+		Key:          "brightspace",
+		Name:         "D2L Brightspace",
+		Issuer:       "https://{host}",
+		KeySetURL:    "https://{host}/d2l/.well-known/jwks",
+		AuthLoginURL: "https://{host}/d2l/lti/authenticate",
+		TokenURL:     "https://auth.brightspace.com/core/connect/token",
+		Quirks: []Quirk{
+			{
+				Code:        QuirkCentralTokenURL,
+				Description: "Access tokens are issued by https://auth.brightspace.com rather than the tenant host",
+			},
+		},
+	}
+	// Sakai is a self-hosted Sakai LMS site
+	Sakai = Preset{
+		Key:          "sakai",
+		Name:         "Sakai",
+		Issuer:       "https://{host}",
+		KeySetURL:    "https://{host}/imsblis/lti13/keyset",
+		AuthLoginURL: "https://{host}/imsoidc/lti13/oidc_auth",
+		Quirks: []Quirk{
+			{
+				Code:        QuirkPerToolTokenURL,
+				Description: "The token url includes the Sakai tool id and must be copied from the tool registration",
+			},
+		},
+	}
+	// Schoology is PowerSchool's Schoology LMS
+	Schoology = Preset{
+		Key:          "schoology",
+		Name:         "Schoology",
+		Issuer:       "https://schoology.schoology.com",
+		KeySetURL:    "https://lti-service.svc.schoology.com/lti-service/.well-known/jwks",
+		AuthLoginURL: "https://lti-service.svc.schoology.com/lti-service/authorize-redirect",
+		TokenURL:     "https://lti-service.svc.schoology.com/lti-service/access-token",
+		Quirks: []Quirk{
+			{
+				Code:        QuirkSharedIssuer,
+				Description: "Every Schoology instance uses the https://schoology.schoology.com issuer",
+			},
+		},
+	}
+
+	// catalogue holds clones of the presets so that modifying an exported Preset does not change the catalogue
+	catalogue = []Preset{
+		Canvas.clone(), CanvasBeta.clone(), CanvasTest.clone(), Moodle.clone(),
+		BlackboardLearn.clone(), D2LBrightspace.clone(), Sakai.clone(), Schoology.clone(),
+	}
+)
+
+// All returns every Preset in the catalogue
+func All() []Preset {
+	presets := make([]Preset, len(catalogue))
+	for i, p := range catalogue {
+		presets[i] = p.clone()
+	}
+
+	return presets
+}
+
+// Lookup returns the Preset by Key from the catalogue
+func Lookup(key string) (Preset, bool) {
+	for _, p := range catalogue {
+		if p.Key == key {
+			return p.clone(), true
+		}
+	}
+
+	return Preset{}, false
+}
+
+// canvasQuirks returns the Quirks of the Canvas presets, a new slice per Preset so they share no backing array
+func canvasQuirks() []Quirk {
+	return []Quirk{
+		{
+			Code:        QuirkDeploymentIDLoginParam,
+			Description: "Canvas sends deployment_id instead of lti_deployment_id in the login initiation request",
+		},
+		{
+			Code:        QuirkSharedIssuer,
+			Description: "Every hosted Canvas instance in an environment shares the same issuer",
+		},
+	}
+}
+
+// clone returns a copy of the Preset with its own Quirks so the catalogue can not be modified through it
+func (p Preset) clone() Preset {
+	p.Quirks = append([]Quirk(nil), p.Quirks...)
+
+	return p
+}
+
+// HasQuirk returns whether the Preset has the Quirk by code
+func (p Preset) HasQuirk(code string) bool {
+	for _, q := range p.Quirks {
+		if q.Code == code {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RequiresHost returns whether the Preset needs a Tenant Host to build a peregrine.Platform
+func (p Preset) RequiresHost() bool {
+	return p.requires(hostPlaceholder)
+}
+
+// RequiresClientID returns whether the Preset needs a Tenant ClientID to build a peregrine.Platform
+func (p Preset) RequiresClientID() bool {
+	return p.requires(clientIDPlaceholder)
+}
+
+// Platform builds a peregrine.Platform for the Tenant, the Platform ID is left for the tool to assign
+func (p Preset) Platform(tenant Tenant) (peregrine.Platform, error) {
+	platform := peregrine.Platform{}
+
+	host, err := normalizeHost(tenant.Host)
+	if err != nil {
+		return platform, err
+	}
+	if p.RequiresHost() && host == "" {
+		return platform, fmt.Errorf("%s preset requires a tenant host", p.Key)
+	}
+	if p.RequiresClientID() && tenant.ClientID == "" {
+		return platform, fmt.Errorf("%s preset requires a tenant client_id", p.Key)
+	}
+
+	r := strings.NewReplacer(hostPlaceholder, host, clientIDPlaceholder, tenant.ClientID)
+	platform.Issuer = r.Replace(p.Issuer)
+	platform.KeySetURL = r.Replace(p.KeySetURL)
+	platform.AuthLoginURL = r.Replace(p.AuthLoginURL)
+	platform.TokenURL = r.Replace(p.TokenURL)
+
+	return platform, nil
+}
+
+func (p Preset) requires(placeholder string) bool {
+	for _, u := range []string{p.Issuer, p.KeySetURL, p.AuthLoginURL, p.TokenURL} {
+		if strings.Contains(u, placeholder) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// normalizeHost strips an https scheme and trailing slash from the host, platforms must use https
+func normalizeHost(host string) (string, error) {
+	host = strings.TrimSpace(host)
+	if strings.HasPrefix(host, "http://") {
+		return "", fmt.Errorf("tenant host %s must use https", host)
+	}
+	host = strings.TrimPrefix(host, "https://")
+	host = strings.TrimRight(host, "/")
+	if strings.ContainsAny(host, "?#") {
+		return "", fmt.Errorf("tenant host %s must not contain a query or fragment", host)
+	}
+
+	return host, nil
+}
@@ -0,0 +1,119 @@
+package platforms
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPresetPlatformCanvas(t *testing.T) {
+	t.Parallel()
+	platform, err := Canvas.Platform(Tenant{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if platform.Issuer != "https://canvas.instructure.com" {
+		t.Fatalf("expected issuer %s to equal https://canvas.instructure.com", platform.Issuer)
+	}
+	if platform.KeySetURL != "https://sso.canvaslms.com/api/lti/security/jwks" {
+		t.Fatalf("expected key set url %s to equal https://sso.canvaslms.com/api/lti/security/jwks", platform.KeySetURL)
+	}
+	if platform.AuthLoginURL != "https://sso.canvaslms.com/api/lti/authorize_redirect" {
+		t.Fatalf("expected auth login url %s to equal https://sso.canvaslms.com/api/lti/authorize_redirect", platform.AuthLoginURL)
+	}
+	if !Canvas.HasQuirk(QuirkDeploymentIDLoginParam) {
+		t.Fatalf("expected canvas preset to have %s quirk", QuirkDeploymentIDLoginParam)
+	}
+}
+
+func TestPresetPlatformMoodle(t *testing.T) {
+	t.Parallel()
+	platform, err := Moodle.Platform(Tenant{Host: "https://school.edu/moodle/"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if platform.Issuer != "https://school.edu/moodle" {
+		t.Fatalf("expected issuer %s to equal https://school.edu/moodle", platform.Issuer)
+	}
+	if platform.KeySetURL != "https://school.edu/moodle/mod/lti/certs.php" {
+		t.Fatalf("expected key set url %s to equal https://school.edu/moodle/mod/lti/certs.php", platform.KeySetURL)
+	}
+	if platform.TokenURL != "https://school.edu/moodle/mod/lti/token.php" {
+		t.Fatalf("expected token url %s to equal https://school.edu/moodle/mod/lti/token.php", platform.TokenURL)
+	}
+
+	_, err = Moodle.Platform(Tenant{})
+	if err == nil || !strings.Contains(err.Error(), "moodle preset requires a tenant host") {
+		t.Fatalf("expected error: %v", err)
+	}
+
+	_, err = Moodle.Platform(Tenant{Host: "http://school.edu"})
+	if err == nil || !strings.Contains(err.Error(), "must use https") {
+		t.Fatalf("expected error: %v", err)
+	}
+}
+
+func TestPresetPlatformBlackboard(t *testing.T) {
+	t.Parallel()
+	_, err := BlackboardLearn.Platform(Tenant{})
+	if err == nil || !strings.Contains(err.Error(), "blackboard preset requires a tenant client_id") {
+		t.Fatalf("expected error: %v", err)
+	}
+
+	platform, err := BlackboardLearn.Platform(Tenant{ClientID: "abc-123"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expectedKeySetURL := "https://developer.blackboard.com/api/v1/management/applications/abc-123/jwks.json"
+	if platform.KeySetURL != expectedKeySetURL {
+		t.Fatalf("expected key set url %s to equal %s", platform.KeySetURL, expectedKeySetURL)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	t.Parallel()
+	for _, p := range All() {
+		found, ok := Lookup(p.Key)
+		if !ok || found.Name != p.Name {
+			t.Fatalf("expected preset %s to be found in catalogue", p.Key)
+		}
+		if p.Issuer == "" || p.KeySetURL == "" || p.AuthLoginURL == "" {
+			t.Fatalf("expected preset %s to have issuer, key set url and auth login url", p.Key)
+		}
+	}
+
+	if _, ok := Lookup("unknown"); ok {
+		t.Fatal("expected unknown preset to not be found")
+	}
+}
+
+func TestPresetQuirksNotShared(t *testing.T) {
+	t.Parallel()
+	presets := All()
+	presets[0].Quirks[0].Code = "MODIFIED"
+
+	canvas, _ := Lookup(Canvas.Key)
+	canvas.Quirks[0].Code = "MODIFIED"
+
+	for _, p := range All() {
+		if p.HasQuirk("MODIFIED") {
+			t.Fatalf("expected catalogue preset %s quirks to be unmodified", p.Key)
+		}
+	}
+	if CanvasBeta.HasQuirk("MODIFIED") || CanvasTest.HasQuirk("MODIFIED") {
+		t.Fatal("expected canvas presets to not share quirks")
+	}
+}
+
+// TestExportedPresetNotAliased is not parallel as it modifies the exported Moodle preset
+func TestExportedPresetNotAliased(t *testing.T) {
+	code := Moodle.Quirks[0].Code
+	Moodle.Quirks[0].Code = "MODIFIED"
+	defer func() { Moodle.Quirks[0].Code = code }()
+
+	moodle, ok := Lookup(Moodle.Key)
+	if !ok || moodle.HasQuirk("MODIFIED") || !moodle.HasQuirk(code) {
+		t.Fatalf("expected catalogue moodle preset quirks to be unmodified got %+v", moodle.Quirks)
+	}
+}
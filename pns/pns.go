@@ -0,0 +1,334 @@
+// Package pns receives 1EdTech Platform Notification Service notices, signed JWTs the platform pushes to the tool
+// when something happens in the platform (e.g. a context is copied), and manages the tools notice handler
+// subscriptions with the platform
+package pns
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/stevenweathers/peregrine-lti/internal/httpx"
+	"github.com/stevenweathers/peregrine-lti/launch"
+	"github.com/stevenweathers/peregrine-lti/peregrine"
+)
+
+// Notice types of the Platform Notification Service, a platform may send other types which can be
+// handled by registering a NoticeHandler for the type
+const (
+	// NoticeTypeHelloWorld is sent by the platform to verify a newly subscribed notice handler
+	NoticeTypeHelloWorld = "LtiHelloWorldNotice"
+	// NoticeTypeContextCopy is sent when a context the tool is deployed in is copied to a new context
+	NoticeTypeContextCopy = "LtiContextCopyNotice"
+	// NoticeTypeAssetProcessorSubmission is sent when a learner submits to an assignment using an asset processor
+	NoticeTypeAssetProcessorSubmission = "LtiAssetProcessorSubmissionNotice"
+)
+
+const (
+	// DefaultMaxBodySize is the default maximum size in bytes of a notice request body
+	DefaultMaxBodySize = 1 << 20
+
+	noticeClaim         = "https://purl.imsglobal.org/spec/lti/claim/notice"
+	deploymentIDClaim   = "https://purl.imsglobal.org/spec/lti/claim/deployment_id"
+	versionClaim        = "https://purl.imsglobal.org/spec/lti/claim/version"
+	versionClaimValue   = "1.3.0"
+	contextClaim        = "https://purl.imsglobal.org/spec/lti/claim/context"
+	originContextsClaim = "https://purl.imsglobal.org/spec/lti/claim/origin_contexts"
+)
+
+// ErrVerificationUnavailable is returned by VerifyNotice when the notice could not be verified because of
+// an infrastructure failure (e.g. the platform key set fetch failed),
+// the notice may be valid so the platform should resend it
+var ErrVerificationUnavailable = errors.New("NOTICE_VERIFICATION_UNAVAILABLE")
+
+// Notice is a verified notice sent by the platform
+type Notice struct {
+	// ID is the platforms unique id of the notice, a platform may resend a notice so handlers should be idempotent
+	ID string
+	// Type is the notice type e.g. NoticeTypeContextCopy
+	Type string
+	// Timestamp is when the platform created the notice
+	Timestamp time.Time
+	// Registration is the peregrine.Registration the notice was sent to
+	Registration peregrine.Registration
+	// DeploymentID is the platform deployment_id the notice belongs to
+	DeploymentID string
+	// Context (OPTIONAL) is the context the notice belongs to
+	Context *peregrine.ContextClaim
+	// OriginContexts (OPTIONAL) are the ids of the contexts a NoticeTypeContextCopy context was copied from
+	OriginContexts []string
+	// Claims are all the claims of the notice jwt, including those of notice types without typed fields
+	Claims map[string]interface{}
+}
+
+// noticeClaimValue is the notice claim of a notice jwt
+type noticeClaimValue struct {
+	ID        string `json:"id"`
+	Timestamp string `json:"timestamp"`
+	Type      string `json:"type"`
+}
+
+// NoticeHandler handles a verified Notice, returning an error to have the platform resend the notice
+type NoticeHandler interface {
+	HandleNotice(ctx context.Context, notice Notice) error
+}
+
+// NoticeHandlerFunc is an adapter to allow the use of ordinary functions as a NoticeHandler
+type NoticeHandlerFunc func(ctx context.Context, notice Notice) error
+
+// HandleNotice calls f(ctx, notice)
+func (f NoticeHandlerFunc) HandleNotice(ctx context.Context, notice Notice) error {
+	return f(ctx, notice)
+}
+
+// KeySetSource provides the key set of a peregrine.Platform, see launch.Service PlatformKeySet
+type KeySetSource interface {
+	PlatformKeySet(ctx context.Context, platform peregrine.Platform) (jwk.Set, error)
+}
+
+// Config holds all the configuration's for Receiver
+type Config struct {
+	// ClockSkew (OPTIONAL) is the leeway allowed when validating the notice exp and iat,
+	// defaults to launch.DefaultIDTokenClockSkew
+	ClockSkew time.Duration
+	// MaxBodySize (OPTIONAL) is the maximum size in bytes of a notice request body, defaults to DefaultMaxBodySize
+	MaxBodySize int64
+	// Logger (OPTIONAL) logs rejected notices and handler failures, defaults to discarding logs
+	Logger *slog.Logger
+}
+
+// Receiver is the tools notice handler endpoint, an http.Handler verifying the notices POSTed by the platform
+// and routing them to the NoticeHandler registered for their type, notices of a type without a
+// NoticeHandler are acknowledged and dropped
+type Receiver struct {
+	config  Config
+	keySets KeySetSource
+	dataSvc peregrine.ToolDataRepo
+
+	mu       sync.RWMutex
+	handlers map[string]NoticeHandler
+}
+
+// New returns a new Receiver verifying notices with the platform key sets of keySets (e.g. the launch.Service)
+// and the registrations of dataSvc
+func New(config Config, keySets KeySetSource, dataSvc peregrine.ToolDataRepo) *Receiver {
+	if config.ClockSkew == 0 {
+		config.ClockSkew = launch.DefaultIDTokenClockSkew
+	}
+	if config.MaxBodySize == 0 {
+		config.MaxBodySize = DefaultMaxBodySize
+	}
+	if config.Logger == nil {
+		config.Logger = launch.DiscardLogger()
+	}
+
+	return &Receiver{
+		config:   config,
+		keySets:  keySets,
+		dataSvc:  dataSvc,
+		handlers: map[string]NoticeHandler{},
+	}
+}
+
+// Handle registers the NoticeHandler for the notice type, replacing any previously registered handler
+func (rc *Receiver) Handle(noticeType string, handler NoticeHandler) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	rc.handlers[noticeType] = handler
+}
+
+// HandleFunc registers the handler func for the notice type
+func (rc *Receiver) HandleFunc(noticeType string, handler func(ctx context.Context, notice Notice) error) {
+	rc.Handle(noticeType, NoticeHandlerFunc(handler))
+}
+
+// noticeRequest is the body of a notice request, the platform may batch multiple notices
+type noticeRequest struct {
+	Notices []struct {
+		JWT string `json:"jwt"`
+	} `json:"notices"`
+}
+
+// ServeHTTP verifies and routes each notice of the request on its own, invalid notices are logged and dropped
+// without affecting the rest of the batch. It responds 500 when a notice could not be verified
+// (ErrVerificationUnavailable) or a NoticeHandler fails so that the platform resends the notices,
+// 401 when every notice is invalid and 200 otherwise
+func (rc *Receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req noticeRequest
+	body, err := httpx.ReadLimited(r.Body, rc.config.MaxBodySize)
+	if err != nil {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err = json.Unmarshal(body, &req); err != nil || len(req.Notices) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	status := http.StatusUnauthorized
+	for _, n := range req.Notices {
+		notice, err := rc.VerifyNotice(ctx, n.JWT)
+		if errors.Is(err, ErrVerificationUnavailable) {
+			rc.config.Logger.ErrorContext(ctx, "notice verification failed", slog.String("error", err.Error()))
+			status = http.StatusInternalServerError
+			continue
+		}
+		if err != nil {
+			rc.config.Logger.WarnContext(ctx, "notice rejected", slog.String("error", err.Error()))
+			continue
+		}
+
+		if err = rc.route(ctx, notice); err != nil {
+			rc.config.Logger.ErrorContext(ctx, "notice handler failed",
+				slog.String("notice_id", notice.ID),
+				slog.String("notice_type", notice.Type),
+				slog.String("error", err.Error()),
+			)
+			status = http.StatusInternalServerError
+			continue
+		}
+		if status == http.StatusUnauthorized {
+			status = http.StatusOK
+		}
+	}
+
+	w.WriteHeader(status)
+}
+
+// route calls the NoticeHandler registered for the notices type
+func (rc *Receiver) route(ctx context.Context, notice Notice) error {
+	rc.mu.RLock()
+	handler, ok := rc.handlers[notice.Type]
+	rc.mu.RUnlock()
+	if !ok {
+		return nil
+	}
+
+	return handler.HandleNotice(ctx, notice)
+}
+
+// VerifyNotice verifies the notice jwt was signed by the platform of the registration it is addressed to
+// (by its aud client_id) and decodes it into a Notice, platform key set fetch errors are wrapped with
+// ErrVerificationUnavailable while registration lookup errors reject the notice
+func (rc *Receiver) VerifyNotice(ctx context.Context, noticeJWT string) (Notice, error) {
+	var notice Notice
+
+	unverified, err := jwt.Parse([]byte(noticeJWT), jwt.WithVerify(false), jwt.WithValidate(false))
+	if err != nil {
+		return notice, fmt.Errorf("failed to parse notice jwt: %v", err)
+	}
+	clientID, err := noticeClientID(unverified)
+	if err != nil {
+		return notice, err
+	}
+
+	// the notice is not yet verified so any client_id it claims is untrusted input, a failed lookup rejects the
+	// notice rather than reporting ErrVerificationUnavailable so that forged notices can not cause 5xx responses
+	registration, err := rc.dataSvc.GetRegistrationByClientID(ctx, clientID)
+	if err != nil {
+		return notice, fmt.Errorf("failed to get registration by client id %s: %w", clientID, err)
+	}
+	if registration.Platform == nil {
+		return notice, fmt.Errorf("registration %s has no platform", clientID)
+	}
+
+	keySet, err := rc.keySets.PlatformKeySet(ctx, *registration.Platform)
+	if err != nil {
+		return notice, fmt.Errorf("failed to get platform key set: %v: %w", err, ErrVerificationUnavailable)
+	}
+	verified, err := jwt.Parse([]byte(noticeJWT),
+		jwt.WithKeySet(keySet),
+		jwt.WithIssuer(registration.Platform.Issuer),
+		jwt.WithAudience(clientID),
+		jwt.WithAcceptableSkew(rc.config.ClockSkew),
+		jwt.WithRequiredClaim(jwt.ExpirationKey),
+		jwt.WithRequiredClaim(jwt.IssuedAtKey),
+		jwt.WithRequiredClaim(deploymentIDClaim),
+		jwt.WithClaimValue(versionClaim, versionClaimValue),
+	)
+	if err != nil {
+		return notice, fmt.Errorf("failed to verify notice jwt: %v", err)
+	}
+
+	return decodeNotice(ctx, verified, registration)
+}
+
+// noticeClientID returns the client_id the notice is addressed to, the azp when the notice has multiple audiences
+func noticeClientID(token jwt.Token) (string, error) {
+	aud := token.Audience()
+	switch {
+	case len(aud) == 1:
+		return aud[0], nil
+	case len(aud) > 1:
+		if azp, ok := token.PrivateClaims()["azp"].(string); ok && azp != "" {
+			return azp, nil
+		}
+		return "", fmt.Errorf("notice jwt with multiple audiences missing azp")
+	default:
+		return "", fmt.Errorf("notice jwt missing aud")
+	}
+}
+
+// decodeNotice decodes the verified notice jwt claims into a Notice
+func decodeNotice(ctx context.Context, token jwt.Token, registration peregrine.Registration) (Notice, error) {
+	notice := Notice{
+		Registration: registration,
+	}
+
+	claims, err := token.AsMap(ctx)
+	if err != nil {
+		return notice, fmt.Errorf("failed to read notice jwt claims: %v", err)
+	}
+	notice.Claims = claims
+	notice.DeploymentID, _ = claims[deploymentIDClaim].(string)
+
+	if _, ok := claims[noticeClaim]; !ok {
+		return notice, fmt.Errorf("%s claim is required", noticeClaim)
+	}
+	nc, err := launch.DecodeClaim[noticeClaimValue](noticeClaim, claims[noticeClaim])
+	if err != nil {
+		return notice, err
+	}
+	if nc.ID == "" || nc.Type == "" {
+		return notice, fmt.Errorf("%s claim missing id or type", noticeClaim)
+	}
+	notice.ID = nc.ID
+	notice.Type = nc.Type
+	if nc.Timestamp != "" {
+		notice.Timestamp, err = time.Parse(time.RFC3339, nc.Timestamp)
+		if err != nil {
+			return notice, fmt.Errorf("%s claim timestamp is not RFC3339", noticeClaim)
+		}
+	}
+
+	if value, ok := claims[contextClaim]; ok {
+		lmsContext, err := launch.DecodeClaim[peregrine.ContextClaim](contextClaim, value)
+		if err != nil {
+			return notice, err
+		}
+		notice.Context = &lmsContext
+	}
+	if value, ok := claims[originContextsClaim]; ok {
+		notice.OriginContexts, err = launch.DecodeClaim[[]string](originContextsClaim, value)
+		if err != nil {
+			return notice, err
+		}
+	}
+
+	return notice, nil
+}
@@ -0,0 +1,264 @@
+package pns
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/stevenweathers/peregrine-lti/launch"
+	"github.com/stevenweathers/peregrine-lti/peregrine"
+)
+
+const (
+	testIssuer       = "https://canvas.test.instructure.com"
+	testClientID     = "10000000000001"
+	testDeploymentID = "1:8865aa05b4b79b64a91a86042e43af5ea8ae79eb"
+	// testFailingClientID is a client_id whose registration lookup fails
+	testFailingClientID = "10000000000002"
+)
+
+var _ KeySetSource = (*launch.Service)(nil)
+
+type mockKeySets struct {
+	keySet jwk.Set
+	err    error
+}
+
+func (m mockKeySets) PlatformKeySet(ctx context.Context, platform peregrine.Platform) (jwk.Set, error) {
+	return m.keySet, m.err
+}
+
+type mockStoreSvc struct {
+	peregrine.ToolDataRepo
+}
+
+func (m mockStoreSvc) GetRegistrationByClientID(ctx context.Context, clientID string) (peregrine.Registration, error) {
+	if clientID == testFailingClientID {
+		return peregrine.Registration{}, fmt.Errorf("database unavailable")
+	}
+	if clientID != testClientID {
+		return peregrine.Registration{}, peregrine.ErrRegistrationNotFound
+	}
+
+	return peregrine.Registration{
+		ID:       uuid.New(),
+		ClientID: testClientID,
+		Platform: &peregrine.Platform{
+			Issuer:    testIssuer,
+			KeySetURL: testIssuer + "/api/lti/security/jwks",
+		},
+	}, nil
+}
+
+func newTestKeys(t *testing.T) (jwk.Key, jwk.Set) {
+	t.Helper()
+	raw, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, _ := jwk.FromRaw(raw)
+	_ = key.Set(jwk.KeyIDKey, "test-key")
+	_ = key.Set(jwk.AlgorithmKey, jwa.RS256)
+	pub, _ := key.PublicKey()
+	set := jwk.NewSet()
+	_ = set.AddKey(pub)
+
+	return key, set
+}
+
+func testNoticeBuilder(noticeType string) *jwt.Builder {
+	return jwt.NewBuilder().
+		Issuer(testIssuer).
+		Audience([]string{testClientID}).
+		IssuedAt(time.Now()).
+		Expiration(time.Now().Add(time.Minute*5)).
+		Claim(deploymentIDClaim, testDeploymentID).
+		Claim(versionClaim, versionClaimValue).
+		Claim(noticeClaim, map[string]interface{}{
+			"id":        "notice-1",
+			"timestamp": "2024-05-01T12:00:00Z",
+			"type":      noticeType,
+		})
+}
+
+func signTestNotice(t *testing.T, key jwk.Key, builder *jwt.Builder) string {
+	t.Helper()
+	tok, err := builder.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed, err := jwt.Sign(tok, jwt.WithKey(jwa.RS256, key))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(signed)
+}
+
+func postNotices(rc *Receiver, jwts ...string) *httptest.ResponseRecorder {
+	body := noticeRequest{}
+	for _, j := range jwts {
+		body.Notices = append(body.Notices, struct {
+			JWT string `json:"jwt"`
+		}{JWT: j})
+	}
+	b, _ := json.Marshal(body)
+	w := httptest.NewRecorder()
+	rc.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/lti/notices", strings.NewReader(string(b))))
+
+	return w
+}
+
+func TestReceiverRoutesNotice(t *testing.T) {
+	t.Parallel()
+	key, set := newTestKeys(t)
+	rc := New(Config{}, mockKeySets{keySet: set}, mockStoreSvc{})
+
+	var received Notice
+	rc.HandleFunc(NoticeTypeContextCopy, func(ctx context.Context, notice Notice) error {
+		received = notice
+		return nil
+	})
+
+	w := postNotices(rc, signTestNotice(t, key, testNoticeBuilder(NoticeTypeContextCopy).
+		Claim(contextClaim, map[string]interface{}{"id": "course-2", "title": "Biology 101"}).
+		Claim(originContextsClaim, []string{"course-1"})),
+	)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200 got %d", w.Code)
+	}
+	if received.ID != "notice-1" || received.DeploymentID != testDeploymentID {
+		t.Fatalf("unexpected notice %+v", received)
+	}
+	if received.Registration.ClientID != testClientID {
+		t.Fatalf("expected notice registration %s got %s", testClientID, received.Registration.ClientID)
+	}
+	if !received.Timestamp.Equal(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected notice timestamp %s", received.Timestamp)
+	}
+	if received.Context == nil || received.Context.ID != "course-2" {
+		t.Fatalf("expected notice context course-2 got %+v", received.Context)
+	}
+	if len(received.OriginContexts) != 1 || received.OriginContexts[0] != "course-1" {
+		t.Fatalf("expected origin contexts [course-1] got %v", received.OriginContexts)
+	}
+}
+
+func TestReceiverUnhandledNoticeType(t *testing.T) {
+	t.Parallel()
+	key, set := newTestKeys(t)
+	rc := New(Config{}, mockKeySets{keySet: set}, mockStoreSvc{})
+
+	w := postNotices(rc, signTestNotice(t, key, testNoticeBuilder(NoticeTypeHelloWorld)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200 got %d", w.Code)
+	}
+}
+
+func TestReceiverRejectsInvalidNotice(t *testing.T) {
+	t.Parallel()
+	key, set := newTestKeys(t)
+	otherKey, _ := newTestKeys(t)
+	tests := []struct {
+		name   string
+		notice string
+	}{
+		{name: "wrong key", notice: signTestNotice(t, otherKey, testNoticeBuilder(NoticeTypeHelloWorld))},
+		{name: "wrong issuer", notice: signTestNotice(t, key, testNoticeBuilder(NoticeTypeHelloWorld).Issuer("https://evil.example"))},
+		{name: "unknown client", notice: signTestNotice(t, key, testNoticeBuilder(NoticeTypeHelloWorld).Audience([]string{"someothertool"}))},
+		{name: "registration lookup failure", notice: signTestNotice(t, key, testNoticeBuilder(NoticeTypeHelloWorld).Audience([]string{testFailingClientID}))},
+		{name: "expired", notice: signTestNotice(t, key, testNoticeBuilder(NoticeTypeHelloWorld).Expiration(time.Now().Add(-time.Hour)))},
+		{name: "missing notice claim", notice: signTestNotice(t, key, testNoticeBuilder(NoticeTypeHelloWorld).Claim(noticeClaim, nil))},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			rc := New(Config{}, mockKeySets{keySet: set}, mockStoreSvc{})
+			handled := false
+			rc.HandleFunc(NoticeTypeHelloWorld, func(ctx context.Context, notice Notice) error {
+				handled = true
+				return nil
+			})
+
+			w := postNotices(rc, tt.notice)
+			if w.Code != http.StatusUnauthorized || handled {
+				t.Fatalf("expected status 401 without handling got %d", w.Code)
+			}
+		})
+	}
+}
+
+func TestReceiverBatchWithInvalidNotice(t *testing.T) {
+	t.Parallel()
+	key, set := newTestKeys(t)
+	otherKey, _ := newTestKeys(t)
+	rc := New(Config{}, mockKeySets{keySet: set}, mockStoreSvc{})
+	handled := 0
+	rc.HandleFunc(NoticeTypeHelloWorld, func(ctx context.Context, notice Notice) error {
+		handled++
+		return nil
+	})
+
+	w := postNotices(rc,
+		signTestNotice(t, otherKey, testNoticeBuilder(NoticeTypeHelloWorld)),
+		signTestNotice(t, key, testNoticeBuilder(NoticeTypeHelloWorld)),
+	)
+	if w.Code != http.StatusOK || handled != 1 {
+		t.Fatalf("expected status 200 handling the valid notice got %d with %d handled", w.Code, handled)
+	}
+}
+
+func TestReceiverVerificationUnavailable(t *testing.T) {
+	t.Parallel()
+	key, _ := newTestKeys(t)
+	rc := New(Config{}, mockKeySets{err: fmt.Errorf("platform jwks unreachable")}, mockStoreSvc{})
+	notice := signTestNotice(t, key, testNoticeBuilder(NoticeTypeHelloWorld))
+
+	_, err := rc.VerifyNotice(context.Background(), notice)
+	if !errors.Is(err, ErrVerificationUnavailable) {
+		t.Fatalf("expected error: %v", err)
+	}
+	w := postNotices(rc, notice)
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("expected status 500 got %d", w.Code)
+	}
+}
+
+func TestReceiverHandlerFailure(t *testing.T) {
+	t.Parallel()
+	key, set := newTestKeys(t)
+	rc := New(Config{}, mockKeySets{keySet: set}, mockStoreSvc{})
+	rc.HandleFunc(NoticeTypeHelloWorld, func(ctx context.Context, notice Notice) error {
+		return fmt.Errorf("database unavailable")
+	})
+
+	w := postNotices(rc, signTestNotice(t, key, testNoticeBuilder(NoticeTypeHelloWorld)))
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("expected status 500 got %d", w.Code)
+	}
+}
+
+func TestReceiverMethodNotAllowed(t *testing.T) {
+	t.Parallel()
+	rc := New(Config{}, mockKeySets{}, mockStoreSvc{})
+
+	w := httptest.NewRecorder()
+	rc.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/lti/notices", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected status 405 got %d", w.Code)
+	}
+}
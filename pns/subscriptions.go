@@ -0,0 +1,151 @@
+package pns
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/stevenweathers/peregrine-lti/internal/httpx"
+	"github.com/stevenweathers/peregrine-lti/peregrine"
+)
+
+const (
+	// ScopeNoticeHandlers is the access token scope of the platforms notice handlers endpoint
+	ScopeNoticeHandlers = "https://purl.imsglobal.org/spec/lti/scope/noticehandlers"
+	// DefaultTimeout is the default timeout of a notice handlers request
+	DefaultTimeout = httpx.DefaultTimeout
+	// DefaultMaxResponseSize is the default maximum size in bytes of a notice handlers response
+	DefaultMaxResponseSize = httpx.DefaultMaxResponseSize
+)
+
+// NoticeHandlerSubscription is the tools notice handler url subscribed to a notice type
+type NoticeHandlerSubscription struct {
+	NoticeType string `json:"notice_type"`
+	Handler    string `json:"handler"`
+}
+
+// NoticeHandlers are the tools notice handler subscriptions of a deployment
+type NoticeHandlers struct {
+	ClientID       string                      `json:"client_id"`
+	DeploymentID   string                      `json:"deployment_id"`
+	NoticeHandlers []NoticeHandlerSubscription `json:"notice_handlers"`
+}
+
+// SubscriptionConfig holds all the configuration's for SubscriptionClient
+type SubscriptionConfig struct {
+	// HTTPClient (OPTIONAL) is the client used to call the platform, defaults to a client with DefaultTimeout
+	HTTPClient *http.Client
+	// MaxResponseSize (OPTIONAL) is the maximum size in bytes of a notice handlers response,
+	// defaults to DefaultMaxResponseSize
+	MaxResponseSize int64
+}
+
+// SubscriptionClient manages the tools notice handler subscriptions through the platforms notice handlers endpoint,
+// the PlatformNotificationServiceURL of the peregrine.PlatformNotificationServiceClaim of a launch
+type SubscriptionClient struct {
+	config SubscriptionConfig
+	tokens peregrine.AccessTokenSource
+}
+
+// NewSubscriptionClient returns a new SubscriptionClient authorizing its requests with tokens
+func NewSubscriptionClient(config SubscriptionConfig, tokens peregrine.AccessTokenSource) *SubscriptionClient {
+	if config.HTTPClient == nil {
+		config.HTTPClient = httpx.NewClient()
+	}
+	if config.MaxResponseSize == 0 {
+		config.MaxResponseSize = DefaultMaxResponseSize
+	}
+
+	return &SubscriptionClient{
+		config: config,
+		tokens: tokens,
+	}
+}
+
+// List returns the tools notice handler subscriptions of the deployment the serviceURL belongs to
+func (c *SubscriptionClient) List(
+	ctx context.Context, registration peregrine.Registration, serviceURL string,
+) (NoticeHandlers, error) {
+	var handlers NoticeHandlers
+	err := c.do(ctx, registration, http.MethodGet, serviceURL, nil, &handlers)
+
+	return handlers, err
+}
+
+// Subscribe subscribes the tools handlerURL (the url the Receiver is served at) to the notice type
+func (c *SubscriptionClient) Subscribe(
+	ctx context.Context, registration peregrine.Registration, serviceURL string, noticeType string, handlerURL string,
+) (NoticeHandlerSubscription, error) {
+	var subscription NoticeHandlerSubscription
+	err := c.do(ctx, registration, http.MethodPut, serviceURL, NoticeHandlerSubscription{
+		NoticeType: noticeType,
+		Handler:    handlerURL,
+	}, &subscription)
+
+	return subscription, err
+}
+
+// Unsubscribe removes the tools notice handler subscription to the notice type
+func (c *SubscriptionClient) Unsubscribe(
+	ctx context.Context, registration peregrine.Registration, serviceURL string, noticeType string,
+) error {
+	return c.do(ctx, registration, http.MethodPut, serviceURL, NoticeHandlerSubscription{
+		NoticeType: noticeType,
+	}, nil)
+}
+
+// do sends the request to the notice handlers endpoint decoding the json response into result when not nil
+func (c *SubscriptionClient) do(
+	ctx context.Context, registration peregrine.Registration, method string, serviceURL string,
+	body interface{}, result interface{},
+) error {
+	token, err := c.tokens.AccessToken(ctx, registration, []string{ScopeNoticeHandlers})
+	if err != nil {
+		return fmt.Errorf("failed to get notice handlers access token: %v", err)
+	}
+
+	var reqBody io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode notice handlers request: %v", err)
+		}
+		reqBody = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, serviceURL, reqBody)
+	if err != nil {
+		return fmt.Errorf("failed to create notice handlers request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := c.config.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call notice handlers endpoint: %v", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("notice handlers endpoint responded with status %d", res.StatusCode)
+	}
+	if result == nil || res.StatusCode == http.StatusNoContent {
+		return nil
+	}
+
+	resBody, err := httpx.ReadLimited(res.Body, c.config.MaxResponseSize)
+	if err != nil {
+		return fmt.Errorf("failed to read notice handlers response: %w", err)
+	}
+	if err = json.Unmarshal(resBody, result); err != nil {
+		return fmt.Errorf("failed to decode notice handlers response: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,90 @@
+package pns
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stevenweathers/peregrine-lti/peregrine"
+)
+
+type mockTokenSource struct{}
+
+func (m mockTokenSource) AccessToken(ctx context.Context, registration peregrine.Registration, scopes []string) (
+	string, error,
+) {
+	return "test_token:" + scopes[0], nil
+}
+
+func TestSubscriptionClient(t *testing.T) {
+	t.Parallel()
+	handlers := NoticeHandlers{ClientID: testClientID, DeploymentID: testDeploymentID}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test_token:"+ScopeNoticeHandlers {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			_ = json.NewEncoder(w).Encode(handlers)
+		case http.MethodPut:
+			var sub NoticeHandlerSubscription
+			if err := json.NewDecoder(r.Body).Decode(&sub); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			if sub.Handler == "" {
+				handlers.NoticeHandlers = nil
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			handlers.NoticeHandlers = append(handlers.NoticeHandlers, sub)
+			_ = json.NewEncoder(w).Encode(sub)
+		}
+	}))
+	defer srv.Close()
+
+	client := NewSubscriptionClient(SubscriptionConfig{}, mockTokenSource{})
+	ctx := context.Background()
+	registration := peregrine.Registration{ClientID: testClientID}
+
+	sub, err := client.Subscribe(ctx, registration, srv.URL, NoticeTypeContextCopy, "https://tool.example/lti/notices")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sub.NoticeType != NoticeTypeContextCopy || sub.Handler != "https://tool.example/lti/notices" {
+		t.Fatalf("unexpected subscription %+v", sub)
+	}
+
+	list, err := client.List(ctx, registration, srv.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if list.DeploymentID != testDeploymentID || len(list.NoticeHandlers) != 1 {
+		t.Fatalf("unexpected notice handlers %+v", list)
+	}
+
+	if err = client.Unsubscribe(ctx, registration, srv.URL, NoticeTypeContextCopy); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(handlers.NoticeHandlers) != 0 {
+		t.Fatalf("expected subscription to be removed got %+v", handlers.NoticeHandlers)
+	}
+}
+
+func TestSubscriptionClientMaxResponseSize(t *testing.T) {
+	t.Parallel()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(NoticeHandlers{ClientID: testClientID, DeploymentID: testDeploymentID})
+	}))
+	defer srv.Close()
+
+	client := NewSubscriptionClient(SubscriptionConfig{MaxResponseSize: 16}, mockTokenSource{})
+	_, err := client.List(context.Background(), peregrine.Registration{ClientID: testClientID}, srv.URL)
+	if err == nil || !strings.Contains(err.Error(), "exceeds 16 bytes") {
+		t.Fatalf("expected error: %v", err)
+	}
+}
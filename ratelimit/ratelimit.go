@@ -0,0 +1,164 @@
+// Package ratelimit provides an in-memory token bucket launch.RateLimiter
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/stevenweathers/peregrine-lti/launch"
+)
+
+const (
+	// DefaultIdleTTL is the default time after which an unused bucket is evicted
+	DefaultIdleTTL = time.Minute * 10
+	// DefaultMaxBuckets is the default maximum number of buckets held in memory
+	DefaultMaxBuckets = 100000
+)
+
+// Limit is the token bucket of a single key
+type Limit struct {
+	// Rate (REQUIRED) is the number of requests per second the bucket refills by, a zero Rate disables the Limit
+	Rate float64
+	// Burst (OPTIONAL) is the maximum number of requests allowed at once, defaults to Rate rounded up
+	Burst int
+}
+
+// Config holds all the configuration's for Limiter, each enabled Limit is applied
+// per unique value so a login request is allowed only when every bucket it maps to has a token
+type Config struct {
+	// PerClientID (OPTIONAL) limits login requests per client_id
+	PerClientID Limit
+	// PerIssuer (OPTIONAL) limits login requests per issuer
+	PerIssuer Limit
+	// PerRemoteIP (OPTIONAL) limits login requests per remote IP, see launch.ContextWithRemoteIP
+	PerRemoteIP Limit
+	// IdleTTL (OPTIONAL) is the time after which an unused bucket is evicted, defaults to DefaultIdleTTL
+	IdleTTL time.Duration
+	// MaxBuckets (OPTIONAL) is the maximum number of buckets held in memory, defaults to DefaultMaxBuckets,
+	// once reached login requests needing a new bucket are limited until idle buckets are evicted
+	MaxBuckets int
+}
+
+// Limiter is an in-memory token bucket launch.RateLimiter, buckets are not shared across processes
+type Limiter struct {
+	config Config
+	now    func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[bucketKey]*bucket
+	lastSweep time.Time
+}
+
+type bucketKey struct {
+	dimension string
+	value     string
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// New returns a new Limiter
+func New(config Config) *Limiter {
+	if config.IdleTTL <= 0 {
+		config.IdleTTL = DefaultIdleTTL
+	}
+	if config.MaxBuckets <= 0 {
+		config.MaxBuckets = DefaultMaxBuckets
+	}
+
+	return &Limiter{
+		config:    config,
+		now:       time.Now,
+		buckets:   make(map[bucketKey]*bucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// Allow takes a token from each of the keys buckets reporting false without taking any when a bucket is empty,
+// empty key values (e.g. an omitted client_id) are not limited. The remote IP is checked first as the client_id
+// and issuer are chosen by the requester, buckets are only stored for allowed requests
+func (l *Limiter) Allow(_ context.Context, key launch.RateLimitKey) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now, false)
+
+	var buckets []*bucket
+	newBuckets := make(map[bucketKey]*bucket)
+	for _, d := range []struct {
+		name  string
+		value string
+		limit Limit
+	}{
+		{"remote_ip", key.RemoteIP, l.config.PerRemoteIP},
+		{"issuer", key.Issuer, l.config.PerIssuer},
+		{"client_id", key.ClientID, l.config.PerClientID},
+	} {
+		if d.value == "" || d.limit.Rate <= 0 {
+			continue
+		}
+
+		k := bucketKey{dimension: d.name, value: d.value}
+		b, ok := l.bucket(k, d.limit, now)
+		if b.tokens < 1 {
+			return false
+		}
+		if !ok {
+			newBuckets[k] = b
+		}
+		buckets = append(buckets, b)
+	}
+
+	if len(l.buckets)+len(newBuckets) > l.config.MaxBuckets {
+		l.sweep(now, true)
+		if len(l.buckets)+len(newBuckets) > l.config.MaxBuckets {
+			return false
+		}
+	}
+	for k, b := range newBuckets {
+		l.buckets[k] = b
+	}
+	for _, b := range buckets {
+		b.tokens--
+	}
+
+	return true
+}
+
+// bucket returns the refilled bucket for key reporting whether it exists,
+// a full bucket that is not yet stored is returned when not existing
+func (l *Limiter) bucket(key bucketKey, limit Limit, now time.Time) (*bucket, bool) {
+	burst := float64(limit.Burst)
+	if limit.Burst <= 0 {
+		burst = math.Ceil(limit.Rate)
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		return &bucket{tokens: burst, last: now}, false
+	}
+
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*limit.Rate)
+	b.last = now
+
+	return b, true
+}
+
+// sweep evicts the buckets unused for the IdleTTL, at most once per IdleTTL unless forced
+func (l *Limiter) sweep(now time.Time, force bool) {
+	if !force && now.Sub(l.lastSweep) < l.config.IdleTTL {
+		return
+	}
+	l.lastSweep = now
+
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= l.config.IdleTTL {
+			delete(l.buckets, key)
+		}
+	}
+}
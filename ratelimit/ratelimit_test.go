@@ -0,0 +1,122 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stevenweathers/peregrine-lti/launch"
+)
+
+func testLimiter(config Config) (*Limiter, *time.Time) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := New(config)
+	l.now = func() time.Time { return now }
+	l.lastSweep = now
+	return l, &now
+}
+
+func TestAllowBurstThenRefill(t *testing.T) {
+	t.Parallel()
+	l, now := testLimiter(Config{PerClientID: Limit{Rate: 1, Burst: 2}})
+	key := launch.RateLimitKey{ClientID: "150420000000000007"}
+
+	for i := 0; i < 2; i++ {
+		if !l.Allow(context.Background(), key) {
+			t.Fatalf("expected request %d within burst to be allowed", i)
+		}
+	}
+	if l.Allow(context.Background(), key) {
+		t.Fatalf("expected request exceeding burst to be limited")
+	}
+
+	*now = now.Add(time.Second)
+	if !l.Allow(context.Background(), key) {
+		t.Fatalf("expected request to be allowed after refill")
+	}
+}
+
+func TestAllowAppliesEveryLimit(t *testing.T) {
+	t.Parallel()
+	l, _ := testLimiter(Config{
+		PerClientID: Limit{Rate: 10},
+		PerRemoteIP: Limit{Rate: 1},
+	})
+
+	if !l.Allow(context.Background(), launch.RateLimitKey{ClientID: "a", RemoteIP: "203.0.113.7"}) {
+		t.Fatalf("expected first request to be allowed")
+	}
+	// a different client_id from the same remote IP is limited by the remote IP bucket
+	if l.Allow(context.Background(), launch.RateLimitKey{ClientID: "b", RemoteIP: "203.0.113.7"}) {
+		t.Fatalf("expected request from limited remote ip to be limited")
+	}
+	// the rejected request does not take a token from client_id b
+	if !l.Allow(context.Background(), launch.RateLimitKey{ClientID: "b", RemoteIP: "198.51.100.1"}) {
+		t.Fatalf("expected request from another remote ip to be allowed")
+	}
+	// requests without a remote IP are only limited by client_id
+	if !l.Allow(context.Background(), launch.RateLimitKey{ClientID: "a"}) {
+		t.Fatalf("expected request without remote ip to be allowed")
+	}
+}
+
+func TestSweepEvictsIdleBuckets(t *testing.T) {
+	t.Parallel()
+	l, now := testLimiter(Config{PerIssuer: Limit{Rate: 1}, IdleTTL: time.Minute})
+
+	l.Allow(context.Background(), launch.RateLimitKey{Issuer: "https://canvas.instructure.com"})
+	*now = now.Add(time.Minute)
+	l.Allow(context.Background(), launch.RateLimitKey{Issuer: "https://moodle.test"})
+
+	if len(l.buckets) != 1 {
+		t.Fatalf("expected idle bucket to be evicted got %d buckets", len(l.buckets))
+	}
+}
+
+func TestAllowRejectedRequestsDoNotStoreBuckets(t *testing.T) {
+	t.Parallel()
+	l, _ := testLimiter(Config{
+		PerClientID: Limit{Rate: 10},
+		PerIssuer:   Limit{Rate: 10},
+		PerRemoteIP: Limit{Rate: 1},
+	})
+
+	if !l.Allow(context.Background(), launch.RateLimitKey{ClientID: "a", Issuer: "a", RemoteIP: "203.0.113.7"}) {
+		t.Fatalf("expected first request to be allowed")
+	}
+	for _, id := range []string{"b", "c", "d"} {
+		if l.Allow(context.Background(), launch.RateLimitKey{ClientID: id, Issuer: id, RemoteIP: "203.0.113.7"}) {
+			t.Fatalf("expected request from limited remote ip to be limited")
+		}
+	}
+	if len(l.buckets) != 3 {
+		t.Fatalf("expected only the allowed requests buckets got %d buckets", len(l.buckets))
+	}
+}
+
+func TestAllowMaxBuckets(t *testing.T) {
+	t.Parallel()
+	l, now := testLimiter(Config{PerClientID: Limit{Rate: 1}, IdleTTL: time.Minute, MaxBuckets: 2})
+
+	for _, id := range []string{"a", "b"} {
+		if !l.Allow(context.Background(), launch.RateLimitKey{ClientID: id}) {
+			t.Fatalf("expected request for client_id %s to be allowed", id)
+		}
+	}
+	if l.Allow(context.Background(), launch.RateLimitKey{ClientID: "c"}) {
+		t.Fatalf("expected request needing a new bucket past MaxBuckets to be limited")
+	}
+	if len(l.buckets) != 2 {
+		t.Fatalf("expected %d buckets got %d", 2, len(l.buckets))
+	}
+
+	*now = now.Add(time.Second * 30)
+	if !l.Allow(context.Background(), launch.RateLimitKey{ClientID: "a"}) {
+		t.Fatalf("expected request with an existing bucket to be allowed")
+	}
+	// client_id b is now idle and evicted to make room
+	*now = now.Add(time.Second * 30)
+	if !l.Allow(context.Background(), launch.RateLimitKey{ClientID: "c"}) {
+		t.Fatalf("expected request to be allowed once an idle bucket is evicted")
+	}
+}
@@ -0,0 +1,164 @@
+// Package retention purges expired peregrine.Launch records, abandoned logins (where the user never
+// completed the launch) and completed launches older than the retention period
+package retention
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/stevenweathers/peregrine-lti/instrument"
+	"github.com/stevenweathers/peregrine-lti/launch"
+	"github.com/stevenweathers/peregrine-lti/peregrine"
+)
+
+const (
+	// DefaultInterval is the default time between purges
+	DefaultInterval = time.Minute * 15
+	// DefaultBatchSize is the default maximum number of launches deleted per repository call
+	DefaultBatchSize = 500
+	// DefaultUsedRetention is the default time a completed launch is retained for
+	DefaultUsedRetention = time.Hour * 24 * 30
+)
+
+// Config holds all the configuration's for Janitor
+type Config struct {
+	// Interval (OPTIONAL) is the time between purges, defaults to DefaultInterval
+	Interval time.Duration
+	// BatchSize (OPTIONAL) is the maximum number of launches deleted per repository call, defaults to DefaultBatchSize
+	BatchSize int
+	// UnusedTTL (OPTIONAL) is the time after which a launch that was never used is abandoned,
+	// defaults to launch.DefaultStateTTL as the launch can no longer be completed,
+	// a smaller value is raised to launch.DefaultStateTTL
+	UnusedTTL time.Duration
+	// UsedRetention (OPTIONAL) is the time a completed launch is retained for (e.g. 7 days),
+	// defaults to DefaultUsedRetention
+	UsedRetention time.Duration
+	// Meter (OPTIONAL) records the number of launches purged, defaults to instrument.Noop
+	Meter instrument.Meter
+	// Logger (OPTIONAL) logs purge results and failures, defaults to discarding logs
+	Logger *slog.Logger
+}
+
+// Result is the number of launches deleted by a purge
+type Result struct {
+	// Unused is the number of abandoned launches deleted
+	Unused int
+	// Used is the number of completed launches deleted
+	Used int
+}
+
+// Janitor periodically purges expired launches
+type Janitor struct {
+	config Config
+	repo   peregrine.LaunchRetentionRepo
+
+	unusedTotal atomic.Int64
+	usedTotal   atomic.Int64
+}
+
+// New returns a new Janitor
+func New(config Config, repo peregrine.LaunchRetentionRepo) *Janitor {
+	if config.Interval <= 0 {
+		config.Interval = DefaultInterval
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = DefaultBatchSize
+	}
+	// a shorter UnusedTTL would purge launches whose state can still be completed
+	if config.UnusedTTL < launch.DefaultStateTTL {
+		config.UnusedTTL = launch.DefaultStateTTL
+	}
+	if config.UsedRetention <= 0 {
+		config.UsedRetention = DefaultUsedRetention
+	}
+	if config.Meter == nil {
+		config.Meter = instrument.Noop{}
+	}
+	if config.Logger == nil {
+		config.Logger = launch.DiscardLogger()
+	}
+
+	return &Janitor{
+		config: config,
+		repo:   repo,
+	}
+}
+
+// Run purges expired launches immediately and then every Interval until ctx is done, returning ctx.Err(),
+// failed purges are logged and retried at the next Interval
+func (j *Janitor) Run(ctx context.Context) error {
+	ticker := time.NewTicker(j.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := j.Purge(ctx); err != nil && ctx.Err() == nil {
+			j.config.Logger.ErrorContext(ctx, "failed to purge expired launches", slog.String("error", err.Error()))
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Purge deletes expired launches in batches of BatchSize until none remain, returning the number deleted
+func (j *Janitor) Purge(ctx context.Context) (Result, error) {
+	var result Result
+	start := time.Now()
+	unusedBefore := start.Add(-j.config.UnusedTTL)
+	usedBefore := start.Add(-j.config.UsedRetention)
+
+	defer func() {
+		j.config.Meter.Record(ctx, instrument.MetricPurgeDuration, time.Since(start).Seconds())
+		j.record(ctx, result)
+	}()
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		unused, used, err := j.repo.DeleteExpiredLaunches(ctx, unusedBefore, usedBefore, j.config.BatchSize)
+		if err != nil {
+			return result, fmt.Errorf("failed to delete expired launches: %v", err)
+		}
+		result.Unused += unused
+		result.Used += used
+
+		if unused+used < j.config.BatchSize {
+			return result, nil
+		}
+	}
+}
+
+// Totals returns the number of launches deleted since the Janitor was created
+func (j *Janitor) Totals() Result {
+	return Result{
+		Unused: int(j.unusedTotal.Load()),
+		Used:   int(j.usedTotal.Load()),
+	}
+}
+
+func (j *Janitor) record(ctx context.Context, result Result) {
+	j.unusedTotal.Add(int64(result.Unused))
+	j.usedTotal.Add(int64(result.Used))
+
+	j.config.Meter.Count(ctx, instrument.MetricLaunchesPurged, int64(result.Unused),
+		instrument.String(instrument.AttrPurgeReason, instrument.PurgeUnused),
+	)
+	j.config.Meter.Count(ctx, instrument.MetricLaunchesPurged, int64(result.Used),
+		instrument.String(instrument.AttrPurgeReason, instrument.PurgeUsed),
+	)
+
+	if result.Unused+result.Used > 0 {
+		j.config.Logger.InfoContext(ctx, "purged expired launches",
+			slog.Int("unused", result.Unused),
+			slog.Int("used", result.Used),
+		)
+	}
+}
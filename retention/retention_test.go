@@ -0,0 +1,137 @@
+package retention
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stevenweathers/peregrine-lti/instrument"
+	"github.com/stevenweathers/peregrine-lti/launch"
+)
+
+// mockRetentionRepo deletes from a fixed number of expired unused and used launches
+type mockRetentionRepo struct {
+	mu           sync.Mutex
+	unused       int
+	used         int
+	calls        int
+	unusedBefore time.Time
+	usedBefore   time.Time
+	fail         bool
+}
+
+func (r *mockRetentionRepo) DeleteExpiredLaunches(
+	ctx context.Context, unusedBefore time.Time, usedBefore time.Time, limit int,
+) (int, int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls++
+	r.unusedBefore, r.usedBefore = unusedBefore, usedBefore
+	if r.fail {
+		return 0, 0, errors.New("delete expired launches forced failure")
+	}
+
+	unused := min(r.unused, limit)
+	used := min(r.used, limit-unused)
+	r.unused -= unused
+	r.used -= used
+
+	return unused, used, nil
+}
+
+// countingMeter sums counters by purge reason
+type countingMeter struct {
+	instrument.Noop
+	mu     sync.Mutex
+	counts map[string]int64
+}
+
+func (m *countingMeter) Count(_ context.Context, name string, value int64, attrs ...instrument.Attribute) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if name != instrument.MetricLaunchesPurged {
+		return
+	}
+	for _, a := range attrs {
+		if a.Key == instrument.AttrPurgeReason {
+			m.counts[a.Value] += value
+		}
+	}
+}
+
+func TestPurgeInBatches(t *testing.T) {
+	t.Parallel()
+	repo := &mockRetentionRepo{unused: 7, used: 4}
+	meter := &countingMeter{counts: make(map[string]int64)}
+	j := New(Config{BatchSize: 3, UsedRetention: time.Hour * 24 * 7, Meter: meter}, repo)
+
+	start := time.Now()
+	result, err := j.Purge(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if result.Unused != 7 || result.Used != 4 {
+		t.Fatalf("expected 7 unused and 4 used launches purged got %+v", result)
+	}
+	// 11 launches in batches of 3 requires 4 calls, the last returning a partial batch
+	if repo.calls != 4 {
+		t.Fatalf("expected 4 repository calls got %d", repo.calls)
+	}
+	unusedDrift := repo.unusedBefore.Sub(start.Add(-launch.DefaultStateTTL))
+	usedDrift := repo.usedBefore.Sub(start.Add(-time.Hour * 24 * 7))
+	if unusedDrift < 0 || unusedDrift > time.Second || usedDrift < 0 || usedDrift > time.Second {
+		t.Fatalf("expected cutoffs to be the state ttl and used retention got %s %s", repo.unusedBefore, repo.usedBefore)
+	}
+	if meter.counts[instrument.PurgeUnused] != 7 || meter.counts[instrument.PurgeUsed] != 4 {
+		t.Fatalf("expected purge metrics to be recorded got %v", meter.counts)
+	}
+	if j.Totals() != result {
+		t.Fatalf("expected totals %+v got %+v", result, j.Totals())
+	}
+}
+
+func TestPurgeFailure(t *testing.T) {
+	t.Parallel()
+	j := New(Config{}, &mockRetentionRepo{fail: true})
+
+	_, err := j.Purge(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "forced failure") {
+		t.Fatalf("expected error: %v", err)
+	}
+}
+
+func TestRunPurgesUntilCanceled(t *testing.T) {
+	t.Parallel()
+	repo := &mockRetentionRepo{unused: 2}
+	j := New(Config{Interval: time.Millisecond}, repo)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*20)
+	defer cancel()
+
+	if err := j.Run(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected error: %v", err)
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	if repo.calls < 2 || j.Totals().Unused != 2 {
+		t.Fatalf("expected repeated purges got %d calls and totals %+v", repo.calls, j.Totals())
+	}
+}
+
+func TestUnusedTTLClampedToStateTTL(t *testing.T) {
+	t.Parallel()
+	repo := &mockRetentionRepo{}
+	j := New(Config{UnusedTTL: time.Second}, repo)
+
+	if _, err := j.Purge(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if repo.unusedBefore.After(time.Now().Add(-launch.DefaultStateTTL)) {
+		t.Fatalf("expected unused launches within the state ttl to be kept got unused before %s", repo.unusedBefore)
+	}
+}
@@ -0,0 +1,86 @@
+package session
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/stevenweathers/peregrine-lti/launch"
+)
+
+// Start issues a Session for the successful launch and sets the session cookie,
+// the returned token can also be handed to the tools frontend for use as a bearer token
+// when third party cookies are blocked in the platforms iframe
+func (m *Manager) Start(w http.ResponseWriter, resp launch.HandleOidcCallbackResponse) (string, Session, error) {
+	token, s, err := m.Issue(FromLaunch(resp))
+	if err != nil {
+		return "", s, err
+	}
+	m.SetCookie(w, token, s.ExpiresAt)
+
+	return token, s, nil
+}
+
+// SetCookie sets the session cookie, SameSite=None is required for the cookie to be sent
+// when the tool is launched within the platforms iframe
+func (m *Manager) SetCookie(w http.ResponseWriter, token string, expires time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     m.config.CookieName,
+		Value:    token,
+		Path:     m.config.CookiePath,
+		Domain:   m.config.CookieDomain,
+		Expires:  expires,
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteNoneMode,
+	})
+}
+
+// Middleware authenticates requests with a session token from the Authorization bearer header or session cookie,
+// refreshing the token when within the RefreshWindow (until the sessions MaxAge is reached)
+// and exposing the Session through the request context,
+// see FromContext. Unauthenticated requests are rejected with 401 Unauthorized.
+func (m *Manager) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, fromCookie := m.requestToken(r)
+		if token == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		s, err := m.Verify(token)
+		if err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		if time.Until(s.ExpiresAt) < m.config.RefreshWindow && s.ExpiresAt.Before(m.maxExpiresAt(s)) {
+			refreshed, rs, err := m.Issue(s)
+			if err == nil {
+				s = rs
+				if fromCookie {
+					m.SetCookie(w, refreshed, s.ExpiresAt)
+				} else {
+					w.Header().Set(RefreshHeader, refreshed)
+				}
+			}
+		}
+
+		next.ServeHTTP(w, r.WithContext(ContextWithSession(r.Context(), s)))
+	})
+}
+
+// requestToken returns the session token from the Authorization bearer header falling back to the session cookie
+func (m *Manager) requestToken(r *http.Request) (string, bool) {
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		if token, ok := strings.CutPrefix(auth, "Bearer "); ok {
+			return strings.TrimSpace(token), false
+		}
+	}
+
+	if c, err := r.Cookie(m.config.CookieName); err == nil {
+		return c.Value, true
+	}
+
+	return "", false
+}
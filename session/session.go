@@ -0,0 +1,267 @@
+package session
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/stevenweathers/peregrine-lti/launch"
+)
+
+const (
+	// DefaultTTL is the default lifetime of a session token
+	DefaultTTL = time.Minute * 30
+	// DefaultMaxAge is the default maximum lifetime of a session across refreshes
+	DefaultMaxAge = time.Hour * 8
+	// DefaultCookieName is the default name of the session cookie
+	DefaultCookieName = "peregrine_session"
+	// RefreshHeader is the response header the Middleware sets with a refreshed bearer session token
+	RefreshHeader = "X-Peregrine-Session"
+
+	launchIDClaim       = "lti_launch_id"
+	registrationIDClaim = "lti_registration_id"
+	platformIssuerClaim = "lti_platform_iss"
+	clientIDClaim       = "lti_client_id"
+	deploymentIDClaim   = "lti_deployment_id"
+	contextIDClaim      = "lti_context_id"
+	contextTitleClaim   = "lti_context_title"
+	rolesClaim          = "lti_roles"
+	startedAtClaim      = "lti_session_started_at"
+)
+
+type sessionContextKey struct{}
+
+// Config holds all the configuration's for Manager
+type Config struct {
+	// Issuer (REQUIRED) is the issuer used to sign the session JWT
+	Issuer string
+	// KeySecret (REQUIRED) is the secret used to sign the session JWT, it should differ from the launch state secret
+	KeySecret string
+	// TTL (OPTIONAL) is the lifetime of a session token, defaults to DefaultTTL
+	TTL time.Duration
+	// RefreshWindow (OPTIONAL) is the remaining lifetime at which the Middleware refreshes the session token,
+	// defaults to half the TTL
+	RefreshWindow time.Duration
+	// MaxAge (OPTIONAL) is the maximum lifetime of a session since the launch that started it,
+	// refreshed tokens never expire later so the user must launch again, defaults to DefaultMaxAge
+	MaxAge time.Duration
+	// CookieName (OPTIONAL) is the name of the session cookie, defaults to DefaultCookieName
+	CookieName string
+	// CookiePath (OPTIONAL) is the path of the session cookie, defaults to /
+	CookiePath string
+	// CookieDomain (OPTIONAL) is the domain of the session cookie
+	CookieDomain string
+}
+
+// Session is the tool session established by a successful LTI launch
+type Session struct {
+	// LaunchID is the peregrine.Launch ID that established the Session
+	LaunchID uuid.UUID
+	// RegistrationID is the peregrine.Registration ID of the launch
+	RegistrationID uuid.UUID
+	// PlatformIssuer is the peregrine.Platform Issuer of the launch
+	PlatformIssuer string
+	// ClientID is the peregrine.Registration ClientID of the launch
+	ClientID string
+	// SUB is the launching users platform id, empty for anonymous launches
+	SUB string
+	// DeploymentID is the platform deployment_id of the launch
+	DeploymentID string
+	// ContextID is the platform context id (e.g. course) of the launch
+	ContextID string
+	// ContextTitle is the platform context title of the launch
+	ContextTitle string
+	// Roles are the users roles within the context of the launch
+	Roles []string
+	// StartedAt is when the first session token of the launch was issued, carried across refreshes
+	StartedAt time.Time
+	// IssuedAt is when the session token was issued
+	IssuedAt time.Time
+	// ExpiresAt is when the session token expires
+	ExpiresAt time.Time
+}
+
+// Manager mints and verifies signed short-lived tool session tokens
+type Manager struct {
+	config Config
+}
+
+// New returns a new Manager for tool sessions
+func New(config Config) *Manager {
+	if config.TTL == 0 {
+		config.TTL = DefaultTTL
+	}
+	if config.RefreshWindow == 0 {
+		config.RefreshWindow = config.TTL / 2
+	}
+	if config.MaxAge == 0 {
+		config.MaxAge = DefaultMaxAge
+	}
+	if config.CookieName == "" {
+		config.CookieName = DefaultCookieName
+	}
+	if config.CookiePath == "" {
+		config.CookiePath = "/"
+	}
+
+	return &Manager{
+		config: config,
+	}
+}
+
+// FromLaunch builds the Session for a successful launch.HandleOidcCallbackResponse
+func FromLaunch(resp launch.HandleOidcCallbackResponse) Session {
+	s := Session{
+		LaunchID:     resp.Launch.ID,
+		SUB:          resp.Claims.SUB,
+		DeploymentID: resp.Claims.DeploymentID,
+		ContextID:    resp.Claims.Context.ID,
+		ContextTitle: resp.Claims.Context.Title,
+		Roles:        resp.Claims.Roles,
+	}
+	if resp.Launch.Registration != nil {
+		s.RegistrationID = resp.Launch.Registration.ID
+		s.ClientID = resp.Launch.Registration.ClientID
+		if resp.Launch.Registration.Platform != nil {
+			s.PlatformIssuer = resp.Launch.Registration.Platform.Issuer
+		}
+	}
+
+	return s
+}
+
+// ContextWithSession returns a copy of ctx carrying the Session
+func ContextWithSession(ctx context.Context, s Session) context.Context {
+	return context.WithValue(ctx, sessionContextKey{}, s)
+}
+
+// FromContext returns the Session set by the Middleware
+func FromContext(ctx context.Context) (Session, bool) {
+	s, ok := ctx.Value(sessionContextKey{}).(Session)
+	return s, ok
+}
+
+// Issue mints a signed session token for the Session returning the token and the Session with its lifetime set,
+// the token expires after the TTL but no later than the MaxAge since the Session StartedAt
+func (m *Manager) Issue(s Session) (string, Session, error) {
+	// jwt times are in seconds
+	now := time.Now().Truncate(time.Second)
+	if s.StartedAt.IsZero() {
+		s.StartedAt = now
+	}
+	s.StartedAt = s.StartedAt.Truncate(time.Second)
+	s.IssuedAt = now
+	s.ExpiresAt = now.Add(m.config.TTL)
+	if maxExpiresAt := m.maxExpiresAt(s); s.ExpiresAt.After(maxExpiresAt) {
+		s.ExpiresAt = maxExpiresAt
+	}
+
+	roles := s.Roles
+	if roles == nil {
+		roles = []string{}
+	}
+
+	tok, err := jwt.NewBuilder().
+		Issuer(m.config.Issuer).
+		Subject(s.SUB).
+		IssuedAt(s.IssuedAt).
+		Expiration(s.ExpiresAt).
+		Claim(launchIDClaim, s.LaunchID.String()).
+		Claim(registrationIDClaim, s.RegistrationID.String()).
+		Claim(platformIssuerClaim, s.PlatformIssuer).
+		Claim(clientIDClaim, s.ClientID).
+		Claim(deploymentIDClaim, s.DeploymentID).
+		Claim(contextIDClaim, s.ContextID).
+		Claim(contextTitleClaim, s.ContextTitle).
+		Claim(rolesClaim, roles).
+		Claim(startedAtClaim, s.StartedAt.Unix()).
+		Build()
+	if err != nil {
+		return "", s, fmt.Errorf("failed to create launch %s session jwt: %v", s.LaunchID, err)
+	}
+
+	key, err := jwk.FromRaw([]byte(m.config.KeySecret))
+	if err != nil {
+		return "", s, fmt.Errorf("failed to create session jwk from configured secret: %v", err)
+	}
+
+	signed, err := jwt.Sign(tok, jwt.WithKey(jwa.HS256, key))
+	if err != nil {
+		return "", s, fmt.Errorf("failed to sign launch %s session jwt: %v", s.LaunchID, err)
+	}
+
+	return string(signed), s, nil
+}
+
+// Verify parses and validates the session token returning its Session
+func (m *Manager) Verify(token string) (Session, error) {
+	s := Session{}
+
+	key, err := jwk.FromRaw([]byte(m.config.KeySecret))
+	if err != nil {
+		return s, fmt.Errorf("failed to create session jwk from configured secret: %v", err)
+	}
+
+	verifiedToken, err := jwt.Parse([]byte(token), jwt.WithKey(jwa.HS256, key), jwt.WithIssuer(m.config.Issuer))
+	if err != nil {
+		return s, fmt.Errorf("invalid session token: %v", err)
+	}
+
+	claims := verifiedToken.PrivateClaims()
+	s.SUB = verifiedToken.Subject()
+	s.IssuedAt = verifiedToken.IssuedAt()
+	s.ExpiresAt = verifiedToken.Expiration()
+	s.PlatformIssuer, _ = claims[platformIssuerClaim].(string)
+	s.ClientID, _ = claims[clientIDClaim].(string)
+	s.DeploymentID, _ = claims[deploymentIDClaim].(string)
+	s.ContextID, _ = claims[contextIDClaim].(string)
+	s.ContextTitle, _ = claims[contextTitleClaim].(string)
+
+	if s.LaunchID, err = uuidClaim(claims, launchIDClaim); err != nil {
+		return s, err
+	}
+	if s.RegistrationID, err = uuidClaim(claims, registrationIDClaim); err != nil {
+		return s, err
+	}
+	startedAt, ok := claims[startedAtClaim].(float64)
+	if !ok {
+		return s, fmt.Errorf("%s claim not found in session jwt", startedAtClaim)
+	}
+	s.StartedAt = time.Unix(int64(startedAt), 0)
+	if time.Now().After(m.maxExpiresAt(s)) {
+		return s, fmt.Errorf("invalid session token: session exceeded its maximum age of %s", m.config.MaxAge)
+	}
+
+	if roles, ok := claims[rolesClaim].([]interface{}); ok {
+		s.Roles = make([]string, 0, len(roles))
+		for _, r := range roles {
+			if role, ok := r.(string); ok {
+				s.Roles = append(s.Roles, role)
+			}
+		}
+	}
+
+	return s, nil
+}
+
+// maxExpiresAt is the latest time a token of the Session may expire
+func (m *Manager) maxExpiresAt(s Session) time.Time {
+	return s.StartedAt.Add(m.config.MaxAge)
+}
+
+func uuidClaim(claims map[string]interface{}, name string) (uuid.UUID, error) {
+	value, ok := claims[name].(string)
+	if !ok {
+		return uuid.Nil, fmt.Errorf("%s claim not found in session jwt", name)
+	}
+	id, err := uuid.Parse(value)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("%s claim not a uuid", name)
+	}
+
+	return id, nil
+}
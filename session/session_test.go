@@ -0,0 +1,196 @@
+package session
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stevenweathers/peregrine-lti/launch"
+	"github.com/stevenweathers/peregrine-lti/peregrine"
+)
+
+const (
+	testIssuer    = "https://stevenweathers.dev"
+	testSecret    = "godofthunder"
+	testSub       = "4cfa2adf-9389-425a-a7d1-436f987cdb11"
+	testContextID = "c1d887f0a1a2b3c4"
+)
+
+var (
+	testLaunchID       = uuid.MustParse("5daca535-415c-4bfe-8a0e-a7fba8f5d1eb")
+	testRegistrationID = uuid.MustParse("7b556115-9460-4f1e-835e-cb11a7301f7d")
+	testLaunchResponse = launch.HandleOidcCallbackResponse{
+		Claims: peregrine.LTI1p3Claims{
+			SUB:          testSub,
+			DeploymentID: "007:9ac4b5c1c2db02e7c70db53837fe8bd47a5e309c",
+			Context:      peregrine.ContextClaim{ID: testContextID, Title: "Intro to Falconry"},
+			Roles:        []string{"http://purl.imsglobal.org/vocab/lis/v2/membership#Instructor"},
+		},
+		Launch: peregrine.Launch{
+			ID: testLaunchID,
+			Registration: &peregrine.Registration{
+				ID:       testRegistrationID,
+				ClientID: "150420000000000007",
+				Platform: &peregrine.Platform{Issuer: "https://canvas.test.instructure.com"},
+			},
+		},
+	}
+)
+
+func TestIssueAndVerify(t *testing.T) {
+	t.Parallel()
+	m := New(Config{Issuer: testIssuer, KeySecret: testSecret})
+
+	token, issued, err := m.Issue(FromLaunch(testLaunchResponse))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if issued.ExpiresAt.Sub(issued.IssuedAt) != DefaultTTL {
+		t.Fatalf("expected session lifetime to equal %s", DefaultTTL)
+	}
+
+	s, err := m.Verify(token)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if s.LaunchID != testLaunchID || s.RegistrationID != testRegistrationID {
+		t.Fatalf("expected session launch and registration ids to be set got %s %s", s.LaunchID, s.RegistrationID)
+	}
+	if s.SUB != testSub || s.ContextID != testContextID || s.ContextTitle != "Intro to Falconry" {
+		t.Fatalf("expected session sub and context to be set got %+v", s)
+	}
+	if s.PlatformIssuer != "https://canvas.test.instructure.com" || s.ClientID != "150420000000000007" {
+		t.Fatalf("expected session platform issuer and client id to be set got %+v", s)
+	}
+	if len(s.Roles) != 1 || s.Roles[0] != "http://purl.imsglobal.org/vocab/lis/v2/membership#Instructor" {
+		t.Fatalf("expected session roles to be set got %v", s.Roles)
+	}
+
+	other := New(Config{Issuer: testIssuer, KeySecret: "othersecret"})
+	if _, err = other.Verify(token); err == nil || !strings.Contains(err.Error(), "invalid session token") {
+		t.Fatalf("expected error: %v", err)
+	}
+}
+
+func TestVerifyExpired(t *testing.T) {
+	t.Parallel()
+	m := New(Config{Issuer: testIssuer, KeySecret: testSecret, TTL: -time.Minute})
+
+	token, _, err := m.Issue(FromLaunch(testLaunchResponse))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err = m.Verify(token); err == nil || !strings.Contains(err.Error(), "invalid session token") {
+		t.Fatalf("expected error: %v", err)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	t.Parallel()
+	m := New(Config{Issuer: testIssuer, KeySecret: testSecret})
+
+	rec := httptest.NewRecorder()
+	_, _, err := m.Start(rec, testLaunchResponse)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != DefaultCookieName || cookies[0].SameSite != http.SameSiteNoneMode {
+		t.Fatalf("expected SameSite=None session cookie to be set got %v", cookies)
+	}
+
+	handler := m.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s, ok := FromContext(r.Context())
+		if !ok || s.LaunchID != testLaunchID {
+			t.Fatalf("expected session in request context")
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(cookies[0])
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d got %d", http.StatusNoContent, rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status %d got %d", http.StatusUnauthorized, rec.Code)
+	}
+}
+
+func TestMiddlewareRefreshesBearerToken(t *testing.T) {
+	t.Parallel()
+	// a refresh window longer than the ttl ensures every request is refreshed
+	m := New(Config{Issuer: testIssuer, KeySecret: testSecret, TTL: time.Minute, RefreshWindow: time.Hour})
+
+	token, _, err := m.Issue(FromLaunch(testLaunchResponse))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	handler := m.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d got %d", http.StatusNoContent, rec.Code)
+	}
+	refreshed := rec.Header().Get(RefreshHeader)
+	if refreshed == "" {
+		t.Fatalf("expected refreshed token in %s header", RefreshHeader)
+	}
+	if _, err = m.Verify(refreshed); err != nil {
+		t.Fatalf("expected refreshed token to be valid: %v", err)
+	}
+}
+
+func TestMiddlewareStopsRefreshingAtMaxAge(t *testing.T) {
+	t.Parallel()
+	m := New(Config{Issuer: testIssuer, KeySecret: testSecret, TTL: time.Minute, RefreshWindow: time.Hour, MaxAge: time.Hour})
+
+	// a session started just under an hour ago can only be refreshed up to its MaxAge
+	started := FromLaunch(testLaunchResponse)
+	started.StartedAt = time.Now().Add(-time.Hour + time.Second*30)
+	token, issued, err := m.Issue(started)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !issued.ExpiresAt.Equal(started.StartedAt.Truncate(time.Second).Add(time.Hour)) {
+		t.Fatalf("expected session to expire at its MaxAge got %s", issued.ExpiresAt)
+	}
+
+	handler := m.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d got %d", http.StatusNoContent, rec.Code)
+	}
+	if refreshed := rec.Header().Get(RefreshHeader); refreshed != "" {
+		t.Fatalf("expected session at its MaxAge to not be refreshed")
+	}
+
+	expired := FromLaunch(testLaunchResponse)
+	expired.StartedAt = time.Now().Add(-time.Hour * 2)
+	token, _, err = m.Issue(expired)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err = m.Verify(token); err == nil || !strings.Contains(err.Error(), "invalid session token") {
+		t.Fatalf("expected error: %v", err)
+	}
+}
@@ -0,0 +1,50 @@
+package toolconfig
+
+import (
+	"fmt"
+
+	"github.com/stevenweathers/peregrine-lti/platforms/canvas"
+)
+
+// CanvasDeveloperKey renders the Tool as a Canvas developer key configuration
+func (t Tool) CanvasDeveloperKey() canvas.DeveloperKeyConfig {
+	placements := make([]canvas.Placement, 0, len(t.Placements))
+	for _, p := range t.Placements {
+		placements = append(placements, canvas.Placement{
+			Placement:     p.Placement,
+			Text:          t.placementLabel(p),
+			Enabled:       true,
+			IconURL:       p.IconURL,
+			MessageType:   t.messageType(p),
+			TargetLinkURI: t.placementTargetLinkURI(p),
+		})
+	}
+
+	return canvas.DeveloperKeyConfig{
+		Title:             t.Title,
+		Description:       t.Description,
+		OIDCInitiationURL: t.OIDCLoginURL,
+		TargetLinkURI:     t.TargetLinkURI,
+		PublicJWKURL:      t.JWKSURL,
+		Scopes:            t.Scopes,
+		Extensions: []canvas.DeveloperKeyExtension{{
+			Domain:       t.domain(),
+			Platform:     canvas.PlatformName,
+			PrivacyLevel: string(t.privacyLevel()),
+			Settings: canvas.DeveloperKeySettings{
+				Text:       t.Title,
+				Placements: placements,
+			},
+		}},
+		CustomFields: t.CustomFields,
+	}
+}
+
+// CanvasJSON renders the Tool as the Canvas developer key JSON configuration
+func (t Tool) CanvasJSON() ([]byte, error) {
+	if err := t.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid tool config: %v", err)
+	}
+
+	return t.CanvasDeveloperKey().JSON()
+}
@@ -0,0 +1,100 @@
+package toolconfig
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ClientMetadata is the OpenID Connect client registration metadata sent by the tool during
+// 1EdTech LTI Dynamic Registration, see https://www.imsglobal.org/spec/lti-dr/v1p0#tool-configuration
+type ClientMetadata struct {
+	ApplicationType         string               `json:"application_type"`
+	ResponseTypes           []string             `json:"response_types"`
+	GrantTypes              []string             `json:"grant_types"`
+	InitiateLoginURI        string               `json:"initiate_login_uri"`
+	RedirectURIs            []string             `json:"redirect_uris"`
+	ClientName              string               `json:"client_name"`
+	JWKSURI                 string               `json:"jwks_uri"`
+	TokenEndpointAuthMethod string               `json:"token_endpoint_auth_method"`
+	Scope                   string               `json:"scope,omitempty"`
+	LTIToolConfiguration    LTIToolConfiguration `json:"https://purl.imsglobal.org/spec/lti-tool-configuration"`
+}
+
+// LTIToolConfiguration is the LTI specific tool configuration of the ClientMetadata
+type LTIToolConfiguration struct {
+	Domain           string            `json:"domain"`
+	Description      string            `json:"description,omitempty"`
+	TargetLinkURI    string            `json:"target_link_uri"`
+	CustomParameters map[string]string `json:"custom_parameters,omitempty"`
+	Claims           []string          `json:"claims"`
+	Messages         []LTIMessage      `json:"messages,omitempty"`
+}
+
+// LTIMessage is a message (launch placement) supported by the tool in the LTIToolConfiguration
+type LTIMessage struct {
+	Type          string   `json:"type"`
+	TargetLinkURI string   `json:"target_link_uri,omitempty"`
+	Label         string   `json:"label,omitempty"`
+	IconURI       string   `json:"icon_uri,omitempty"`
+	Placements    []string `json:"placements,omitempty"`
+}
+
+// DynamicRegistrationMetadata renders the Tool as the 1EdTech LTI Dynamic Registration client_metadata
+func (t Tool) DynamicRegistrationMetadata() ClientMetadata {
+	messages := make([]LTIMessage, 0, len(t.Placements))
+	for _, p := range t.Placements {
+		messages = append(messages, LTIMessage{
+			Type:          t.messageType(p),
+			TargetLinkURI: t.placementTargetLinkURI(p),
+			Label:         t.placementLabel(p),
+			IconURI:       p.IconURL,
+			Placements:    []string{p.Placement},
+		})
+	}
+
+	return ClientMetadata{
+		ApplicationType:         "web",
+		ResponseTypes:           []string{"id_token"},
+		GrantTypes:              []string{"implicit", "client_credentials"},
+		InitiateLoginURI:        t.OIDCLoginURL,
+		RedirectURIs:            t.RedirectURIs,
+		ClientName:              t.Title,
+		JWKSURI:                 t.JWKSURL,
+		TokenEndpointAuthMethod: "private_key_jwt",
+		Scope:                   strings.Join(t.Scopes, " "),
+		LTIToolConfiguration: LTIToolConfiguration{
+			Domain:           t.domain(),
+			Description:      t.Description,
+			TargetLinkURI:    t.TargetLinkURI,
+			CustomParameters: t.CustomFields,
+			Claims:           t.claims(),
+			Messages:         messages,
+		},
+	}
+}
+
+// DynamicRegistrationJSON renders the Tool as the 1EdTech LTI Dynamic Registration client_metadata JSON document
+func (t Tool) DynamicRegistrationJSON() ([]byte, error) {
+	if err := t.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid tool config: %v", err)
+	}
+
+	return json.MarshalIndent(t.DynamicRegistrationMetadata(), "", "  ")
+}
+
+// claims returns the id_token claims requested for the Tool PrivacyLevel
+func (t Tool) claims() []string {
+	claims := []string{"iss", "sub"}
+
+	switch t.privacyLevel() {
+	case PrivacyLevelPublic:
+		claims = append(claims, "name", "given_name", "family_name", "email")
+	case PrivacyLevelNameOnly:
+		claims = append(claims, "name", "given_name", "family_name")
+	case PrivacyLevelEmailOnly:
+		claims = append(claims, "email")
+	}
+
+	return claims
+}
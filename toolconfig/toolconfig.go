@@ -0,0 +1,244 @@
+package toolconfig
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/stevenweathers/peregrine-lti/launch"
+	"github.com/stevenweathers/peregrine-lti/platforms/canvas"
+)
+
+// PrivacyLevel determines which user identifying claims the tool requests from the Platform,
+// the levels are those of the Canvas developer key privacy_level
+type PrivacyLevel string
+
+const (
+	// PrivacyLevelPublic requests the users name and email
+	PrivacyLevelPublic PrivacyLevel = canvas.PrivacyLevelPublic
+	// PrivacyLevelNameOnly requests only the users name
+	PrivacyLevelNameOnly PrivacyLevel = canvas.PrivacyLevelNameOnly
+	// PrivacyLevelEmailOnly requests only the users email
+	PrivacyLevelEmailOnly PrivacyLevel = canvas.PrivacyLevelEmailOnly
+	// PrivacyLevelAnonymous requests no user identifying claims
+	PrivacyLevelAnonymous PrivacyLevel = canvas.PrivacyLevelAnonymous
+)
+
+// Placement is a location within the Platform the tool can be launched from
+type Placement struct {
+	// Placement (REQUIRED) is the name of the location ex. course_navigation
+	Placement string
+	// Label (OPTIONAL) is the text shown for the placement, defaults to the Tool Title
+	Label string
+	// MessageType (OPTIONAL) is the LTI message type of the placement launch, defaults to LtiResourceLinkRequest
+	MessageType string
+	// TargetLinkURI (OPTIONAL) is the placement specific launch url, defaults to the Tool TargetLinkURI
+	TargetLinkURI string
+	// IconURL (OPTIONAL) is the icon shown for the placement
+	IconURL string
+}
+
+// Tool describes the LTI tool as registered in a Platform and renders it as the configuration each Platform expects
+type Tool struct {
+	// Title (REQUIRED) is the name of the tool
+	Title string
+	// Description (REQUIRED) is the description of the tool
+	Description string
+	// TargetLinkURI (REQUIRED) is the tools default launch url
+	TargetLinkURI string
+	// OIDCLoginURL (REQUIRED) is the tools OIDC third party initiated login url
+	OIDCLoginURL string
+	// RedirectURIs (REQUIRED) are the tools OIDC callback urls
+	RedirectURIs []string
+	// JWKSURL (REQUIRED) is the tools public JWK key set url
+	JWKSURL string
+	// Placements (OPTIONAL) are the locations within the Platform the tool can be launched from
+	Placements []Placement
+	// Scopes (OPTIONAL) are the LTI Advantage scopes the tool requests
+	Scopes []string
+	// CustomFields (OPTIONAL) are sent with every launch
+	CustomFields map[string]string
+	// PrivacyLevel (OPTIONAL) determines which user identifying claims are requested, defaults to PrivacyLevelAnonymous
+	PrivacyLevel PrivacyLevel
+}
+
+// Config holds the values a Tool is built from, the same values the tools launch routes use
+type Config struct {
+	// ToolConfig (REQUIRED) is the launch.ToolConfig of the tool identity, see launch.Config ToolConfig,
+	// its CallbackURL is the redirect uri and its first AllowedTargetLinkURIs the target link uri
+	// (defaulting to the CallbackURL origin as launch.Service does)
+	ToolConfig launch.ToolConfig
+	// LoginURL (REQUIRED) is the url the tool serves launch.Service HandleOidcLogin at
+	LoginURL string
+	// JWKSURL (REQUIRED) is the url the tool serves its public key set at
+	JWKSURL string
+	// Title (REQUIRED) is the name of the tool
+	Title string
+	// Description (REQUIRED) is the description of the tool
+	Description string
+}
+
+// New returns a Tool with its endpoint urls taken from the launch.ToolConfig and the urls the tool serves
+// its login and key set at
+func New(config Config) (Tool, error) {
+	callbackURL, err := url.Parse(config.ToolConfig.CallbackURL)
+	if err != nil || callbackURL.Scheme != "https" || callbackURL.Host == "" {
+		return Tool{}, fmt.Errorf("tool callback url %s must be an absolute https url", config.ToolConfig.CallbackURL)
+	}
+	loginURL, err := url.Parse(config.LoginURL)
+	if err != nil || loginURL.Scheme != "https" || loginURL.Host == "" {
+		return Tool{}, fmt.Errorf("tool login url %s must be an absolute https url", config.LoginURL)
+	}
+	if !isAbsoluteURL(config.JWKSURL) {
+		return Tool{}, fmt.Errorf("tool jwks url %s must be an absolute url", config.JWKSURL)
+	}
+
+	targetLinkURI := callbackURL.Scheme + "://" + callbackURL.Host + "/"
+	if len(config.ToolConfig.AllowedTargetLinkURIs) > 0 {
+		targetLinkURI = config.ToolConfig.AllowedTargetLinkURIs[0]
+	}
+
+	return Tool{
+		Title:         config.Title,
+		Description:   config.Description,
+		TargetLinkURI: targetLinkURI,
+		OIDCLoginURL:  loginURL.String(),
+		RedirectURIs:  []string{callbackURL.String()},
+		JWKSURL:       config.JWKSURL,
+		PrivacyLevel:  PrivacyLevelAnonymous,
+	}, nil
+}
+
+// CallbackURL returns the tools primary OIDC callback url to use as the authentication request redirect_uri
+func (t Tool) CallbackURL() string {
+	if len(t.RedirectURIs) == 0 {
+		return ""
+	}
+
+	return t.RedirectURIs[0]
+}
+
+// Validate checks the Tool has the values required by every Platform
+func (t Tool) Validate() error {
+	if t.Title == "" {
+		return fmt.Errorf("MISSING_TITLE")
+	}
+	if t.Description == "" {
+		return fmt.Errorf("MISSING_DESCRIPTION")
+	}
+	if !isAbsoluteURL(t.TargetLinkURI) {
+		return fmt.Errorf("INVALID_TARGET_LINK_URI")
+	}
+	if !isAbsoluteURL(t.OIDCLoginURL) {
+		return fmt.Errorf("INVALID_OIDC_LOGIN_URL")
+	}
+	if len(t.RedirectURIs) == 0 {
+		return fmt.Errorf("MISSING_REDIRECT_URIS")
+	}
+	for _, u := range t.RedirectURIs {
+		if !isAbsoluteURL(u) {
+			return fmt.Errorf("INVALID_REDIRECT_URI")
+		}
+	}
+	if !isAbsoluteURL(t.JWKSURL) {
+		return fmt.Errorf("INVALID_JWKS_URL")
+	}
+	switch t.PrivacyLevel {
+	case "", PrivacyLevelPublic, PrivacyLevelNameOnly, PrivacyLevelEmailOnly, PrivacyLevelAnonymous:
+	default:
+		return fmt.Errorf("INVALID_PRIVACY_LEVEL")
+	}
+	for _, p := range t.Placements {
+		if p.Placement == "" {
+			return fmt.Errorf("MISSING_PLACEMENT")
+		}
+	}
+
+	return nil
+}
+
+// Summary renders a human-readable summary of the Tool configuration for manual registration in a Platform
+func (t Tool) Summary() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "Title: %s\n", t.Title)
+	fmt.Fprintf(&b, "Description: %s\n", t.Description)
+	fmt.Fprintf(&b, "Target Link URI: %s\n", t.TargetLinkURI)
+	fmt.Fprintf(&b, "OIDC Login URL: %s\n", t.OIDCLoginURL)
+	fmt.Fprintf(&b, "Redirect URIs: %s\n", strings.Join(t.RedirectURIs, ", "))
+	fmt.Fprintf(&b, "Public JWK Set URL: %s\n", t.JWKSURL)
+	fmt.Fprintf(&b, "Privacy Level: %s\n", t.privacyLevel())
+	if len(t.Scopes) > 0 {
+		fmt.Fprintf(&b, "Scopes:\n")
+		for _, s := range t.Scopes {
+			fmt.Fprintf(&b, "  - %s\n", s)
+		}
+	}
+	if len(t.Placements) > 0 {
+		fmt.Fprintf(&b, "Placements:\n")
+		for _, p := range t.Placements {
+			fmt.Fprintf(&b, "  - %s (%s) %s\n", p.Placement, t.messageType(p), t.placementTargetLinkURI(p))
+		}
+	}
+	if len(t.CustomFields) > 0 {
+		fmt.Fprintf(&b, "Custom Fields:\n")
+		keys := make([]string, 0, len(t.CustomFields))
+		for k := range t.CustomFields {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(&b, "  %s=%s\n", k, t.CustomFields[k])
+		}
+	}
+
+	return b.String()
+}
+
+func (t Tool) privacyLevel() PrivacyLevel {
+	if t.PrivacyLevel == "" {
+		return PrivacyLevelAnonymous
+	}
+
+	return t.PrivacyLevel
+}
+
+func (t Tool) messageType(p Placement) string {
+	if p.MessageType == "" {
+		return "LtiResourceLinkRequest"
+	}
+
+	return p.MessageType
+}
+
+func (t Tool) placementLabel(p Placement) string {
+	if p.Label == "" {
+		return t.Title
+	}
+
+	return p.Label
+}
+
+func (t Tool) placementTargetLinkURI(p Placement) string {
+	if p.TargetLinkURI == "" {
+		return t.TargetLinkURI
+	}
+
+	return p.TargetLinkURI
+}
+
+// domain returns the host of the tools TargetLinkURI
+func (t Tool) domain() string {
+	u, err := url.Parse(t.TargetLinkURI)
+	if err != nil {
+		return ""
+	}
+
+	return u.Host
+}
+
+func isAbsoluteURL(u string) bool {
+	parsed, err := url.ParseRequestURI(u)
+	return err == nil && parsed.Scheme != "" && parsed.Host != ""
+}
@@ -0,0 +1,191 @@
+package toolconfig
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stevenweathers/peregrine-lti/launch"
+)
+
+const testJWKSURL = "https://stevenweathers.dev/.well-known/jwks.json"
+
+func testConfig() Config {
+	return Config{
+		ToolConfig: launch.Config{
+			Issuer:      "peregrine",
+			CallbackURL: "https://stevenweathers.dev/lti/callback",
+		}.ToolConfig(),
+		LoginURL:    "https://stevenweathers.dev/lti/login",
+		JWKSURL:     testJWKSURL,
+		Title:       "Peregrine",
+		Description: "Peregrine LTI tool",
+	}
+}
+
+func testTool(t *testing.T) Tool {
+	tool, err := New(testConfig())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tool.PrivacyLevel = PrivacyLevelNameOnly
+	tool.Scopes = []string{"https://purl.imsglobal.org/spec/lti-nrps/scope/contextmembership.readonly"}
+	tool.CustomFields = map[string]string{"canvas_course_id": "$Canvas.course.id"}
+	tool.Placements = []Placement{{Placement: "course_navigation", Label: "Peregrine"}}
+
+	return tool
+}
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+	tool := testTool(t)
+
+	if tool.OIDCLoginURL != "https://stevenweathers.dev/lti/login" {
+		t.Fatalf("expected oidc login url %s to equal https://stevenweathers.dev/lti/login", tool.OIDCLoginURL)
+	}
+	if tool.CallbackURL() != "https://stevenweathers.dev/lti/callback" {
+		t.Fatalf("expected callback url %s to equal https://stevenweathers.dev/lti/callback", tool.CallbackURL())
+	}
+	if tool.JWKSURL != testJWKSURL {
+		t.Fatalf("expected jwks url %s to equal %s", tool.JWKSURL, testJWKSURL)
+	}
+	if tool.TargetLinkURI != "https://stevenweathers.dev/" {
+		t.Fatalf("expected target link uri %s to equal https://stevenweathers.dev/", tool.TargetLinkURI)
+	}
+
+	config := testConfig()
+	config.ToolConfig.AllowedTargetLinkURIs = []string{"https://stevenweathers.dev/lti/launch"}
+	tool, err := New(config)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if tool.TargetLinkURI != "https://stevenweathers.dev/lti/launch" {
+		t.Fatalf("expected target link uri %s to be the first allowed target link uri", tool.TargetLinkURI)
+	}
+
+	config = testConfig()
+	config.ToolConfig.CallbackURL = "http://stevenweathers.dev/lti/callback"
+	_, err = New(config)
+	if err == nil || !strings.Contains(err.Error(), "callback url") {
+		t.Fatalf("expected error: %v", err)
+	}
+
+	config = testConfig()
+	config.LoginURL = ""
+	_, err = New(config)
+	if err == nil || !strings.Contains(err.Error(), "login url") {
+		t.Fatalf("expected error: %v", err)
+	}
+
+	config = testConfig()
+	config.JWKSURL = ""
+	_, err = New(config)
+	if err == nil || !strings.Contains(err.Error(), "jwks url") {
+		t.Fatalf("expected error: %v", err)
+	}
+}
+
+func TestCanvasJSON(t *testing.T) {
+	t.Parallel()
+	b, err := testTool(t).CanvasJSON()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var decoded struct {
+		OIDCInitiationURL string `json:"oidc_initiation_url"`
+		PublicJWKURL      string `json:"public_jwk_url"`
+		Extensions        []struct {
+			Domain       string `json:"domain"`
+			PrivacyLevel string `json:"privacy_level"`
+			Settings     struct {
+				Placements []struct {
+					Placement     string `json:"placement"`
+					MessageType   string `json:"message_type"`
+					TargetLinkURI string `json:"target_link_uri"`
+				} `json:"placements"`
+			} `json:"settings"`
+		} `json:"extensions"`
+	}
+	if err = json.Unmarshal(b, &decoded); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if decoded.OIDCInitiationURL != "https://stevenweathers.dev/lti/login" {
+		t.Fatalf("expected oidc_initiation_url %s to equal https://stevenweathers.dev/lti/login", decoded.OIDCInitiationURL)
+	}
+	if decoded.PublicJWKURL != testJWKSURL {
+		t.Fatalf("expected public_jwk_url %s to equal %s", decoded.PublicJWKURL, testJWKSURL)
+	}
+	ext := decoded.Extensions[0]
+	if ext.Domain != "stevenweathers.dev" || ext.PrivacyLevel != "name_only" {
+		t.Fatalf("expected extension domain and privacy level to be rendered got %s %s", ext.Domain, ext.PrivacyLevel)
+	}
+	placement := ext.Settings.Placements[0]
+	if placement.Placement != "course_navigation" || placement.MessageType != "LtiResourceLinkRequest" ||
+		placement.TargetLinkURI != "https://stevenweathers.dev/" {
+		t.Fatalf("expected placement defaults to be rendered got %+v", placement)
+	}
+}
+
+func TestDynamicRegistrationJSON(t *testing.T) {
+	t.Parallel()
+	b, err := testTool(t).DynamicRegistrationJSON()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var decoded ClientMetadata
+	if err = json.Unmarshal(b, &decoded); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if decoded.InitiateLoginURI != "https://stevenweathers.dev/lti/login" {
+		t.Fatalf("expected initiate_login_uri %s to equal https://stevenweathers.dev/lti/login", decoded.InitiateLoginURI)
+	}
+	if decoded.RedirectURIs[0] != "https://stevenweathers.dev/lti/callback" {
+		t.Fatalf("expected redirect_uris %v to contain https://stevenweathers.dev/lti/callback", decoded.RedirectURIs)
+	}
+	if decoded.Scope != "https://purl.imsglobal.org/spec/lti-nrps/scope/contextmembership.readonly" {
+		t.Fatalf("expected scope to be rendered got %s", decoded.Scope)
+	}
+	toolCfg := decoded.LTIToolConfiguration
+	if strings.Join(toolCfg.Claims, " ") != "iss sub name given_name family_name" {
+		t.Fatalf("expected name_only claims got %v", toolCfg.Claims)
+	}
+	if toolCfg.Messages[0].Placements[0] != "course_navigation" {
+		t.Fatalf("expected message placement course_navigation got %v", toolCfg.Messages[0].Placements)
+	}
+}
+
+func TestSummary(t *testing.T) {
+	t.Parallel()
+	summary := testTool(t).Summary()
+
+	for _, expected := range []string{
+		"OIDC Login URL: https://stevenweathers.dev/lti/login",
+		"Redirect URIs: https://stevenweathers.dev/lti/callback",
+		"Privacy Level: name_only",
+		"course_navigation (LtiResourceLinkRequest) https://stevenweathers.dev/",
+		"canvas_course_id=$Canvas.course.id",
+	} {
+		if !strings.Contains(summary, expected) {
+			t.Fatalf("expected summary to contain %s got %s", expected, summary)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+	tool := testTool(t)
+	tool.RedirectURIs = nil
+	if err := tool.Validate(); err == nil || err.Error() != "MISSING_REDIRECT_URIS" {
+		t.Fatalf("expected MISSING_REDIRECT_URIS error: %v", err)
+	}
+
+	tool = testTool(t)
+	tool.PrivacyLevel = "everything"
+	if _, err := tool.CanvasJSON(); err == nil || !strings.Contains(err.Error(), "INVALID_PRIVACY_LEVEL") {
+		t.Fatalf("expected INVALID_PRIVACY_LEVEL error: %v", err)
+	}
+}